@@ -2,14 +2,19 @@ package main
 
 import (
 	"crypto/tls"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/context_providers"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/go-redis/redis/v8"
 )
 
 func getAssignmentsManager(cfg *config.Config) (assignmentsManager connectors.AssignmentsManager, err error) {
@@ -34,6 +39,8 @@ func getAssignmentsManager(cfg *config.Config) (assignmentsManager connectors.As
 			LogLevel:  cfg.GetStringDefault("log.level", config.LoggerLevel),
 			LogFormat: logger.LogFormat(cfg.GetStringDefault("log.format", config.LoggerFormat)),
 			TLSConfig: tlsConfig,
+			// migrates the assignments saved before the keys were scoped by environment, disabled by default
+			LegacyEnvID: cfg.GetStringDefault("cache.options.redisLegacyEnvId", ""),
 		})
 	case "dynamo":
 		session, _ := session.NewSession()
@@ -53,3 +60,109 @@ func getAssignmentsManager(cfg *config.Config) (assignmentsManager connectors.As
 
 	return assignmentsManager, err
 }
+
+// getContextProvider returns the connector fetching the visitor context of the integration providers, or nil if disabled
+func getContextProvider(cfg *config.Config) (connectors.ContextProvider, error) {
+	switch cfg.GetStringDefault("context_provider.type", "") {
+	case "":
+		return nil, nil
+	case "udc":
+		return context_providers.NewUDCProvider(context_providers.UDCOptions{
+			URL: cfg.GetStringDefault("context_provider.options.udcUrl", context_providers.DefaultUDCURL),
+		}), nil
+	case "file":
+		return context_providers.InitFileProvider(context_providers.FileOptions{
+			Path: cfg.GetString("context_provider.options.filePath"),
+		})
+	case "redis":
+		var tlsConfig *tls.Config
+		if cfg.GetBool("context_provider.options.redisTls") {
+			tlsConfig = &tls.Config{}
+		}
+		return context_providers.InitRedisProvider(context_providers.RedisOptions{
+			Host:      cfg.GetStringDefault("context_provider.options.redisHost", config.RedisAddr),
+			Username:  cfg.GetStringDefault("context_provider.options.redisUsername", ""),
+			Password:  cfg.GetStringDefault("context_provider.options.redisPassword", ""),
+			Db:        cfg.GetIntDefault("context_provider.options.redisDb", 0),
+			KeyPrefix: cfg.GetStringDefault("context_provider.options.redisKeyPrefix", context_providers.DefaultRedisKeyPrefix),
+			TLSConfig: tlsConfig,
+		})
+	default:
+		return nil, fmt.Errorf("unknown context provider %s", cfg.GetString("context_provider.type"))
+	}
+}
+
+type rateLimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+type routeRateLimitsConfig struct {
+	IP        *rateLimitConfig `mapstructure:"ip"`
+	APIKey    *rateLimitConfig `mapstructure:"api_key"`
+	VisitorID *rateLimitConfig `mapstructure:"visitor_id"`
+}
+
+func (c *rateLimitConfig) toRateLimit() *models.RateLimit {
+	if c == nil {
+		return nil
+	}
+	return &models.RateLimit{Rate: c.Rate, Burst: c.Burst}
+}
+
+func (c *routeRateLimitsConfig) toRouteRateLimits() *models.RouteRateLimits {
+	if c == nil {
+		return nil
+	}
+	return &models.RouteRateLimits{
+		IP:        c.IP.toRateLimit(),
+		APIKey:    c.APIKey.toRateLimit(),
+		VisitorID: c.VisitorID.toRateLimit(),
+	}
+}
+
+// getRateLimitOptions returns the default and per route rate limits
+func getRateLimitOptions(cfg *config.Config) (*models.RateLimitOptions, error) {
+	defaultLimits := &routeRateLimitsConfig{}
+	if err := cfg.UnmarshalKey("rate_limit.default", &defaultLimits); err != nil {
+		return nil, err
+	}
+	routes := map[string]*routeRateLimitsConfig{}
+	if err := cfg.UnmarshalKey("rate_limit.routes", &routes); err != nil {
+		return nil, err
+	}
+
+	options := &models.RateLimitOptions{
+		Enabled:           cfg.GetBool("rate_limit.enabled"),
+		Default:           defaultLimits.toRouteRateLimits(),
+		Routes:            map[string]*models.RouteRateLimits{},
+		TrustForwardedFor: cfg.GetBool("rate_limit.trust_forwarded_for"),
+		TrustedProxies:    cfg.GetStringSlice("rate_limit.trusted_proxies"),
+	}
+	for route, limits := range routes {
+		options.Routes[route] = limits.toRouteRateLimits()
+	}
+	return options, nil
+}
+
+func getRateLimitStore(cfg *config.Config) (middlewares.RateLimitStore, error) {
+	switch cfg.GetStringDefault("rate_limit.store", config.RateLimitStore) {
+	case "memory":
+		return middlewares.NewMemoryRateLimitStore(), nil
+	case "redis":
+		var tlsConfig *tls.Config
+		if cfg.GetBool("rate_limit.options.redisTls") {
+			tlsConfig = &tls.Config{}
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:      cfg.GetStringDefault("rate_limit.options.redisHost", config.RedisAddr),
+			Username:  cfg.GetStringDefault("rate_limit.options.redisUsername", ""),
+			Password:  cfg.GetStringDefault("rate_limit.options.redisPassword", ""),
+			DB:        cfg.GetIntDefault("rate_limit.options.redisDb", 0),
+			TLSConfig: tlsConfig,
+		})
+		return middlewares.NewRedisRateLimitStore(client, cfg.GetStringDefault("rate_limit.options.redisKeyPrefix", "rate_limit:")), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %s", cfg.GetString("rate_limit.store"))
+	}
+}
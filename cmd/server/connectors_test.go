@@ -5,6 +5,9 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/context_providers"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,3 +46,78 @@ func TestGetAssignmentsManager(t *testing.T) {
 	assert.Nil(t, err)
 	assert.IsType(t, &assignments_managers.DynamoManager{}, assignmentsManager)
 }
+
+func TestGetContextProvider(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	provider, err := getContextProvider(cfg)
+	assert.Nil(t, err)
+	assert.Nil(t, provider)
+
+	cfg.Set("context_provider.type", "udc")
+	provider, err = getContextProvider(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, &context_providers.UDCProvider{}, provider)
+
+	cfg.Set("context_provider.type", "file")
+	cfg.Set("context_provider.options.filePath", "missing.json")
+	_, err = getContextProvider(cfg)
+	assert.NotNil(t, err)
+
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	cfg.Set("context_provider.type", "redis")
+	cfg.Set("context_provider.options.redisHost", s.Addr())
+	provider, err = getContextProvider(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, &context_providers.RedisProvider{}, provider)
+
+	cfg.Set("context_provider.type", "unknown")
+	_, err = getContextProvider(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestGetRateLimitOptions(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	options, err := getRateLimitOptions(cfg)
+	assert.Nil(t, err)
+	assert.False(t, options.Enabled)
+	assert.Len(t, options.Routes, 0)
+
+	cfg.Set("rate_limit.enabled", true)
+	cfg.Set("rate_limit.trust_forwarded_for", true)
+	cfg.Set("rate_limit.default", map[string]interface{}{
+		"ip": map[string]interface{}{"rate": 100, "burst": 200},
+	})
+	cfg.Set("rate_limit.routes", map[string]interface{}{
+		"activate": map[string]interface{}{
+			"visitor_id": map[string]interface{}{"rate": 0.5, "burst": 5},
+		},
+	})
+	options, err = getRateLimitOptions(cfg)
+	assert.Nil(t, err)
+	assert.True(t, options.Enabled)
+	assert.True(t, options.TrustForwardedFor)
+	assert.Equal(t, &models.RateLimit{Rate: 100, Burst: 200}, options.Default.IP)
+	assert.Nil(t, options.Default.VisitorID)
+	assert.Equal(t, &models.RateLimit{Rate: 0.5, Burst: 5}, options.Routes["activate"].VisitorID)
+	assert.Nil(t, options.Routes["activate"].IP)
+}
+
+func TestGetRateLimitStore(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	store, err := getRateLimitStore(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, &middlewares.MemoryRateLimitStore{}, store)
+
+	cfg.Set("rate_limit.store", "redis")
+	store, err = getRateLimitStore(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, &middlewares.RedisRateLimitStore{}, store)
+
+	cfg.Set("rate_limit.store", "unknown")
+	_, err = getRateLimitStore(cfg)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/utils/config"
+)
+
+const healthcheckTimeout = 5 * time.Second
+
+// getHealthcheckURL returns the URL of the readiness endpoint of the server, on its configured address.
+// An address without host, or listening on all interfaces, is called on localhost
+func getHealthcheckURL(cfg *config.Config) (string, error) {
+	host, port, err := net.SplitHostPort(cfg.GetStringDefault("address", config.ServerAddress))
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	scheme := "http"
+	if cfg.GetString("tls.cert_file") != "" && cfg.GetString("tls.key_file") != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/v2/health/ready", scheme, net.JoinHostPort(host, port)), nil
+}
+
+// getHealthcheckTLSConfig returns the TLS config verifying the server certificate against the system roots and
+// the configured certificate, so that self-signed certificates are trusted. The server name can be set with
+// healthcheck.tls_server_name when the certificate is not issued for the configured host. With mutual TLS, the
+// healthcheck.tls_cert_file and healthcheck.tls_key_file client certificate, issued by the client CA, is presented
+func getHealthcheckTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	certFile := cfg.GetString("tls.cert_file")
+	if certFile == "" {
+		return nil, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading TLS certificate: %v", err)
+	}
+	roots.AppendCertsFromPEM(data)
+
+	tlsConfig := &tls.Config{
+		RootCAs:    roots,
+		ServerName: cfg.GetString("healthcheck.tls_server_name"),
+	}
+	if cfg.GetString("tls.client_ca_file") != "" {
+		clientCertFile, clientKeyFile := cfg.GetString("healthcheck.tls_cert_file"), cfg.GetString("healthcheck.tls_key_file")
+		if clientCertFile == "" || clientKeyFile == "" {
+			return nil, errors.New("healthcheck.tls_cert_file and healthcheck.tls_key_file are mandatory with mutual TLS")
+		}
+		clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when loading healthcheck client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return tlsConfig, nil
+}
+
+// healthcheck calls the readiness endpoint of the running server, to be used as a container health check
+func healthcheck(cfg *config.Config) error {
+	url, err := getHealthcheckURL(cfg)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := getHealthcheckTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout:   healthcheckTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server is not ready: status %d", resp.StatusCode)
+	}
+	return nil
+}
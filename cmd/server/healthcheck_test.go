@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/utils/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthcheck(t *testing.T) {
+	cfg, _ := config.NewFromFilename("")
+	url, err := getHealthcheckURL(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:8080/v2/health/ready", url)
+
+	cfg.Set("tls.cert_file", "cert.pem")
+	cfg.Set("tls.key_file", "key.pem")
+	url, err = getHealthcheckURL(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://localhost:8080/v2/health/ready", url)
+
+	cfg.Set("address", "10.0.0.1:8081")
+	url, err = getHealthcheckURL(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://10.0.0.1:8081/v2/health/ready", url)
+
+	cfg.Set("address", "0.0.0.0:8081")
+	url, err = getHealthcheckURL(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://localhost:8081/v2/health/ready", url)
+
+	ready := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/health/ready", r.URL.Path)
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	cfg, _ = config.NewFromFilename("")
+	cfg.Set("address", ":"+port)
+	assert.Nil(t, healthcheck(cfg))
+
+	ready = false
+	assert.NotNil(t, healthcheck(cfg))
+}
+
+func TestHealthcheckTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	cfg, _ := config.NewFromFilename("")
+	cfg.Set("address", ts.Listener.Addr().String())
+	cfg.Set("tls.key_file", "key.pem")
+
+	// the configured certificate must be readable to verify the server
+	cfg.Set("tls.cert_file", filepath.Join(t.TempDir(), "missing.pem"))
+	assert.NotNil(t, healthcheck(cfg))
+
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+	assert.Nil(t, err)
+	cfg.Set("tls.cert_file", certFile)
+	assert.Nil(t, healthcheck(cfg))
+
+	// the certificate is verified against the server name
+	cfg.Set("healthcheck.tls_server_name", "decision-api.internal")
+	assert.NotNil(t, healthcheck(cfg))
+}
+
+// writeHealthcheckCertificate writes a self-signed certificate for 127.0.0.1, usable as CA, server and client certificate
+func writeHealthcheckCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "decision-api"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestHealthcheckMutualTLS(t *testing.T) {
+	certFile, keyFile := writeHealthcheckCertificate(t, t.TempDir())
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.Nil(t, err)
+	clientCAs := x509.NewCertPool()
+	data, _ := os.ReadFile(certFile)
+	clientCAs.AppendCertsFromPEM(data)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	cfg, _ := config.NewFromFilename("")
+	cfg.Set("address", ts.Listener.Addr().String())
+	cfg.Set("tls.cert_file", certFile)
+	cfg.Set("tls.key_file", keyFile)
+	cfg.Set("tls.client_ca_file", certFile)
+
+	// the client certificate is mandatory with mutual TLS
+	assert.NotNil(t, healthcheck(cfg))
+
+	cfg.Set("healthcheck.tls_cert_file", certFile)
+	cfg.Set("healthcheck.tls_key_file", keyFile)
+	assert.Nil(t, healthcheck(cfg))
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/server"
+	"github.com/flagship-io/decision-api/pkg/utils/config"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadableKeys are the prefixes of the configuration keys applied when the configuration is reloaded.
+// Changes of the other keys require a restart
+var reloadableKeys = []string{"log.", "cors.", "polling_interval", "cache.", "hits."}
+
+// secretKeys are the parts of the configuration keys whose values are not logged
+var secretKeys = []string{"password", "api_key", "apikey", "secret", "token", "environments"}
+
+func hasKeyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretKey(key string) bool {
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func getCorsOptions(cfg *config.Config) *models.CorsOptions {
+	return &models.CorsOptions{
+		Enabled:        cfg.GetBool("cors.enabled"),
+		AllowedOrigins: cfg.GetStringDefault("cors.allowed_origins", config.ServerCorsAllowedOrigins),
+		AllowedHeaders: cfg.GetStringDefault("cors.allowed_headers", config.ServerCorsAllowedHeaders),
+	}
+}
+
+func getReloadOptions(cfg *config.Config) *models.ReloadOptions {
+	return &models.ReloadOptions{
+		LogLevel:           cfg.GetStringDefault("log.level", config.LoggerLevel),
+		LogFormat:          cfg.GetStringDefault("log.format", config.LoggerFormat),
+		CorsOptions:        getCorsOptions(cfg),
+		PollingInterval:    cfg.GetDuration("polling_interval"),
+		HitsBatchSize:      cfg.GetIntDefault("hits.batch_size", config.HitsBatchSize),
+		HitsBatchingWindow: cfg.GetDurationDefault("hits.batching_window", config.HitsBatchingWindow),
+	}
+}
+
+// validateConfig checks the values of the reloadable configuration keys
+func validateConfig(cfg *config.Config) error {
+	if _, err := logrus.ParseLevel(cfg.GetStringDefault("log.level", config.LoggerLevel)); err != nil {
+		return err
+	}
+	switch format := logger.LogFormat(cfg.GetStringDefault("log.format", config.LoggerFormat)); format {
+	case logger.FORMAT_JSON, logger.FORMAT_TEXT:
+	default:
+		return fmt.Errorf("unknown log format %s", format)
+	}
+	if cfg.GetDuration("polling_interval") <= 0 {
+		return errors.New("polling_interval must be positive")
+	}
+	if cfg.GetIntDefault("hits.batch_size", config.HitsBatchSize) <= 0 {
+		return errors.New("hits.batch_size must be positive")
+	}
+	if cfg.GetDurationDefault("hits.batching_window", config.HitsBatchingWindow) <= 0 {
+		return errors.New("hits.batching_window must be positive")
+	}
+	switch cacheType := cfg.GetStringDefault("cache.type", ""); cacheType {
+	case "", "memory", "local", "redis", "dynamo":
+	default:
+		return fmt.Errorf("unknown cache type %s", cacheType)
+	}
+	return nil
+}
+
+// changedKeys returns the sorted configuration keys whose values differ between the configurations
+func changedKeys(previous *config.Config, next *config.Config) []string {
+	keys := map[string]bool{}
+	for _, key := range previous.AllKeys() {
+		keys[key] = true
+	}
+	for _, key := range next.AllKeys() {
+		keys[key] = true
+	}
+
+	changed := []string{}
+	for key := range keys {
+		if fmt.Sprint(previous.Get(key)) != fmt.Sprint(next.Get(key)) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// diffConfig describes the changes of the keys between the configurations, without the secret values
+func diffConfig(previous *config.Config, next *config.Config, keys []string) string {
+	lines := []string{}
+	for _, key := range keys {
+		if isSecretKey(key) {
+			lines = append(lines, fmt.Sprintf("%s: <redacted>", key))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %v -> %v", key, previous.Get(key), next.Get(key)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configReloader applies the changes of the configuration file to the running server
+type configReloader struct {
+	filename string
+	cfg      *config.Config
+	server   *server.Server
+	logger   *logger.Logger
+}
+
+// reload reads the configuration file and applies the reloadable changes. Invalid configurations are rejected
+// and the running configuration is kept
+func (r *configReloader) reload() error {
+	cfg, err := config.NewFromFilename(r.filename)
+	if err != nil {
+		r.logger.Errorf("configuration rejected, keeping the running configuration: %v", err)
+		return err
+	}
+
+	keys := changedKeys(r.cfg, cfg)
+	if len(keys) == 0 {
+		r.logger.Info("configuration unchanged")
+		return nil
+	}
+	diff := diffConfig(r.cfg, cfg, keys)
+
+	if err := validateConfig(cfg); err != nil {
+		r.logger.Errorf("invalid configuration rejected, keeping the running configuration: %v\n%s", err, diff)
+		return err
+	}
+
+	cacheChanged := false
+	for _, key := range keys {
+		if !hasKeyPrefix(key, reloadableKeys) {
+			r.logger.Warnf("changes of %s require a restart", key)
+		}
+		cacheChanged = cacheChanged || strings.HasPrefix(key, "cache.")
+	}
+
+	// the new components are built before any change is applied, then swapped together by the server
+	var assignmentsManager connectors.AssignmentsManager
+	if cacheChanged {
+		assignmentsManager, err = getAssignmentsManager(cfg)
+		if err != nil {
+			r.logger.Errorf("invalid cache configuration rejected, keeping the running configuration: %v\n%s", err, diff)
+			return err
+		}
+	}
+
+	if err := r.server.Reload(context.Background(), getReloadOptions(cfg), assignmentsManager); err != nil {
+		r.logger.Errorf("configuration rejected, keeping the running configuration: %v\n%s", err, diff)
+		return err
+	}
+
+	r.cfg = cfg
+	r.logger.Infof("configuration reloaded:\n%s", diff)
+	return nil
+}
+
+// watchConfigFile notifies the reloads channel when the configuration file is written.
+// The directory is watched as editors often replace the file
+func watchConfigFile(filename string, reloads chan<- struct{}, log *logger.Logger) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(filename)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(filename) || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				select {
+				case reloads <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Errorf("error when watching configuration file: %v", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
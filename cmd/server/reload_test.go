@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/utils/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, filename string, content string) {
+	err := os.WriteFile(filename, []byte(content), 0600)
+	assert.Nil(t, err)
+}
+
+func TestConfigReloader(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, filename, "env_id: env_id\napi_key: api_key\nlog:\n  level: warning\n")
+
+	cfg, err := config.NewFromFilename(filename)
+	assert.Nil(t, err)
+	log := createLogger(cfg)
+	srv, err := createServer(cfg, log)
+	assert.Nil(t, err)
+
+	reloader := &configReloader{filename: filename, cfg: cfg, server: srv, logger: log}
+	assert.Nil(t, reloader.reload())
+
+	writeConfig(t, filename, "env_id: env_id\napi_key: api_key\nlog:\n  level: debug\ncache:\n  type: memory\nhits:\n  batch_size: 10\n")
+	assert.Nil(t, reloader.reload())
+	assert.Equal(t, logrus.DebugLevel, log.Logger.Level)
+	assert.Equal(t, "memory", reloader.cfg.GetString("cache.type"))
+
+	// invalid configurations keep the running configuration
+	writeConfig(t, filename, "env_id: env_id\napi_key: api_key\nlog:\n  level: unknown\n")
+	assert.NotNil(t, reloader.reload())
+	assert.Equal(t, logrus.DebugLevel, log.Logger.Level)
+	assert.Equal(t, "debug", reloader.cfg.GetString("log.level"))
+
+	writeConfig(t, filename, "env_id: env_id\napi_key: api_key\nhits:\n  batch_size: 0\n")
+	assert.NotNil(t, reloader.reload())
+
+	writeConfig(t, filename, "env_id: [")
+	assert.NotNil(t, reloader.reload())
+	assert.Equal(t, "memory", reloader.cfg.GetString("cache.type"))
+}
+
+func TestDiffConfig(t *testing.T) {
+	previous, _ := config.NewFromFilename("test")
+	next, _ := config.NewFromFilename("test")
+	previous.Set("log.level", "warning")
+	next.Set("log.level", "debug")
+	next.Set("cache.options.redisPassword", "secret")
+
+	keys := changedKeys(previous, next)
+	assert.Equal(t, []string{"cache.options.redispassword", "log.level"}, keys)
+
+	diff := diffConfig(previous, next, keys)
+	assert.Contains(t, diff, "log.level: warning -> debug")
+	assert.Contains(t, diff, "cache.options.redispassword: <redacted>")
+	assert.NotContains(t, diff, "secret")
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, filename, "log:\n  level: warning\n")
+
+	cfg, _ := config.NewFromFilename(filename)
+	reloads := make(chan struct{}, 1)
+	watcher, err := watchConfigFile(filename, reloads, createLogger(cfg))
+	assert.Nil(t, err)
+	defer watcher.Close()
+
+	writeConfig(t, filename, "log:\n  level: debug\n")
+	select {
+	case <-reloads:
+	case <-time.After(2 * time.Second):
+		t.Error("expected a reload notification")
+	}
+}
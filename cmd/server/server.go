@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,13 +13,15 @@ import (
 	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
 	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
 	"github.com/flagship-io/decision-api/pkg/server"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/flagship-io/decision-api/pkg/utils/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-var shutdownTimeout = 3 * time.Second
-
 func createLogger(cfg *config.Config) *logger.Logger {
 	lvl := cfg.GetStringDefault("log.level", config.LoggerLevel)
 	format := cfg.GetStringDefault("log.format", config.LoggerFormat)
@@ -26,6 +29,148 @@ func createLogger(cfg *config.Config) *logger.Logger {
 	return logger.New(lvl, logger.LogFormat(format), "Server")
 }
 
+// getEnvironments returns the environments configured in the environments list,
+// or the single env_id & api_key pair if the list is empty
+func getEnvironments(cfg *config.Config) ([]models.EnvironmentCredentials, error) {
+	environments := []models.EnvironmentCredentials{}
+
+	configEnvironments := []struct {
+		EnvID  string `mapstructure:"env_id"`
+		APIKey string `mapstructure:"api_key"`
+	}{}
+	if err := cfg.UnmarshalKey("environments", &configEnvironments); err != nil {
+		return nil, err
+	}
+
+	for _, env := range configEnvironments {
+		environments = append(environments, models.EnvironmentCredentials{
+			EnvID:  env.EnvID,
+			APIKey: env.APIKey,
+		})
+	}
+
+	if len(environments) == 0 && (cfg.GetString("env_id") != "" || cfg.GetString("api_key") != "") {
+		environments = append(environments, models.EnvironmentCredentials{
+			EnvID:  cfg.GetString("env_id"),
+			APIKey: cfg.GetString("api_key"),
+		})
+	}
+
+	return environments, nil
+}
+
+// getGRPCAddress returns the gRPC server address if the gRPC server is enabled
+func getGRPCAddress(cfg *config.Config) string {
+	if !cfg.GetBool("grpc.enabled") {
+		return ""
+	}
+	return cfg.GetStringDefault("grpc.address", config.GRPCAddress)
+}
+
+// getTracerProvider returns the OpenTelemetry tracer provider if the tracing is enabled
+func getTracerProvider(cfg *config.Config) (trace.TracerProvider, error) {
+	if !cfg.GetBool("tracing.enabled") {
+		return noop.NewTracerProvider(), nil
+	}
+	return tracing.NewTracerProvider(context.Background(), &models.TracingOptions{
+		Exporter:    models.TracingExporter(cfg.GetStringDefault("tracing.exporter", config.TracingExporter)),
+		Endpoint:    cfg.GetString("tracing.endpoint"),
+		Insecure:    cfg.GetBool("tracing.insecure"),
+		ServiceName: cfg.GetStringDefault("tracing.service_name", config.TracingServiceName),
+		SampleRatio: cfg.GetFloat64("tracing.sample_ratio"),
+	})
+}
+
+// getFallbackOptions returns the fallback flags served when an environment can not be loaded or is in panic mode
+func getFallbackOptions(cfg *config.Config) (*models.FallbackOptions, error) {
+	configFlags := []struct {
+		Key       string      `mapstructure:"key"`
+		Type      string      `mapstructure:"type"`
+		Value     interface{} `mapstructure:"value"`
+		Targeting []struct {
+			Key      string      `mapstructure:"key"`
+			Operator string      `mapstructure:"operator"`
+			Value    interface{} `mapstructure:"value"`
+		} `mapstructure:"targeting"`
+	}{}
+	if err := cfg.UnmarshalKey("fallback.flags", &configFlags); err != nil {
+		return nil, err
+	}
+
+	options := &models.FallbackOptions{}
+	for _, flag := range configFlags {
+		fallbackFlag := &models.FallbackFlag{
+			Key:   flag.Key,
+			Type:  flag.Type,
+			Value: flag.Value,
+		}
+		for _, t := range flag.Targeting {
+			fallbackFlag.Targeting = append(fallbackFlag.Targeting, &models.FallbackTargeting{
+				Key:      t.Key,
+				Operator: t.Operator,
+				Value:    t.Value,
+			})
+		}
+		options.Flags = append(options.Flags, fallbackFlag)
+	}
+	return options, nil
+}
+
+// getContextSchemaOptions returns the schema validating the visitor contexts
+func getContextSchemaOptions(cfg *config.Config) (*models.ContextSchemaOptions, error) {
+	options := &models.ContextSchemaOptions{
+		Mode:   cfg.GetStringDefault("context_schema.mode", config.ContextSchemaMode),
+		Strict: cfg.GetBool("context_schema.strict"),
+	}
+	configFields := []struct {
+		Key      string        `mapstructure:"key"`
+		Type     string        `mapstructure:"type"`
+		Enum     []interface{} `mapstructure:"enum"`
+		Min      *float64      `mapstructure:"min"`
+		Max      *float64      `mapstructure:"max"`
+		Required bool          `mapstructure:"required"`
+	}{}
+	if err := cfg.UnmarshalKey("context_schema.fields", &configFields); err != nil {
+		return nil, err
+	}
+	for _, field := range configFields {
+		options.Fields = append(options.Fields, &models.ContextField{
+			Key:      field.Key,
+			Type:     field.Type,
+			Enum:     field.Enum,
+			Min:      field.Min,
+			Max:      field.Max,
+			Required: field.Required,
+		})
+	}
+	return options, nil
+}
+
+// getContextEnrichmentOptions returns the visitor context keys derived from the decision requests
+func getContextEnrichmentOptions(cfg *config.Config) (*models.ContextEnrichmentOptions, error) {
+	location, err := time.LoadLocation(cfg.GetStringDefault("context_enrichment.timezone", config.ContextEnrichmentTimezone))
+	if err != nil {
+		return nil, err
+	}
+	return &models.ContextEnrichmentOptions{
+		Keys:              cfg.GetStringSlice("context_enrichment.keys"),
+		Location:          location,
+		TrustForwardedFor: cfg.GetBool("context_enrichment.trust_forwarded_for"),
+	}, nil
+}
+
+// getOverridesManager returns the manager of the local overrides file, watched for changes if set
+func getOverridesManager(cfg *config.Config, log *logger.Logger) (*overrides.Manager, error) {
+	manager, err := overrides.NewManager(cfg.GetString("overrides.file"), log)
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.Watch(); err != nil {
+		return nil, fmt.Errorf("error when watching overrides file: %v", err)
+	}
+	return manager, nil
+}
+
 func createServer(cfg *config.Config, log *logger.Logger) (*server.Server, error) {
 	logLvl := cfg.GetStringDefault("log.level", config.LoggerLevel)
 	logFmt := cfg.GetStringDefault("log.format", config.LoggerFormat)
@@ -36,9 +181,53 @@ func createServer(cfg *config.Config, log *logger.Logger) (*server.Server, error
 		log.Fatalf("error occurred when initializing assignment cache manager: %v", err)
 	}
 
-	return server.CreateServer(
-		cfg.GetString("env_id"),
-		cfg.GetString("api_key"),
+	environments, err := getEnvironments(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider, err := getTracerProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitOptions, err := getRateLimitOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitStore, err := getRateLimitStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	overridesManager, err := getOverridesManager(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackOptions, err := getFallbackOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	contextSchemaOptions, err := getContextSchemaOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	contextEnrichmentOptions, err := getContextEnrichmentOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	contextProvider, err := getContextProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return server.CreateMultiEnvironmentServer(
+		environments,
 		cfg.GetString("address"),
 		server.WithLogger(log),
 		server.WithEnvironmentLoader(
@@ -46,18 +235,76 @@ func createServer(cfg *config.Config, log *logger.Logger) (*server.Server, error
 				environment_loaders.WithLogger(logLvl, logger.LogFormat(logFmt)),
 				environment_loaders.WithPollingInterval(cfg.GetDuration("polling_interval"))),
 		),
-		server.WithHitsProcessor(hits_processors.NewDataCollectProcessor(hits_processors.WithLogger(logLvl, logger.LogFormat(logFmt)))),
+		server.WithHitsProcessor(hits_processors.NewDataCollectProcessor(
+			hits_processors.WithLogger(logLvl, logger.LogFormat(logFmt)),
+			hits_processors.WithBatchOptions(
+				cfg.GetIntDefault("hits.batch_size", config.HitsBatchSize),
+				cfg.GetDurationDefault("hits.batching_window", config.HitsBatchingWindow)))),
 		server.WithAssignmentsManager(assignmentManager),
-		server.WithCorsOptions(&models.CorsOptions{
-			Enabled:        cfg.GetBool("cors.enabled"),
-			AllowedOrigins: cfg.GetStringDefault("cors.allowed_origins", config.ServerCorsAllowedOrigins),
-			AllowedHeaders: cfg.GetStringDefault("cors.allowed_headers", config.ServerCorsAllowedHeaders),
+		server.WithContextProvider(contextProvider),
+		server.WithContextProviderOptions(&models.ContextProviderOptions{
+			CacheTTL:                cfg.GetDurationDefault("context_provider.cache_ttl", config.ContextProviderCacheTTL),
+			Timeout:                 cfg.GetDurationDefault("context_provider.timeout", config.ContextProviderTimeout),
+			CircuitBreakerThreshold: cfg.GetIntDefault("context_provider.circuit_breaker.threshold", config.ContextProviderCircuitBreakerThreshold),
+			CircuitBreakerCooldown:  cfg.GetDurationDefault("context_provider.circuit_breaker.cooldown", config.ContextProviderCircuitBreakerCooldown),
+		}),
+		server.WithCorsOptions(getCorsOptions(cfg)),
+		server.WithAuthOptions(&models.AuthOptions{
+			Enabled:        cfg.GetBool("auth.enabled"),
+			APIKeys:        cfg.GetStringSlice("auth.api_keys"),
+			ExemptedRoutes: cfg.GetStringSlice("auth.exempted_routes"),
+		}),
+		server.WithTLSOptions(&models.TLSOptions{
+			CertFile:     cfg.GetStringDefault("tls.cert_file", ""),
+			KeyFile:      cfg.GetStringDefault("tls.key_file", ""),
+			ClientCAFile: cfg.GetStringDefault("tls.client_ca_file", ""),
+			MinVersion:   cfg.GetStringDefault("tls.min_version", config.TLSMinVersion),
+		}),
+		server.WithHealthOptions(&models.HealthOptions{
+			StalenessThreshold: cfg.GetDurationDefault("health.staleness_threshold", config.HealthStalenessThreshold),
+			Timeout:            cfg.GetDurationDefault("health.timeout", config.HealthTimeout),
+			RequireAllEnvs:     cfg.GetBool("health.require_all_environments"),
+		}),
+		server.WithRateLimitOptions(rateLimitOptions),
+		server.WithRateLimitStore(rateLimitStore),
+		server.WithAdminOptions(&models.AdminOptions{
+			Enabled:           cfg.GetBool("admin.enabled"),
+			Token:             cfg.GetString("admin.token"),
+			AllowedIPs:        cfg.GetStringSlice("admin.allowed_ips"),
+			TrustForwardedFor: cfg.GetBool("admin.trust_forwarded_for"),
+			TrustedProxies:    cfg.GetStringSlice("admin.trusted_proxies"),
+		}),
+		server.WithFlagsStreamOptions(&models.FlagsStreamOptions{
+			MaxSubscribers:    cfg.GetIntDefault("flags_stream.max_subscribers", config.FlagsStreamMaxSubscribers),
+			HeartbeatInterval: cfg.GetDurationDefault("flags_stream.heartbeat_interval", config.FlagsStreamHeartbeatInterval),
+		}),
+		server.WithBatchOptions(&models.BatchOptions{
+			MaxSize:     cfg.GetIntDefault("batch.max_size", config.BatchMaxSize),
+			Concurrency: cfg.GetIntDefault("batch.concurrency", config.BatchConcurrency),
+		}),
+		server.WithHTTPCacheOptions(&models.HTTPCacheOptions{
+			MaxAge: cfg.GetDurationDefault("http_cache.max_age", config.HTTPCacheMaxAge),
+		}),
+		server.WithForcedVariationsOptions(&models.ForcedVariationsOptions{
+			Secret:      cfg.GetString("forced_variations.secret"),
+			MaxLifetime: cfg.GetDurationDefault("forced_variations.max_lifetime", config.ForcedVariationsMaxLifetime),
 		}),
+		server.WithOverrides(overridesManager),
+		server.WithFallbackOptions(fallbackOptions),
+		server.WithContextSchemaOptions(contextSchemaOptions),
+		server.WithContextEnrichmentOptions(contextEnrichmentOptions),
+		server.WithTracerProvider(tracerProvider),
+		server.WithGRPCAddress(getGRPCAddress(cfg)),
 	)
 }
 
 func main() {
-	cfgFilename := flag.String("config", "config.yaml", "Path the configuration file")
+	// the CONFIG_FILE environment variable sets the default path, so that the container health check reads the same file
+	defaultCfgFilename := "config.yaml"
+	if filename := os.Getenv("CONFIG_FILE"); filename != "" {
+		defaultCfgFilename = filename
+	}
+	cfgFilename := flag.String("config", defaultCfgFilename, "Path the configuration file")
 	flag.Parse()
 
 	cfg, errCfg := config.NewFromFilename(*cfgFilename)
@@ -67,6 +314,14 @@ func main() {
 		logger.Warn(errCfg)
 	}
 
+	if flag.Arg(0) == "healthcheck" {
+		if err := healthcheck(cfg); err != nil {
+			logger.Errorf("healthcheck failed: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	srv, err := createServer(cfg, logger)
 	if err != nil {
 		logger.Fatalf("error when creating server: %v", err)
@@ -80,6 +335,22 @@ func main() {
 		}
 	}()
 
+	reloader := &configReloader{
+		filename: *cfgFilename,
+		cfg:      cfg,
+		server:   srv,
+		logger:   logger,
+	}
+	reloads := make(chan struct{}, 1)
+	if cfg.GetBool("watch_config") {
+		watcher, err := watchConfigFile(*cfgFilename, reloads, logger)
+		if err != nil {
+			logger.Errorf("error when watching configuration file: %v", err)
+		} else {
+			defer watcher.Close()
+		}
+	}
+
 	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel,
 		syscall.SIGHUP,
@@ -87,10 +358,24 @@ func main() {
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
-	<-signalChannel
+	// SIGHUP reloads the configuration, the other signals stop the server
+waitSignal:
+	for {
+		select {
+		case sig := <-signalChannel:
+			if sig != syscall.SIGHUP {
+				break waitSignal
+			}
+			logger.Info("SIGHUP received, reloading configuration")
+			_ = reloader.reload()
+		case <-reloads:
+			logger.Info("configuration file changed, reloading configuration")
+			_ = reloader.reload()
+		}
+	}
 
 	// Try to gracefully shutdown the server
-	ctx, cancelFunc := context.WithTimeout(context.Background(), shutdownTimeout)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), reloader.cfg.GetDurationDefault("drain_timeout", config.ServerDrainTimeout))
 	defer cancelFunc()
 	srv.Shutdown(ctx)
 }
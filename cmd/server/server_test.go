@@ -6,10 +6,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestCreateLogger(t *testing.T) {
@@ -35,6 +38,109 @@ func TestCreateServer(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestGetTracerProvider(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	provider, err := getTracerProvider(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, noop.TracerProvider{}, provider)
+
+	cfg.Set("tracing.enabled", true)
+	cfg.Set("tracing.exporter", "stdout")
+	provider, err = getTracerProvider(cfg)
+	assert.Nil(t, err)
+	assert.IsType(t, &sdktrace.TracerProvider{}, provider)
+
+	cfg.Set("tracing.exporter", "unknown")
+	_, err = getTracerProvider(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestGetEnvironments(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	environments, err := getEnvironments(cfg)
+	assert.Nil(t, err)
+	assert.Len(t, environments, 0)
+
+	cfg.Set("env_id", "env_id")
+	cfg.Set("api_key", "api_key")
+	environments, err = getEnvironments(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.EnvironmentCredentials{{EnvID: "env_id", APIKey: "api_key"}}, environments)
+
+	cfg.Set("environments", []map[string]interface{}{
+		{"env_id": "env_id_1", "api_key": "api_key_1"},
+		{"env_id": "env_id_2", "api_key": "api_key_2"},
+	})
+	environments, err = getEnvironments(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+		{EnvID: "env_id_2", APIKey: "api_key_2"},
+	}, environments)
+}
+
+func TestGetFallbackOptions(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	options, err := getFallbackOptions(cfg)
+	assert.Nil(t, err)
+	assert.Empty(t, options.Flags)
+
+	cfg.Set("fallback.flags", []map[string]interface{}{
+		{"key": "btnColor", "type": "string", "value": "blue", "targeting": []map[string]interface{}{
+			{"key": "plan", "operator": "equals", "value": "premium"},
+		}},
+		{"key": "btnColor", "value": "red"},
+	})
+	options, err = getFallbackOptions(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, &models.FallbackOptions{Flags: []*models.FallbackFlag{
+		{Key: "btnColor", Type: "string", Value: "blue", Targeting: []*models.FallbackTargeting{{Key: "plan", Operator: "equals", Value: "premium"}}},
+		{Key: "btnColor", Value: "red"},
+	}}, options)
+}
+
+func TestGetContextSchemaOptions(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	options, err := getContextSchemaOptions(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, config.ContextSchemaMode, options.Mode)
+	assert.Empty(t, options.Fields)
+
+	cfg.Set("context_schema.mode", models.ContextSchemaModeReject)
+	cfg.Set("context_schema.fields", []map[string]interface{}{
+		{"key": "age", "type": "number", "min": 0, "max": 120},
+		{"key": "plan", "type": "string", "enum": []interface{}{"free", "premium"}, "required": true},
+	})
+	options, err = getContextSchemaOptions(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ContextSchemaModeReject, options.Mode)
+	assert.Len(t, options.Fields, 2)
+	assert.Equal(t, 120., *options.Fields[0].Max)
+	assert.Nil(t, options.Fields[1].Min)
+	assert.Equal(t, []interface{}{"free", "premium"}, options.Fields[1].Enum)
+	assert.True(t, options.Fields[1].Required)
+}
+
+func TestGetContextEnrichmentOptions(t *testing.T) {
+	cfg, _ := config.NewFromFilename("test")
+	options, err := getContextEnrichmentOptions(cfg)
+	assert.Nil(t, err)
+	assert.Empty(t, options.Keys)
+	assert.Equal(t, time.UTC, options.Location)
+	assert.True(t, options.TrustForwardedFor)
+
+	cfg.Set("context_enrichment.keys", []string{models.ContextKeyDeviceType, models.ContextKeyHour})
+	cfg.Set("context_enrichment.timezone", "Europe/Paris")
+	options, err = getContextEnrichmentOptions(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{models.ContextKeyDeviceType, models.ContextKeyHour}, options.Keys)
+	assert.Equal(t, "Europe/Paris", options.Location.String())
+
+	cfg.Set("context_enrichment.timezone", "Mars/Olympus")
+	_, err = getContextEnrichmentOptions(cfg)
+	assert.NotNil(t, err)
+}
+
 func TestMain(t *testing.T) {
 	os.Setenv("API_KEY", "api_key")
 	os.Setenv("ENV_ID", "env_id")
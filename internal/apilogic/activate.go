@@ -0,0 +1,190 @@
+package apilogic
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/validation"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	decision "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/activate_request"
+	"github.com/flagship-io/flagship-proto/decision_response"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ActivateCampaigns validates the activate requests, persists the activated assignments if needed and tracks the campaign activations.
+// It returns an *Error if one of the activate requests is invalid
+func ActivateCampaigns(context *connectors.DecisionContext, activateItems []*activate_request.ActivateRequest) error {
+	// error management & campaign activations
+	errorsLength := 0
+	errors := make(chan error)
+	campaignActivations := []*models.CampaignActivation{}
+
+	for _, activateItem := range activateItems {
+		if bodyErr := validation.CheckErrorBody(context.EnvID, activateItem); bodyErr != nil {
+			data, _ := json.Marshal(bodyErr)
+			return &Error{Status: http.StatusBadRequest, Message: string(data)}
+		}
+	}
+
+	for _, activateItem := range activateItems {
+		now := time.Now()
+
+		visitorID := activateItem.Vid
+		// If anonymous id is defined
+		if activateItem.Aid != nil {
+			visitorID = activateItem.Aid.Value
+		}
+
+		shouldPersistActivation := false
+		environment, err := context.EnvironmentLoader.LoadEnvironment(activateItem.Cid, context.APIKey)
+		if err != nil {
+			log.Printf("Error when reading existing environment : %v", err)
+		} else {
+			shouldPersistActivation = environment.Common.CacheEnabled && environment.Common.SingleAssignment
+		}
+
+		if shouldPersistActivation {
+			existingAssignments, err := context.AssignmentsManager.LoadAssignments(activateItem.Cid, activateItem.Vid)
+			if err != nil {
+				log.Printf("Error when reading existing assignments : %v", err)
+			}
+
+			var vgAssign *decision.VisitorCache
+			if existingAssignments != nil {
+				vgAssign = existingAssignments.Assignments[activateItem.Caid]
+			}
+
+			shouldPersistActivation = vgAssign == nil || !vgAssign.Activated || vgAssign.VariationID != activateItem.Vaid
+		}
+
+		if shouldPersistActivation {
+			errorsLength++
+			go func(activateItem *activate_request.ActivateRequest) {
+				var err error = nil
+				if context.AssignmentsManager.ShouldSaveAssignments(connectors.SaveAssignmentsContext{
+					AssignmentScope: connectors.Activation,
+				}) {
+					err = context.AssignmentsManager.SaveAssignments(context.EnvID, activateItem.Vid, map[string]*decision.VisitorCache{
+						activateItem.Caid: {
+							VariationID: activateItem.Vaid,
+							Activated:   true,
+						},
+					}, now)
+				}
+				errors <- err
+			}(activateItem)
+		}
+
+		campaignActivations = append(campaignActivations, &models.CampaignActivation{
+			EnvID:           activateItem.Cid,
+			VisitorID:       visitorID,
+			CustomerID:      activateItem.Vid,
+			CampaignID:      activateItem.Caid,
+			VariationID:     activateItem.Vaid,
+			Timestamp:       now.UnixNano() / 1000000,
+			PersistActivate: shouldPersistActivation,
+			QA:              activateItem.Qa,
+			QueueTime:       activateItem.Qt,
+		})
+	}
+
+	errorsLength++
+	go func() {
+		errors <- context.HitsProcessor.TrackHits(
+			connectors.TrackingHits{
+				CampaignActivations: campaignActivations,
+			})
+
+	}()
+
+	var err error
+	for i := 0; i < errorsLength; i++ {
+		if e := <-errors; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// FlagActivation is the campaign decision providing a flag key. Campaign is nil if the visitor has no flag with this key
+type FlagActivation struct {
+	Key      string
+	Campaign *decision_response.Campaign
+}
+
+// ActivateFlags resolves the campaigns and variations providing the flag keys with the visitor decision, which reuses
+// the cached assignments, and activates them with ActivateCampaigns. The decision itself does not trigger hits,
+// and no flag is activated in panic mode. The forced variations are activated as QA activations, without being persisted
+func ActivateFlags(handleRequest *handle.Request, decisionContext *connectors.DecisionContext, keys []string, tracker *decision.Tracker) ([]*FlagActivation, error) {
+	handleRequest.DecisionRequest.TriggerHit = wrapperspb.Bool(false)
+	// keys without value in the assigned variation, as in reference variations, still resolve to their campaign
+	handleRequest.ExposeAllKeys = true
+	if err := ComputeCampaigns(handleRequest, decisionContext, tracker); err != nil {
+		return nil, err
+	}
+
+	flagActivations := make([]*FlagActivation, len(keys))
+	for i, key := range keys {
+		flagActivations[i] = &FlagActivation{Key: key}
+	}
+	if handleRequest.Environment.Common.IsPanic {
+		return flagActivations, nil
+	}
+
+	// the last campaign providing a key wins, as in the flags response
+	flagCampaigns := map[string]*decision_response.Campaign{}
+	for _, campaign := range handleRequest.DecisionResponse.GetCampaigns() {
+		for key := range campaign.GetVariation().GetModifications().GetValue().GetFields() {
+			flagCampaigns[key] = campaign
+		}
+	}
+
+	activateItems := []*activate_request.ActivateRequest{}
+	forcedCampaigns := []*decision_response.Campaign{}
+	activatedVariationGroups := map[string]bool{}
+	for _, flagActivation := range flagActivations {
+		campaign, ok := flagCampaigns[flagActivation.Key]
+		if !ok {
+			continue
+		}
+		flagActivation.Campaign = campaign
+
+		variationGroupID := campaign.GetVariationGroupId().GetValue()
+		if activatedVariationGroups[variationGroupID] {
+			continue
+		}
+		activatedVariationGroups[variationGroupID] = true
+
+		if handleRequest.IsForced(campaign) {
+			forcedCampaigns = append(forcedCampaigns, campaign)
+			continue
+		}
+
+		activateItem := &activate_request.ActivateRequest{
+			Cid:  decisionContext.EnvID,
+			Vid:  handleRequest.DecisionRequest.GetVisitorId().GetValue(),
+			Caid: variationGroupID,
+			Vaid: campaign.GetVariation().GetId().GetValue(),
+		}
+		if handleRequest.DecisionRequest.GetAnonymousId().GetValue() != "" {
+			activateItem.Aid = handleRequest.DecisionRequest.GetAnonymousId()
+		}
+		activateItems = append(activateItems, activateItem)
+	}
+
+	if len(forcedCampaigns) > 0 {
+		if err := handle.TrackForcedActivations(handleRequest, forcedCampaigns); err != nil {
+			return flagActivations, err
+		}
+	}
+	if len(activateItems) == 0 {
+		return flagActivations, nil
+	}
+	return flagActivations, ActivateCampaigns(decisionContext, activateItems)
+}
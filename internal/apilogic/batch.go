@@ -0,0 +1,136 @@
+package apilogic
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/decision_request"
+)
+
+// BatchDecision is the decision result of a request of a batch. Err is set if the decision failed
+type BatchDecision struct {
+	Index         int
+	HandleRequest *handle.Request
+	Err           error
+}
+
+// batchHitsProcessor collects the hits of the batch decisions
+type batchHitsProcessor struct {
+	connectors.HitsProcessor
+	lock *sync.Mutex
+	hits connectors.TrackingHits
+}
+
+func (p *batchHitsProcessor) TrackHits(hits connectors.TrackingHits) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.hits.CampaignActivations = append(p.hits.CampaignActivations, hits.CampaignActivations...)
+	p.hits.VisitorContext = append(p.hits.VisitorContext, hits.VisitorContext...)
+	return nil
+}
+
+// batchAssignmentsManager collects the assignments saved by the batch decisions, merged by environment and visitor
+type batchAssignmentsManager struct {
+	connectors.AssignmentsManager
+	lock        *sync.Mutex
+	envIDs      []string
+	assignments map[string][]*connectors.VisitorAssignmentsSave
+	visitors    map[string]*connectors.VisitorAssignmentsSave
+}
+
+func (m *batchAssignmentsManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := envID + "." + visitorID
+	visitor, ok := m.visitors[key]
+	if !ok {
+		visitor = &connectors.VisitorAssignmentsSave{
+			VisitorID:   visitorID,
+			Assignments: map[string]*common.VisitorCache{},
+		}
+		m.visitors[key] = visitor
+		if _, ok := m.assignments[envID]; !ok {
+			m.envIDs = append(m.envIDs, envID)
+		}
+		m.assignments[envID] = append(m.assignments[envID], visitor)
+	}
+	for vgID, assignment := range vgIDAssignments {
+		visitor.Assignments[vgID] = assignment
+	}
+	visitor.Date = date
+	return nil
+}
+
+// HandleBatch computes the decisions of the batch requests, running up to concurrency decisions at the same time.
+// The forced variations token of the X-Forced-Variations header applies to every decision of the batch.
+// handleDecision is called from the calling goroutine as each decision completes, so results can be streamed.
+// The hits and assignments of all the decisions are then sent to the connectors in bulk
+func HandleBatch(req *http.Request, decisionContext *connectors.DecisionContext, requests []*decision_request.DecisionRequest, concurrency int, handleDecision func(*BatchDecision)) {
+	hitsProcessor := &batchHitsProcessor{
+		HitsProcessor: decisionContext.HitsProcessor,
+		lock:          &sync.Mutex{},
+	}
+	assignmentsManager := &batchAssignmentsManager{
+		AssignmentsManager: decisionContext.AssignmentsManager,
+		lock:               &sync.Mutex{},
+		assignments:        map[string][]*connectors.VisitorAssignmentsSave{},
+		visitors:           map[string]*connectors.VisitorAssignmentsSave{},
+	}
+	batchContext := *decisionContext
+	batchContext.HitsProcessor = hitsProcessor
+	batchContext.AssignmentsManager = assignmentsManager
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	metadata := NewRequestMetadataFromHTTP(req)
+	forcedVariationsToken := req.Header.Get(utils.ForcedVariationsHeader)
+	decisions := make(chan *BatchDecision)
+	go func() {
+		wg := &sync.WaitGroup{}
+		for i, decisionRequest := range requests {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(index int, decisionRequest *decision_request.DecisionRequest) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				handleRequest := NewHandleRequestFromHTTP(req, decisionRequest)
+				// the campaign ID of the batch path is not a campaign filter
+				handleRequest.CampaignID = ""
+				err := PrepareHandleRequest(handleRequest, forcedVariationsToken, metadata, &batchContext)
+				if err == nil {
+					err = ComputeCampaigns(handleRequest, &batchContext, utils.NewTracker())
+				}
+				decisions <- &BatchDecision{Index: index, HandleRequest: handleRequest, Err: err}
+			}(i, decisionRequest)
+		}
+		wg.Wait()
+		close(decisions)
+	}()
+
+	for decision := range decisions {
+		handleDecision(decision)
+	}
+
+	if len(hitsProcessor.hits.CampaignActivations) > 0 || len(hitsProcessor.hits.VisitorContext) > 0 {
+		decisionContext.Logger.Infof("sending %d batch campaign activations and %d visitor contexts to hits processor",
+			len(hitsProcessor.hits.CampaignActivations), len(hitsProcessor.hits.VisitorContext))
+		if err := decisionContext.HitsProcessor.TrackHits(hitsProcessor.hits); err != nil {
+			decisionContext.Logger.Errorf("error when tracking batch hits: %v", err)
+		}
+	}
+
+	for _, envID := range assignmentsManager.envIDs {
+		assignments := assignmentsManager.assignments[envID]
+		decisionContext.Logger.Infof("saving assignments of %d batch visitors", len(assignments))
+		if err := connectors.SaveAssignmentsBulk(decisionContext.AssignmentsManager, envID, assignments); err != nil {
+			decisionContext.Logger.Errorf("error when saving batch assignments: %v", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package apilogic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/qa"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type countingHitsProcessor struct {
+	connectors.HitsProcessor
+	lock  sync.Mutex
+	calls int
+	hits  connectors.TrackingHits
+}
+
+func (p *countingHitsProcessor) TrackHits(hits connectors.TrackingHits) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.calls++
+	p.hits = hits
+	return nil
+}
+
+type bulkAssignmentsManager struct {
+	*assignments_managers.MemoryManager
+	bulks int
+	saves []*connectors.VisitorAssignmentsSave
+}
+
+func (m *bulkAssignmentsManager) SaveAssignmentsBulk(envID string, assignments []*connectors.VisitorAssignmentsSave) error {
+	m.bulks++
+	m.saves = assignments
+	return nil
+}
+
+func TestHandleBatch(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.CacheEnabled = true
+	hitsProcessor := &countingHitsProcessor{}
+	assignmentsManager := &bulkAssignmentsManager{MemoryManager: assignments_managers.InitMemoryManager()}
+	decisionContext.HitsProcessor = hitsProcessor
+	decisionContext.AssignmentsManager = assignmentsManager
+
+	requests := []*decision_request.DecisionRequest{}
+	for _, visitorID := range []string{"visitor_1", "visitor_2", "visitor_3"} {
+		requests = append(requests, &decision_request.DecisionRequest{
+			VisitorId: wrapperspb.String(visitorID),
+			Context:   map[string]*structpb.Value{"key": structpb.NewStringValue("value")},
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch", nil)
+	decisions := map[int]*BatchDecision{}
+	HandleBatch(req, decisionContext, requests, 1, func(decision *BatchDecision) {
+		decisions[decision.Index] = decision
+	})
+
+	assert.Len(t, decisions, 3)
+	for i, decision := range decisions {
+		assert.Nil(t, decision.Err)
+		assert.Equal(t, requests[i].VisitorId.Value, decision.HandleRequest.DecisionResponse.VisitorId.Value)
+		// the path of the batch is not a campaign ID
+		assert.Equal(t, "", decision.HandleRequest.CampaignID)
+	}
+
+	// hits and assignments are sent in bulk
+	assert.Equal(t, 1, hitsProcessor.calls)
+	assert.Len(t, hitsProcessor.hits.VisitorContext, 3)
+	assert.NotEmpty(t, hitsProcessor.hits.CampaignActivations)
+	assert.Equal(t, 1, assignmentsManager.bulks)
+	assert.Len(t, assignmentsManager.saves, 3)
+}
+
+func TestHandleBatchForcedVariations(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+	decisionContext.ForcedVariationsOptions = &models.ForcedVariationsOptions{Secret: "secret"}
+	token, err := qa.SignForcedVariations("secret", &qa.ForcedVariations{
+		Variations: map[string]string{"campaign_1": "v_2"},
+		VisitorID:  "visitor_1",
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Nil(t, err)
+
+	requests := []*decision_request.DecisionRequest{
+		{VisitorId: wrapperspb.String("visitor_1"), TriggerHit: wrapperspb.Bool(false)},
+		{VisitorId: wrapperspb.String("visitor_2"), TriggerHit: wrapperspb.Bool(false)},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch", nil)
+	req.Header.Set(utils.ForcedVariationsHeader, token)
+	decisions := map[int]*BatchDecision{}
+	HandleBatch(req, decisionContext, requests, 1, func(decision *BatchDecision) {
+		decisions[decision.Index] = decision
+	})
+
+	assert.Nil(t, decisions[0].Err)
+	assert.Equal(t, map[string]string{"campaign_1": "v_2"}, decisions[0].HandleRequest.ForcedVariations)
+	var apiErr *Error
+	assert.ErrorAs(t, decisions[1].Err, &apiErr)
+	assert.Equal(t, qa.ErrVisitorMismatch.Error(), apiErr.Message)
+}
+
+func TestBatchAssignmentsManager(t *testing.T) {
+	manager := &batchAssignmentsManager{
+		lock:        &sync.Mutex{},
+		assignments: map[string][]*connectors.VisitorAssignmentsSave{},
+		visitors:    map[string]*connectors.VisitorAssignmentsSave{},
+	}
+	now := time.Now()
+	assert.Nil(t, manager.SaveAssignments("env_1", "visitor_id", map[string]*common.VisitorCache{"vg_1": {VariationID: "v_1"}}, now))
+	assert.Nil(t, manager.SaveAssignments("env_1", "visitor_id", map[string]*common.VisitorCache{"vg_2": {VariationID: "v_2"}}, now))
+	assert.Nil(t, manager.SaveAssignments("env_2", "visitor_id", map[string]*common.VisitorCache{"vg_1": {VariationID: "v_3"}}, now))
+
+	// the assignments of a visitor are merged by environment
+	assert.Equal(t, []string{"env_1", "env_2"}, manager.envIDs)
+	assert.Len(t, manager.assignments["env_1"], 1)
+	assert.Len(t, manager.assignments["env_1"][0].Assignments, 2)
+	assert.Len(t, manager.assignments["env_2"], 1)
+	assert.Equal(t, "v_3", manager.assignments["env_2"][0].Assignments["vg_1"].VariationID)
+}
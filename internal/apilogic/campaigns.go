@@ -1,6 +1,7 @@
 package apilogic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,35 +11,137 @@ import (
 	"github.com/flagship-io/decision-api/internal/utils"
 	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/qa"
+	"github.com/flagship-io/decision-api/pkg/utils/tracing"
 	common "github.com/flagship-io/flagship-common"
 	"github.com/flagship-io/flagship-common/targeting"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // HandleCampaigns get campaigns from request, add checks and side effect and return response
 func HandleCampaigns(w http.ResponseWriter, req *http.Request, decisionContext *connectors.DecisionContext, handleDecision func(http.ResponseWriter, *handle.Request, error), tracker *common.Tracker) {
-	handleRequest, err := BuildHandleRequest(req)
+	HandleCampaignsWithFallback(w, req, decisionContext, handleDecision, nil, tracker)
+}
+
+// HandleCampaignsWithFallback handles the campaigns like HandleCampaigns, but serves the fallback flags of the decision context
+// with the fallback handler when the environment can not be loaded or is in panic mode
+func HandleCampaignsWithFallback(w http.ResponseWriter, req *http.Request, decisionContext *connectors.DecisionContext, handleDecision func(http.ResponseWriter, *handle.Request, error), handleFallback FallbackHandler, tracker *common.Tracker) {
+	handleRequest, err := BuildHandleRequest(req, decisionContext)
 	if err != nil {
-		utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+		utils.WriteRequestError(w, err)
 		return
 	}
 
+	err = ComputeCampaigns(handleRequest, decisionContext, tracker)
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Status >= http.StatusInternalServerError {
+			if writeFallback(w, handleRequest, decisionContext, handleFallback) {
+				return
+			}
+			utils.WriteServerError(w, apiErr)
+			return
+		}
+		utils.WriteClientError(w, apiErr.Status, apiErr.Message)
+		return
+	}
+
+	// Return panic response is panic mode activated
+	if handleRequest.Environment.Common.IsPanic {
+		if writeFallback(w, handleRequest, decisionContext, handleFallback) {
+			return
+		}
+		utils.WritePanicResponse(w, handleRequest.DecisionRequest.VisitorId)
+		return
+	}
+
+	handleDecision(w, handleRequest, err)
+}
+
+// writeFallback writes the fallback flags with the fallback handler, if any fallback flag is configured
+func writeFallback(w http.ResponseWriter, handleRequest *handle.Request, decisionContext *connectors.DecisionContext, handleFallback FallbackHandler) bool {
+	if handleFallback == nil {
+		return false
+	}
+	flags, ok := fallbackFlags(handleRequest, decisionContext)
+	if !ok {
+		return false
+	}
+	return handleFallback(w, handleRequest, flags)
+}
+
+// fallbackFlags returns the fallback flags of the request, and false if no fallback flag is configured
+func fallbackFlags(handleRequest *handle.Request, decisionContext *connectors.DecisionContext) (map[string]interface{}, bool) {
+	if decisionContext.FallbackOptions == nil || len(decisionContext.FallbackOptions.Flags) == 0 {
+		return nil, false
+	}
+	handleRequest.Logger.Warnf("serving fallback flags of environment %s", decisionContext.EnvID)
+	return FallbackFlags(handleRequest, decisionContext.FallbackOptions), true
+}
+
+// DecisionFallbackFlags returns the fallback flags to serve instead of the result of ComputeCampaigns, as HandleCampaignsWithFallback does:
+// when the environment can not be loaded or is in panic mode, and fallback flags are configured.
+// It returns false if the decision or its error must be served
+func DecisionFallbackFlags(handleRequest *handle.Request, decisionContext *connectors.DecisionContext, err error) (map[string]interface{}, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Status < http.StatusInternalServerError {
+			return nil, false
+		}
+	} else if !handleRequest.Environment.Common.IsPanic {
+		return nil, false
+	}
+	return fallbackFlags(handleRequest, decisionContext)
+}
+
+// ComputeCampaigns loads the environment of the decision context and computes the campaigns decision for the handle request.
+// It returns an *Error if the environment or the requested campaign can not be loaded.
+// If the environment is in panic mode, no decision is computed.
+// The decision trace is added to the response extras if the request has the decisionTrace extra
+func ComputeCampaigns(handleRequest *handle.Request, decisionContext *connectors.DecisionContext, tracker *common.Tracker) error {
+	if !handleRequest.HasExtra(DecisionTraceExtra) {
+		return computeCampaigns(handleRequest, decisionContext, tracker)
+	}
+
+	trace, err := ExplainCampaigns(handleRequest, decisionContext, false, tracker)
+	if err != nil || handleRequest.Environment.Common.IsPanic {
+		return err
+	}
+	if err := addJSONExtra(handleRequest, DecisionTraceExtra, trace); err != nil {
+		handleRequest.Logger.Warnf("error when adding decision trace to extras: %v", err)
+	}
+	return nil
+}
+
+func computeCampaigns(handleRequest *handle.Request, decisionContext *connectors.DecisionContext, tracker *common.Tracker) error {
+	var err error
 	handleRequest.DecisionContext = decisionContext
 	handleRequest.Logger = decisionContext.Logger
 
 	// 1. Get environment info from environment ID & API Key
 	tracker.TimeTrack("start get env info from env loader")
 	handleRequest.Logger.Infof("loading environment id: %s", handleRequest.DecisionContext.EnvID)
+	_, span := tracing.StartSpan(handleRequest.Ctx, decisionContext.Tracer, "EnvironmentLoader.LoadEnvironment",
+		attribute.String("env_id", decisionContext.EnvID))
 	handleRequest.Environment, err = decisionContext.EnvironmentLoader.LoadEnvironment(handleRequest.DecisionContext.EnvID, handleRequest.DecisionContext.APIKey)
+	tracing.EndSpan(span, err)
 	tracker.TimeTrack("end get env info from env loader")
 
 	if err != nil {
 		if errors.Is(err, models.ErrEnvironmentNotFound) {
-			utils.WriteClientError(w, http.StatusBadRequest, fmt.Sprintf("environment %s not found", handleRequest.DecisionContext.EnvID))
-			return
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("environment %s not found", handleRequest.DecisionContext.EnvID)}
 		}
-		utils.WriteServerError(w, err)
-		return
+		return &Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	// 1b. Apply the local overrides on top of the loaded environment
+	var overrides *models.Overrides
+	appliedOverrides := &AppliedOverrides{}
+	if decisionContext.Overrides != nil {
+		overrides = decisionContext.Overrides.Overrides()
+		appliedOverrides = applyEnvironmentOverrides(handleRequest, overrides)
 	}
 
 	// 2. Checks that optional campaign ID exists
@@ -53,27 +156,40 @@ func HandleCampaigns(w http.ResponseWriter, req *http.Request, decisionContext *
 		}
 
 		if len(filteredCampaigns) == 0 {
-			utils.WriteClientError(w, http.StatusBadRequest, fmt.Sprintf("The campaign %s is paused or doesn’t exist. Verify your customId or campaignId.", handleRequest.CampaignID))
-			return
+			return &Error{Status: http.StatusBadRequest, Message: fmt.Sprintf("The campaign %s is paused or doesn’t exist. Verify your customId or campaignId.", handleRequest.CampaignID)}
 		}
 		handleRequest.Environment.Common.Campaigns = filteredCampaigns
 	}
 
-	// 3. Return panic response is panic mode activated
+	// 3. Do not compute decision if panic mode activated
 	if handleRequest.Environment.Common.IsPanic {
-		utils.WritePanicResponse(w, handleRequest.DecisionRequest.VisitorId)
-		return
+		return nil
 	}
 
-	// 4 Get context keys from integration service (if needed)
-	if handleRequest.Environment.HasIntegrations {
-		tracker.TimeTrack("start get visitor context from integration service")
+	// 3b. Verify the variations forced to QA testers
+	if handleRequest.ForcedVariationsToken != "" {
+		options := decisionContext.ForcedVariationsOptions
+		if options == nil {
+			options = &models.ForcedVariationsOptions{}
+		}
+		forcedVariations, err := qa.VerifyForcedVariations(options.Secret, handleRequest.ForcedVariationsToken, handleRequest.DecisionRequest.GetVisitorId().GetValue(), handleRequest.Time, options.MaxLifetime)
+		if err != nil {
+			return &Error{Status: http.StatusForbidden, Message: err.Error()}
+		}
+		handleRequest.ForcedVariations = forcedVariations.Variations
+	}
+
+	// 4 Get context keys from the context provider (if set)
+	if decisionContext.ContextProvider != nil {
+		tracker.TimeTrack("start get visitor context from context provider")
 		decisionContext.Logger.Info("filling integration visitor context")
-		err := fillVisitorContext(handleRequest)
+		ctx, span := tracing.StartSpan(handleRequest.Ctx, decisionContext.Tracer, "ContextProvider.LoadVisitorContext")
+		err := fillVisitorContext(ctx, handleRequest, decisionContext.ContextProvider)
+		tracing.EndSpan(span, err)
 		if err != nil {
 			handleRequest.DecisionContext.Logger.Warnf("error occurred when getting integration visitor context: %v", err)
 		}
-		tracker.TimeTrack("end get visitor context from integration service")
+		tracker.TimeTrack("end get visitor context from context provider")
 	}
 
 	wg := &sync.WaitGroup{}
@@ -102,26 +218,46 @@ func HandleCampaigns(w http.ResponseWriter, req *http.Request, decisionContext *
 		if err != nil {
 			handleRequest.Logger.Warnf("error when computing decision: %v", err)
 		}
-		handleDecision(w, handleRequest, err)
 		tracker.TimeTrack("end compute campaigns request logic")
 	}()
 
 	wg.Wait()
+
+	if handleRequest.DecisionResponse != nil {
+		if overrides != nil {
+			applyFlagOverrides(handleRequest, overrides, appliedOverrides)
+		}
+		if !appliedOverrides.isEmpty() {
+			if err := addJSONExtra(handleRequest, OverridesExtra, appliedOverrides); err != nil {
+				handleRequest.Logger.Warnf("error when adding overrides to extras: %v", err)
+			}
+		}
+	}
+
+	return err
 }
 
-func fillVisitorContext(request *handle.Request) error {
-	data, err := utils.FetchVisitorData(request.DecisionContext.EnvID, request.DecisionRequest.VisitorId.Value)
+// fillVisitorContext adds the visitor context of the integration providers to the full visitor context
+func fillVisitorContext(ctx context.Context, request *handle.Request, provider connectors.ContextProvider) error {
+	providersContext, err := provider.LoadVisitorContext(ctx, request.DecisionContext.EnvID, request.DecisionRequest.GetVisitorId().GetValue())
 	if err != nil {
 		return err
 	}
 
-	request.Logger.Infof("got integration context for %d providers", len(data))
-	for _, row := range data {
-		if _, ok := request.FullVisitorContext.IntegrationProviders[row.Partner]; !ok {
-			request.FullVisitorContext.IntegrationProviders[row.Partner] = targeting.ContextMap{}
+	request.Logger.Infof("got integration context for %d providers", len(providersContext))
+	for partner, values := range providersContext {
+		if _, ok := request.FullVisitorContext.IntegrationProviders[partner]; !ok {
+			request.FullVisitorContext.IntegrationProviders[partner] = targeting.ContextMap{}
 		}
 
-		request.FullVisitorContext.IntegrationProviders[row.Partner][row.Segment] = structpb.NewStringValue(row.Value)
+		for key, value := range values {
+			contextValue, err := structpb.NewValue(value)
+			if err != nil {
+				request.Logger.Warnf("invalid value of context key %s of provider %s: %v", key, partner, err)
+				continue
+			}
+			request.FullVisitorContext.IntegrationProviders[partner][key] = contextValue
+		}
 	}
 
 	return nil
@@ -0,0 +1,227 @@
+package apilogic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
+	"github.com/flagship-io/decision-api/pkg/utils/qa"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestComputeCampaignsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.CacheEnabled = true
+	decisionContext.Tracer = tracer
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	handleRequest := NewHandleRequest(&decision_request.DecisionRequest{
+		VisitorId: wrapperspb.String("visitor_id"),
+		Context:   map[string]*structpb.Value{"key": structpb.NewStringValue("value")},
+	})
+	handleRequest.Ctx = ctx
+
+	err := ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	parent.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+
+	for _, name := range []string{"EnvironmentLoader.LoadEnvironment", "handle.Decision", "HitsProcessor.TrackHits"} {
+		span, ok := spans[name]
+		assert.True(t, ok, name)
+		if ok {
+			assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+		}
+	}
+	for _, attr := range spans["handle.Decision"].Attributes() {
+		assert.NotEqual(t, "visitor_id", string(attr.Key))
+	}
+	assert.Contains(t, spans, "AssignmentsManager.LoadAssignments")
+	if span, ok := spans["AssignmentsManager.LoadAssignments"]; ok {
+		assert.Equal(t, spans["handle.Decision"].SpanContext().SpanID(), span.Parent().SpanID())
+	}
+}
+
+func TestComputeCampaignsForcedVariations(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.CacheEnabled = true
+	hitsProcessor := decisionContext.HitsProcessor.(*hits_processors.MockHitProcessor)
+
+	token, err := qa.SignForcedVariations("secret", &qa.ForcedVariations{
+		Variations: map[string]string{"campaign_1": "v_2"},
+		VisitorID:  "visitor_id",
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Nil(t, err)
+	newRequest := func(token string) *handle.Request {
+		handleRequest := NewHandleRequest(&decision_request.DecisionRequest{
+			VisitorId: wrapperspb.String("visitor_id"),
+		})
+		handleRequest.ForcedVariationsToken = token
+		handleRequest.SendContextEvent = false
+		return handleRequest
+	}
+
+	// forced variations are rejected without the secret or with an invalid signature
+	var apiErr *Error
+	err = ComputeCampaigns(newRequest(token), decisionContext, utils.NewTracker())
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.Status)
+
+	decisionContext.ForcedVariationsOptions = &models.ForcedVariationsOptions{Secret: "other_secret"}
+	err = ComputeCampaigns(newRequest(token), decisionContext, utils.NewTracker())
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, qa.ErrInvalidSignature.Error(), apiErr.Message)
+
+	decisionContext.ForcedVariationsOptions = &models.ForcedVariationsOptions{Secret: "secret"}
+	handleRequest := newRequest(token)
+	handleRequest.Extras = []string{DecisionTraceExtra}
+	err = ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"campaign_1": "v_2"}, handleRequest.ForcedVariations)
+	assert.Equal(t, "v_2", handleRequest.DecisionResponse.Campaigns[0].GetVariation().GetId().GetValue())
+	assert.Len(t, hitsProcessor.TrackedHits.CampaignActivations, 1)
+	assert.True(t, hitsProcessor.TrackedHits.CampaignActivations[0].QA)
+
+	trace, err := ExplainCampaigns(newRequest(token), decisionContext, true, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Equal(t, TraceReasonForced, trace.Campaigns[0].Reason)
+	assert.Equal(t, "v_2", trace.Campaigns[0].VariationID)
+
+	// the forced flags are activated as QA activations, without persisting the assignment
+	hitsProcessor.TrackedHits.CampaignActivations = nil
+	flagActivations, err := ActivateFlags(newRequest(token), decisionContext, []string{"testString"}, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Equal(t, "campaign_1", flagActivations[0].Campaign.GetId().GetValue())
+	assert.Len(t, hitsProcessor.TrackedHits.CampaignActivations, 1)
+	assert.True(t, hitsProcessor.TrackedHits.CampaignActivations[0].QA)
+	assert.Equal(t, "v_2", hitsProcessor.TrackedHits.CampaignActivations[0].VariationID)
+	assignments, err := decisionContext.AssignmentsManager.LoadAssignments(environment.Common.ID, "visitor_id")
+	assert.Nil(t, err)
+	assert.NotNil(t, assignments.Assignments["vg_1"])
+	assert.Nil(t, assignments.Assignments["vg_2"])
+}
+
+func TestComputeCampaignsOverrides(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.CacheEnabled = true
+	environment.Common.Campaigns[0].VariationGroups[0].Variations[1].Reference = true
+
+	manager, err := overrides.NewManager("", decisionContext.Logger)
+	assert.Nil(t, err)
+	decisionContext.Overrides = manager
+	_, err = manager.Update(func(o *models.Overrides) {
+		o.Flags = map[string]interface{}{"testString": "overridden", "unknown": true}
+		o.PausedCampaigns = []string{"image"}
+		o.ReferenceCampaigns = []string{"campaign_1"}
+	})
+	assert.Nil(t, err)
+
+	handleRequest := NewHandleRequest(&decision_request.DecisionRequest{
+		VisitorId: wrapperspb.String("visitor_id"),
+	})
+	handleRequest.SendContextEvent = false
+	err = ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+
+	campaigns := handleRequest.DecisionResponse.Campaigns
+	assert.Len(t, campaigns, 1)
+	assert.Equal(t, "campaign_1", campaigns[0].GetId().GetValue())
+	assert.Equal(t, "v_2", campaigns[0].GetVariation().GetId().GetValue())
+	assert.Equal(t, "overridden", campaigns[0].GetVariation().GetModifications().GetValue().GetFields()["testString"].GetStringValue())
+
+	// the loaded environment is left untouched
+	assert.Len(t, environment.Common.Campaigns, 3)
+	assert.Len(t, environment.Common.Campaigns[0].VariationGroups[0].Variations, 2)
+	assert.Equal(t, "string", environment.Common.Campaigns[0].VariationGroups[0].Variations[0].Modifications.Value.Fields["testString"].GetStringValue())
+
+	extra := &structpb.Struct{}
+	assert.Nil(t, handleRequest.DecisionResponse.Extras[OverridesExtra].UnmarshalTo(extra))
+	assert.Equal(t, map[string]interface{}{
+		"flags":              []interface{}{"testString"},
+		"pausedCampaigns":    []interface{}{"image"},
+		"referenceCampaigns": []interface{}{"campaign_1"},
+	}, extra.AsMap())
+
+	// the pinned reference variation is not saved as the visitor assignment
+	assignments, err := decisionContext.AssignmentsManager.LoadAssignments(environment.Common.ID, "visitor_id")
+	assert.Nil(t, err)
+	assert.Nil(t, assignments)
+
+	// the panic mode computes no decision
+	_, err = manager.Update(func(o *models.Overrides) {
+		*o = models.Overrides{Panic: true}
+	})
+	assert.Nil(t, err)
+	handleRequest = NewHandleRequest(&decision_request.DecisionRequest{
+		VisitorId: wrapperspb.String("visitor_id"),
+	})
+	err = ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.True(t, handleRequest.Environment.Common.IsPanic)
+	assert.Nil(t, handleRequest.DecisionResponse)
+	assert.False(t, environment.Common.IsPanic)
+}
+
+type mockContextProvider struct {
+	context connectors.ProvidersContext
+	err     error
+}
+
+func (p *mockContextProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	return p.context, p.err
+}
+
+func TestComputeCampaignsContextProvider(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	newRequest := func() *handle.Request {
+		handleRequest := NewHandleRequest(&decision_request.DecisionRequest{
+			VisitorId: wrapperspb.String("visitor_id"),
+		})
+		handleRequest.SendContextEvent = false
+		return handleRequest
+	}
+
+	decisionContext.ContextProvider = &mockContextProvider{context: connectors.ProvidersContext{
+		"crm": {"plan": "premium", "age": 30., "invalid": struct{}{}},
+	}}
+	handleRequest := newRequest()
+	err := ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"plan": "premium", "age": 30.}, (&structpb.Struct{Fields: handleRequest.FullVisitorContext.IntegrationProviders["crm"]}).AsMap())
+
+	// the decision is computed without the integration context if the provider fails
+	decisionContext.ContextProvider = &mockContextProvider{err: errors.New("unavailable")}
+	handleRequest = newRequest()
+	err = ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.NotNil(t, handleRequest.DecisionResponse)
+	assert.Empty(t, handleRequest.FullVisitorContext.IntegrationProviders)
+}
@@ -0,0 +1,118 @@
+package apilogic
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/internal/utils/useragent"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// RequestMetadata is the transport metadata of a decision request from which the enriched context keys are derived
+type RequestMetadata struct {
+	UserAgent      string
+	AcceptLanguage string
+	ForwardedFor   string
+	RemoteAddr     string
+}
+
+// NewRequestMetadataFromHTTP returns the metadata of an HTTP decision request
+func NewRequestMetadataFromHTTP(req *http.Request) RequestMetadata {
+	return RequestMetadata{
+		UserAgent:      req.UserAgent(),
+		AcceptLanguage: req.Header.Get("Accept-Language"),
+		ForwardedFor:   strings.Join(req.Header.Values("X-Forwarded-For"), ","),
+		RemoteAddr:     req.RemoteAddr,
+	}
+}
+
+// EnrichContext adds the enabled context keys derived from the request metadata and the request time to the visitor
+// context of the decision. The client values take precedence, and the derived keys are not sent with the visitor context hits
+func EnrichContext(metadata RequestMetadata, handleRequest *handle.Request, options *models.ContextEnrichmentOptions) {
+	if options == nil || len(options.Keys) == 0 {
+		return
+	}
+
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	now := handleRequest.Time.In(location)
+	var ua *useragent.UserAgent
+	values := map[string]*structpb.Value{}
+	for _, key := range options.Keys {
+		if _, ok := handleRequest.FullVisitorContext.Standard[key]; ok {
+			continue
+		}
+		if ua == nil && (key == models.ContextKeyDeviceType || key == models.ContextKeyOS || key == models.ContextKeyBrowser) {
+			parsed := useragent.Parse(metadata.UserAgent)
+			ua = &parsed
+		}
+
+		var value string
+		switch key {
+		case models.ContextKeyDeviceType:
+			value = ua.DeviceType
+		case models.ContextKeyOS:
+			value = ua.OS
+		case models.ContextKeyBrowser:
+			value = ua.Browser
+		case models.ContextKeyLanguage:
+			value = preferredLanguage(metadata.AcceptLanguage)
+		case models.ContextKeyIP:
+			value = utils.ForwardedClientIP(metadata.ForwardedFor, metadata.RemoteAddr, options.TrustForwardedFor, nil)
+		case models.ContextKeyHour:
+			values[key] = structpb.NewNumberValue(float64(now.Hour()))
+		case models.ContextKeyDayOfWeek:
+			// ISO day of week, from 1 for monday to 7 for sunday
+			values[key] = structpb.NewNumberValue(float64((int(now.Weekday())+6)%7 + 1))
+		}
+		if value != "" {
+			values[key] = structpb.NewStringValue(value)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	standard := make(map[string]*structpb.Value, len(handleRequest.FullVisitorContext.Standard)+len(values))
+	for key, value := range handleRequest.FullVisitorContext.Standard {
+		standard[key] = value
+	}
+	for key, value := range values {
+		standard[key] = value
+	}
+	handleRequest.FullVisitorContext.Standard = standard
+}
+
+// preferredLanguage returns the primary language subtag of the Accept-Language language with the highest quality
+func preferredLanguage(header string) string {
+	language := ""
+	bestQuality := 0.
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > bestQuality {
+			primary, _, _ := strings.Cut(tag, "-")
+			language = strings.ToLower(primary)
+			bestQuality = quality
+		}
+	}
+	return language
+}
@@ -0,0 +1,11 @@
+package apilogic
+
+// Error represents an error of the decision pipeline with the HTTP status to answer with
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
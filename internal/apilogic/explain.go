@@ -0,0 +1,380 @@
+package apilogic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-common/targeting"
+	protoTargeting "github.com/flagship-io/flagship-proto/targeting"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// DecisionTraceExtra is the extra of the campaigns request adding the decision trace to the response extras
+const DecisionTraceExtra = "decisionTrace"
+
+// Reasons of the campaigns traces
+const (
+	TraceReasonAssigned            = "assigned"
+	TraceReasonForced              = "forced"
+	TraceReasonTargetingNotMatched = "targeting_not_matched"
+	TraceReasonSingleAssignment    = "single_assignment"
+	TraceReasonNotInBucket         = "not_in_bucket"
+	TraceReasonDeletedVariation    = "deleted_variation"
+	TraceReasonNotAllocated        = "not_allocated"
+)
+
+// DecisionTrace explains the decision of a visitor for each campaign of the environment
+type DecisionTrace struct {
+	VisitorID        string                            `json:"visitorId"`
+	AnonymousID      string                            `json:"anonymousId,omitempty"`
+	DecisionGroup    string                            `json:"decisionGroup,omitempty"`
+	Panic            bool                              `json:"panic"`
+	SingleAssignment bool                              `json:"singleAssignment"`
+	CacheLoaded      bool                              `json:"cacheLoaded"`
+	Context          map[string]interface{}            `json:"context"`
+	ProvidersContext map[string]map[string]interface{} `json:"providersContext,omitempty"`
+	Campaigns        []*CampaignTrace                  `json:"campaigns"`
+}
+
+// CampaignTrace explains the decision of a campaign: its targeted variation group, whether the visitor is in its buckets and the assigned variation
+type CampaignTrace struct {
+	ID                string                 `json:"id"`
+	Name              string                 `json:"name"`
+	Slug              *string                `json:"slug,omitempty"`
+	Type              string                 `json:"type"`
+	InBucketRanges    bool                   `json:"inBucketRanges"`
+	VariationGroupID  string                 `json:"variationGroupId,omitempty"`
+	VariationID       string                 `json:"variationId,omitempty"`
+	FromCache         bool                   `json:"fromCache"`
+	Reason            string                 `json:"reason"`
+	VariationGroups   []*VariationGroupTrace `json:"variationGroups"`
+	targetedVG        *common.VariationGroup
+	cachedVariationID string
+}
+
+// VariationGroupTrace explains the targeting and the allocation of a variation group
+type VariationGroupTrace struct {
+	ID                    string                 `json:"id"`
+	Name                  string                 `json:"name"`
+	Matched               bool                   `json:"matched"`
+	TargetingGroups       []*TargetingGroupTrace `json:"targetingGroups"`
+	AllocatedVariationID  string                 `json:"allocatedVariationId,omitempty"`
+	CachedVariationID     string                 `json:"cachedVariationId,omitempty"`
+	CachedVariationExists bool                   `json:"cachedVariationExists,omitempty"`
+}
+
+// TargetingGroupTrace explains a targeting group, which matches if all its targetings match
+type TargetingGroupTrace struct {
+	Matched    bool              `json:"matched"`
+	Targetings []*TargetingTrace `json:"targetings"`
+}
+
+// TargetingTrace explains a targeting condition with the context or provider value it was evaluated with
+type TargetingTrace struct {
+	Key          string      `json:"key"`
+	Provider     string      `json:"provider,omitempty"`
+	Operator     string      `json:"operator"`
+	Value        interface{} `json:"value"`
+	ContextValue interface{} `json:"contextValue"`
+	ContextFound bool        `json:"contextFound"`
+	Matched      bool        `json:"matched"`
+}
+
+// traceAssignmentsManager records the assignments loaded by the decision. A read only manager does not save assignments
+type traceAssignmentsManager struct {
+	connectors.AssignmentsManager
+	readOnly bool
+	lock     sync.Mutex
+	loaded   map[string]*common.VisitorAssignments
+}
+
+func (m *traceAssignmentsManager) ShouldSaveAssignments(context connectors.SaveAssignmentsContext) bool {
+	return !m.readOnly && m.AssignmentsManager.ShouldSaveAssignments(context)
+}
+
+func (m *traceAssignmentsManager) LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error) {
+	assignments, err := m.AssignmentsManager.LoadAssignments(envID, visitorID)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.loaded[visitorID] = assignments
+	return assignments, err
+}
+
+func (m *traceAssignmentsManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	if m.readOnly {
+		return nil
+	}
+	return m.AssignmentsManager.SaveAssignments(envID, visitorID, vgIDAssignments, date)
+}
+
+// ExplainCampaigns computes the campaigns decision of the handle request like ComputeCampaigns, and explains it.
+// The explanation does not trigger hits nor save assignments if dryRun is true
+func ExplainCampaigns(handleRequest *handle.Request, decisionContext *connectors.DecisionContext, dryRun bool, tracker *common.Tracker) (*DecisionTrace, error) {
+	assignmentsManager := &traceAssignmentsManager{
+		AssignmentsManager: decisionContext.AssignmentsManager,
+		readOnly:           dryRun,
+		loaded:             map[string]*common.VisitorAssignments{},
+	}
+	traceContext := *decisionContext
+	traceContext.AssignmentsManager = assignmentsManager
+	if dryRun {
+		handleRequest.SendContextEvent = false
+		handleRequest.DecisionRequest.TriggerHit = wrapperspb.Bool(false)
+	}
+
+	if err := computeCampaigns(handleRequest, &traceContext, tracker); err != nil {
+		return nil, err
+	}
+	handleRequest.DecisionContext = decisionContext
+
+	return buildDecisionTrace(handleRequest, assignmentsManager.loaded), nil
+}
+
+// addJSONExtra adds the value, marshaled as a JSON object, to the extras of the decision response
+func addJSONExtra(handleRequest *handle.Request, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	valueStruct := &structpb.Struct{}
+	if err := valueStruct.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	extra, err := anypb.New(valueStruct)
+	if err != nil {
+		return err
+	}
+	if handleRequest.DecisionResponse.Extras == nil {
+		handleRequest.DecisionResponse.Extras = map[string]*anypb.Any{}
+	}
+	handleRequest.DecisionResponse.Extras[key] = extra
+	return nil
+}
+
+func buildDecisionTrace(handleRequest *handle.Request, loadedAssignments map[string]*common.VisitorAssignments) *DecisionTrace {
+	environment := handleRequest.Environment.Common
+	visitorContext := handleRequest.FullVisitorContext
+	trace := &DecisionTrace{
+		VisitorID:        handleRequest.DecisionRequest.GetVisitorId().GetValue(),
+		AnonymousID:      handleRequest.DecisionRequest.GetAnonymousId().GetValue(),
+		DecisionGroup:    handleRequest.DecisionRequest.GetDecisionGroup().GetValue(),
+		Panic:            environment.IsPanic,
+		SingleAssignment: environment.SingleAssignment,
+		CacheLoaded:      len(loadedAssignments) > 0,
+		Context:          contextMapInterface(visitorContext.Standard),
+		Campaigns:        []*CampaignTrace{},
+	}
+	for provider, contextMap := range visitorContext.IntegrationProviders {
+		if trace.ProvidersContext == nil {
+			trace.ProvidersContext = map[string]map[string]interface{}{}
+		}
+		trace.ProvidersContext[provider] = contextMapInterface(contextMap)
+	}
+	if environment.IsPanic {
+		return trace
+	}
+
+	// the decision loads the assignments of the encoded decision group first
+	encodedDecisionGroup := ""
+	if trace.DecisionGroup != "" {
+		encodedDecisionGroup = base64.StdEncoding.EncodeToString([]byte(trace.DecisionGroup))
+	}
+	cachedVariation := func(vgID string) (string, bool) {
+		for _, id := range []string{encodedDecisionGroup, trace.VisitorID, trace.AnonymousID} {
+			if assignments := loadedAssignments[id]; id != "" && assignments != nil && assignments.Assignments[vgID] != nil {
+				return assignments.Assignments[vgID].VariationID, true
+			}
+		}
+		return "", false
+	}
+
+	assignedVariations := map[string]string{}
+	forcedVariationGroups := map[string]string{}
+	hasAssignedAB := false
+	for _, campaign := range handleRequest.DecisionResponse.GetCampaigns() {
+		if handleRequest.IsForced(campaign) {
+			forcedVariationGroups[campaign.GetId().GetValue()] = campaign.GetVariationGroupId().GetValue()
+			continue
+		}
+		assignedVariations[campaign.GetVariationGroupId().GetValue()] = campaign.GetVariation().GetId().GetValue()
+		hasAssignedAB = hasAssignedAB || campaign.GetType().GetValue() == "ab"
+	}
+
+	seen := map[string]bool{}
+	for _, campaign := range environment.Campaigns {
+		if seen[campaign.ID] {
+			continue
+		}
+		seen[campaign.ID] = true
+
+		campaignTrace := &CampaignTrace{
+			ID:              campaign.ID,
+			Name:            campaign.Name,
+			Slug:            campaign.Slug,
+			Type:            campaign.Type,
+			InBucketRanges:  inBucketRanges(campaign, trace.VisitorID),
+			VariationGroups: []*VariationGroupTrace{},
+		}
+
+		for _, vg := range campaign.VariationGroups {
+			vgTrace := traceVariationGroup(vg, trace.VisitorID, trace.DecisionGroup, visitorContext)
+			vgTrace.CachedVariationID, _ = cachedVariation(vg.ID)
+			for _, variation := range vg.Variations {
+				vgTrace.CachedVariationExists = vgTrace.CachedVariationExists || variation.ID == vgTrace.CachedVariationID
+			}
+			if vgTrace.Matched && campaignTrace.targetedVG == nil {
+				campaignTrace.targetedVG = vg
+				campaignTrace.VariationGroupID = vg.ID
+				campaignTrace.cachedVariationID = vgTrace.CachedVariationID
+			}
+			campaignTrace.VariationGroups = append(campaignTrace.VariationGroups, vgTrace)
+		}
+
+		variationID, assigned := assignedVariations[campaignTrace.VariationGroupID]
+		switch {
+		case forcedVariationGroups[campaign.ID] != "":
+			campaignTrace.Reason = TraceReasonForced
+			campaignTrace.VariationGroupID = forcedVariationGroups[campaign.ID]
+			campaignTrace.VariationID = handleRequest.ForcedVariations[campaign.ID]
+		case campaignTrace.targetedVG == nil:
+			campaignTrace.Reason = TraceReasonTargetingNotMatched
+		case assigned:
+			campaignTrace.Reason = TraceReasonAssigned
+			campaignTrace.VariationID = variationID
+			campaignTrace.FromCache = campaignTrace.cachedVariationID == variationID
+		case environment.SingleAssignment && campaign.Type == "ab" && hasAssignedAB:
+			campaignTrace.Reason = TraceReasonSingleAssignment
+		case !campaignTrace.InBucketRanges:
+			campaignTrace.Reason = TraceReasonNotInBucket
+		case campaignTrace.cachedVariationID != "":
+			campaignTrace.Reason = TraceReasonDeletedVariation
+		default:
+			campaignTrace.Reason = TraceReasonNotAllocated
+		}
+		trace.Campaigns = append(trace.Campaigns, campaignTrace)
+	}
+	return trace
+}
+
+// traceVariationGroup explains the targeting of a variation group and the allocation of the visitor or its decision group
+func traceVariationGroup(vg *common.VariationGroup, visitorID string, decisionGroup string, visitorContext *targeting.Context) *VariationGroupTrace {
+	vgTrace := &VariationGroupTrace{
+		ID:              vg.ID,
+		Name:            vg.Name,
+		Matched:         targetingMatch(vg.Targetings, visitorID, visitorContext),
+		TargetingGroups: []*TargetingGroupTrace{},
+	}
+
+	for _, targetingGroup := range vg.Targetings.GetTargetingGroups() {
+		groupTrace := &TargetingGroupTrace{
+			Matched:    targetingMatch(&protoTargeting.Targeting{TargetingGroups: []*protoTargeting.Targeting_TargetingGroup{targetingGroup}}, visitorID, visitorContext),
+			Targetings: []*TargetingTrace{},
+		}
+		for _, t := range targetingGroup.GetTargetings() {
+			contextValue, found := visitorContext.GetValueByProvider(t.GetKey().GetValue(), t.GetProvider().GetValue())
+			if t.GetKey().GetValue() == "fs_users" {
+				contextValue, found = structpb.NewStringValue(visitorID), true
+			}
+			groupTrace.Targetings = append(groupTrace.Targetings, &TargetingTrace{
+				Key:          t.GetKey().GetValue(),
+				Provider:     t.GetProvider().GetValue(),
+				Operator:     t.GetOperator().String(),
+				Value:        t.GetValue().AsInterface(),
+				ContextValue: contextValue.AsInterface(),
+				ContextFound: found,
+				Matched: targetingMatch(&protoTargeting.Targeting{TargetingGroups: []*protoTargeting.Targeting_TargetingGroup{
+					{Targetings: []*protoTargeting.Targeting_InnerTargeting{t}},
+				}}, visitorID, visitorContext),
+			})
+		}
+		vgTrace.TargetingGroups = append(vgTrace.TargetingGroups, groupTrace)
+	}
+
+	vgTrace.AllocatedVariationID = allocatedVariationID(vg, visitorID, decisionGroup)
+	return vgTrace
+}
+
+// targetingMatch evaluates a targeting with the decision library, on a probe campaign that only holds this targeting
+func targetingMatch(targetings *protoTargeting.Targeting, visitorID string, visitorContext *targeting.Context) bool {
+	disableBucketAllocation := false
+	probe := &common.Campaign{
+		ID: "probe",
+		VariationGroups: []*common.VariationGroup{{
+			ID:         "probe",
+			Targetings: targetings,
+			Variations: []*common.Variation{{ID: "probe", Allocation: 100}},
+		}},
+	}
+	response, err := common.GetDecision(
+		common.Visitor{ID: visitorID, Context: visitorContext},
+		common.Environment{ID: "probe", Campaigns: []*common.Campaign{probe}},
+		common.DecisionOptions{EnableBucketAllocation: &disableBucketAllocation},
+		common.DecisionHandlers{})
+	return err == nil && len(response.GetCampaigns()) > 0
+}
+
+// inBucketRanges returns whether the visitor ID is in the bucket ranges of the campaign, as computed by the decision library
+func inBucketRanges(campaign *common.Campaign, visitorID string) bool {
+	probe := &common.Campaign{
+		ID:           "probe",
+		BucketRanges: campaign.BucketRanges,
+		VariationGroups: []*common.VariationGroup{{
+			ID:         "probe",
+			Targetings: allUsersTargeting,
+			Variations: []*common.Variation{{ID: "probe", Allocation: 100}},
+		}},
+	}
+	return probeDecision(probe, common.Visitor{ID: visitorID, Context: &targeting.Context{}}, true) != ""
+}
+
+// allocatedVariationID returns the ID of the variation allocated by the decision library to the visitor or its decision group,
+// or an empty string if the visitor is untracked
+func allocatedVariationID(vg *common.VariationGroup, visitorID string, decisionGroup string) string {
+	probe := &common.Campaign{
+		ID: "probe",
+		VariationGroups: []*common.VariationGroup{{
+			ID:         vg.ID,
+			Targetings: allUsersTargeting,
+			Variations: vg.Variations,
+		}},
+	}
+	return probeDecision(probe, common.Visitor{ID: visitorID, DecisionGroup: decisionGroup, Context: &targeting.Context{}}, false)
+}
+
+// allUsersTargeting matches every visitor, so that the probe decisions only depend on the buckets and the allocation
+var allUsersTargeting = &protoTargeting.Targeting{
+	TargetingGroups: []*protoTargeting.Targeting_TargetingGroup{{
+		Targetings: []*protoTargeting.Targeting_InnerTargeting{{
+			Operator: protoTargeting.Targeting_EQUALS,
+			Key:      wrapperspb.String("fs_all_users"),
+		}},
+	}},
+}
+
+// probeDecision runs the decision library on a probe campaign and returns the ID of the variation assigned to the visitor, if any
+func probeDecision(probe *common.Campaign, visitor common.Visitor, enableBucketAllocation bool) string {
+	response, err := common.GetDecision(
+		visitor,
+		common.Environment{ID: "probe", Campaigns: []*common.Campaign{probe}},
+		common.DecisionOptions{EnableBucketAllocation: &enableBucketAllocation},
+		common.DecisionHandlers{})
+	if err != nil || len(response.GetCampaigns()) == 0 {
+		return ""
+	}
+	return response.GetCampaigns()[0].GetVariation().GetId().GetValue()
+}
+
+func contextMapInterface(contextMap targeting.ContextMap) map[string]interface{} {
+	values := map[string]interface{}{}
+	for key, value := range contextMap {
+		values[key] = value.AsInterface()
+	}
+	return values
+}
@@ -0,0 +1,110 @@
+package apilogic
+
+import (
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestExplainCampaigns(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.CacheEnabled = true
+	hitsProcessor := decisionContext.HitsProcessor.(*hits_processors.MockHitProcessor)
+
+	newRequest := func() *decision_request.DecisionRequest {
+		return &decision_request.DecisionRequest{
+			VisitorId: wrapperspb.String("visitor_id"),
+			Context:   map[string]*structpb.Value{"age": structpb.NewNumberValue(30)},
+		}
+	}
+
+	// dry run explanations do not trigger hits nor save assignments
+	trace, err := ExplainCampaigns(NewHandleRequest(newRequest()), decisionContext, true, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_id", trace.VisitorID)
+	assert.True(t, trace.CacheLoaded)
+	assert.EqualValues(t, 30, trace.Context["age"])
+	assert.Len(t, trace.Campaigns, 3)
+	assert.Len(t, hitsProcessor.TrackedHits.CampaignActivations, 0)
+	assignments, err := decisionContext.AssignmentsManager.LoadAssignments("env_id_1", "visitor_id")
+	assert.Nil(t, err)
+	assert.Nil(t, assignments)
+
+	campaign := trace.Campaigns[0]
+	assert.Equal(t, "campaign_1", campaign.ID)
+	assert.Equal(t, TraceReasonAssigned, campaign.Reason)
+	assert.Equal(t, "vg_2", campaign.VariationGroupID)
+	assert.True(t, campaign.InBucketRanges)
+	assert.False(t, campaign.FromCache)
+	assert.True(t, campaign.VariationGroups[0].Matched)
+	assert.Equal(t, campaign.VariationID, campaign.VariationGroups[0].AllocatedVariationID)
+	assert.Equal(t, "fs_all_users", campaign.VariationGroups[0].TargetingGroups[0].Targetings[0].Key)
+
+	// the provider targeting fails without the provider context
+	campaign = trace.Campaigns[2]
+	assert.Equal(t, "campaign_2", campaign.ID)
+	assert.Equal(t, TraceReasonTargetingNotMatched, campaign.Reason)
+	targeting := campaign.VariationGroups[0].TargetingGroups[0].Targetings[0]
+	assert.Equal(t, "age", targeting.Key)
+	assert.Equal(t, "mixpanel", targeting.Provider)
+	assert.Equal(t, "GREATER_THAN", targeting.Operator)
+	assert.False(t, targeting.ContextFound)
+	assert.False(t, targeting.Matched)
+	assert.False(t, campaign.VariationGroups[0].TargetingGroups[0].Matched)
+
+	// the decision trace extra explains a real decision, whose assignments are then read from the cache
+	handleRequest := NewHandleRequest(newRequest())
+	handleRequest.Extras = []string{DecisionTraceExtra}
+	handleRequest.SendContextEvent = false
+	err = ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.Contains(t, handleRequest.DecisionResponse.Extras, DecisionTraceExtra)
+	assert.Len(t, hitsProcessor.TrackedHits.CampaignActivations, 2)
+
+	trace, err = ExplainCampaigns(NewHandleRequest(newRequest()), decisionContext, true, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.True(t, trace.Campaigns[0].FromCache)
+	assert.Equal(t, trace.Campaigns[0].VariationID, trace.Campaigns[0].VariationGroups[0].CachedVariationID)
+
+	// no campaign is explained in panic mode
+	environment.Common.IsPanic = true
+	trace, err = ExplainCampaigns(NewHandleRequest(newRequest()), decisionContext, true, utils.NewTracker())
+	assert.Nil(t, err)
+	assert.True(t, trace.Panic)
+	assert.Len(t, trace.Campaigns, 0)
+}
+
+func TestAllocatedVariationID(t *testing.T) {
+	vg := &common.VariationGroup{
+		ID: "vg_1",
+		Variations: []*common.Variation{
+			{ID: "v_1", Allocation: 50},
+			{ID: "v_2", Allocation: 50},
+		},
+	}
+
+	// the decision group is allocated instead of the visitor ID, as in the real decision
+	allocated := map[string]bool{}
+	for _, visitorID := range []string{"visitor_1", "visitor_2", "visitor_3", "visitor_4", "visitor_5", "visitor_6"} {
+		variationID := allocatedVariationID(vg, visitorID, "group")
+		assert.NotEmpty(t, variationID)
+		allocated[variationID] = true
+	}
+	assert.Len(t, allocated, 1)
+
+	// a visitor is untracked if the variations are not fully allocated
+	vg.Variations = []*common.Variation{{ID: "v_1", Allocation: 0}}
+	assert.Empty(t, allocatedVariationID(vg, "visitor_1", ""))
+
+	assert.True(t, inBucketRanges(&common.Campaign{BucketRanges: [][]float64{{0, 100}}}, "visitor_1"))
+	assert.False(t, inBucketRanges(&common.Campaign{BucketRanges: [][]float64{}}, "visitor_1"))
+}
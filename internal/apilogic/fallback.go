@@ -0,0 +1,55 @@
+package apilogic
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/pkg/models"
+	protoTargeting "github.com/flagship-io/flagship-proto/targeting"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// FallbackHandler writes the fallback flags of a request served without environment.
+// It returns false if the request can not be served with fallback flags
+type FallbackHandler func(w http.ResponseWriter, handleRequest *handle.Request, flags map[string]interface{}) bool
+
+// FallbackFlags returns the values of the fallback flags matching the visitor of the request
+func FallbackFlags(handleRequest *handle.Request, options *models.FallbackOptions) map[string]interface{} {
+	flags := map[string]interface{}{}
+	if options == nil {
+		return flags
+	}
+	for _, flag := range options.Flags {
+		if _, ok := flags[flag.Key]; ok {
+			continue
+		}
+		if len(flag.Targeting) > 0 && !fallbackTargetingMatch(handleRequest, flag.Targeting) {
+			continue
+		}
+		flags[flag.Key] = flag.Value
+	}
+	return flags
+}
+
+// fallbackTargetingMatch evaluates the fallback flag conditions as a single targeting group of the decision library
+func fallbackTargetingMatch(handleRequest *handle.Request, conditions []*models.FallbackTargeting) bool {
+	group := &protoTargeting.Targeting_TargetingGroup{}
+	for _, condition := range conditions {
+		value, err := structpb.NewValue(condition.Value)
+		if err != nil {
+			handleRequest.Logger.Warnf("invalid fallback targeting value of key %s: %v", condition.Key, err)
+			return false
+		}
+		group.Targetings = append(group.Targetings, &protoTargeting.Targeting_InnerTargeting{
+			Key:      wrapperspb.String(condition.Key),
+			Operator: protoTargeting.Targeting_TargetingOperator(protoTargeting.Targeting_TargetingOperator_value[strings.ToUpper(condition.Operator)]),
+			Value:    value,
+		})
+	}
+	return targetingMatch(
+		&protoTargeting.Targeting{TargetingGroups: []*protoTargeting.Targeting_TargetingGroup{group}},
+		handleRequest.DecisionRequest.GetVisitorId().GetValue(),
+		handleRequest.FullVisitorContext)
+}
@@ -3,21 +3,98 @@ package apilogic
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/flagship-io/decision-api/internal/handle"
 	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/internal/validation"
+	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/flagship-common/targeting"
+	"github.com/flagship-io/flagship-proto/decision_request"
 )
 
-// BuildHandleRequest builds a handle.Request object from the API Gateway request
-func BuildHandleRequest(req *http.Request) (*handle.Request, error) {
-	handleRequest := handle.NewRequestFromHTTP(req)
-	decisionRequest, err := utils.GetDecisionRequest(req)
+// NewHandleRequest builds a handle.Request object from a decision request, with the default request options
+func NewHandleRequest(decisionRequest *decision_request.DecisionRequest) *handle.Request {
+	hasVisitorConsented := decisionRequest.VisitorConsent == nil || decisionRequest.VisitorConsent.GetValue()
+	return &handle.Request{
+		Time:             time.Now(),
+		CampaignID:       decisionRequest.GetCampaignId().GetValue(),
+		Mode:             "normal",
+		Extras:           []string{},
+		SendContextEvent: hasVisitorConsented,
+		DecisionRequest:  decisionRequest,
+		FullVisitorContext: &targeting.Context{
+			Standard:             decisionRequest.GetContext(),
+			IntegrationProviders: map[string]targeting.ContextMap{},
+		},
+	}
+}
+
+// BuildHandleRequest builds a handle.Request object from the API Gateway request.
+// The visitor context is checked against the context schema of the decision context, if any, then enriched
+// with the context keys derived from the request
+func BuildHandleRequest(req *http.Request, decisionContext *connectors.DecisionContext) (*handle.Request, error) {
+	decisionRequest, forcedVariationsToken, err := utils.GetDecisionRequestAndForcedVariations(req)
 
 	if err != nil {
 		return nil, err
 	}
 
+	handleRequest := NewHandleRequestFromHTTP(req, decisionRequest)
+	if err := PrepareHandleRequest(handleRequest, forcedVariationsToken, NewRequestMetadataFromHTTP(req), decisionContext); err != nil {
+		return nil, err
+	}
+	return handleRequest, nil
+}
+
+// PrepareHandleRequest sets the forced variations token of the request, then checks and enriches its visitor context.
+// It is shared by the HTTP and gRPC decisions so that the same input gets the same decision on every transport
+func PrepareHandleRequest(handleRequest *handle.Request, forcedVariationsToken string, metadata RequestMetadata, decisionContext *connectors.DecisionContext) error {
+	handleRequest.ForcedVariationsToken = forcedVariationsToken
+	return PrepareVisitorContext(metadata, handleRequest, decisionContext)
+}
+
+// PrepareVisitorContext applies the context schema and the context enrichment of the decision context to the visitor context
+func PrepareVisitorContext(metadata RequestMetadata, handleRequest *handle.Request, decisionContext *connectors.DecisionContext) error {
+	if err := ApplyContextSchema(handleRequest, decisionContext); err != nil {
+		return err
+	}
+	EnrichContext(metadata, handleRequest, decisionContext.ContextEnrichmentOptions)
+	return nil
+}
+
+// ApplyContextSchema checks the visitor context against the context schema. In reject mode, the violations are returned
+// as a *validation.ErrorResponse. In coerce mode, the context of the decision request is replaced by the coerced one
+// and the violations are logged
+func ApplyContextSchema(handleRequest *handle.Request, decisionContext *connectors.DecisionContext) error {
+	if decisionContext.ContextSchema == nil {
+		return nil
+	}
+	context, violations := decisionContext.ContextSchema.Validate(handleRequest.DecisionRequest.GetContext())
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if decisionContext.ContextSchema.Reject() {
+		errors := map[string]string{}
+		for _, violation := range violations {
+			errors["context."+violation.Key] = violation.Message
+		}
+		return validation.BuildErrorResponse(errors)
+	}
+
+	for _, violation := range violations {
+		decisionContext.Logger.Warnf("visitor %s context key %s violates the context schema: %s",
+			handleRequest.DecisionRequest.GetVisitorId().GetValue(), violation.Key, violation.Message)
+	}
+	handleRequest.DecisionRequest.Context = context
+	handleRequest.FullVisitorContext.Standard = context
+	return nil
+}
+
+// NewHandleRequestFromHTTP builds a handle.Request object from a decision request, with the request options of the URL query
+func NewHandleRequestFromHTTP(req *http.Request, decisionRequest *decision_request.DecisionRequest) *handle.Request {
+	handleRequest := handle.NewRequestFromHTTP(req)
 	handleRequest.Mode = "normal"
 	mode := req.URL.Query().Get("mode")
 	if mode != "" {
@@ -43,5 +120,5 @@ func BuildHandleRequest(req *http.Request) (*handle.Request, error) {
 	}
 	handleRequest.Extras = req.URL.Query()["extras"]
 
-	return &handleRequest, nil
+	return &handleRequest
 }
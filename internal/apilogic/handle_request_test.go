@@ -3,11 +3,18 @@ package apilogic
 import (
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/internal/validation"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestBuildHandleRequestHasCorrectSendContextEvent(t *testing.T) {
@@ -42,7 +49,7 @@ func TestBuildHandleRequestHasCorrectSendContextEvent(t *testing.T) {
 				Method: "POST",
 			}
 
-			hr, err := BuildHandleRequest(req)
+			hr, err := BuildHandleRequest(req, utils.CreateMockDecisionContext())
 
 			assert.NotNil(t, hr)
 			assert.Nil(t, err)
@@ -81,7 +88,7 @@ func TestBuildHandleRequestHasCorrectExposeAllKeys(t *testing.T) {
 				Method: "POST",
 			}
 
-			hr, err := BuildHandleRequest(req)
+			hr, err := BuildHandleRequest(req, utils.CreateMockDecisionContext())
 
 			assert.NotNil(t, hr)
 			assert.Nil(t, err)
@@ -90,3 +97,77 @@ func TestBuildHandleRequestHasCorrectExposeAllKeys(t *testing.T) {
 	}
 
 }
+
+func TestBuildHandleRequestContextSchema(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	validator, err := contextschema.NewValidator(&models.ContextSchemaOptions{
+		Mode:   models.ContextSchemaModeReject,
+		Fields: []*models.ContextField{{Key: "age", Type: "number"}},
+	})
+	assert.Nil(t, err)
+	decisionContext.ContextSchema = validator
+	newRequest := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id": "123", "context": {"age": "30"}}`))
+	}
+
+	_, err = BuildHandleRequest(newRequest(), decisionContext)
+	var validationErr *validation.ErrorResponse
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, map[string]string{"context.age": "Expected a number."}, validationErr.Errors)
+
+	validator, err = contextschema.NewValidator(&models.ContextSchemaOptions{
+		Mode:   models.ContextSchemaModeCoerce,
+		Fields: []*models.ContextField{{Key: "age", Type: "number"}},
+	})
+	assert.Nil(t, err)
+	decisionContext.ContextSchema = validator
+	hr, err := BuildHandleRequest(newRequest(), decisionContext)
+	assert.Nil(t, err)
+	assert.Equal(t, 30., hr.DecisionRequest.GetContext()["age"].GetNumberValue())
+	assert.Equal(t, 30., hr.FullVisitorContext.Standard["age"].GetNumberValue())
+}
+
+func TestBuildHandleRequestContextEnrichment(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	paris, err := time.LoadLocation("Europe/Paris")
+	assert.Nil(t, err)
+	decisionContext.ContextEnrichmentOptions = &models.ContextEnrichmentOptions{
+		Keys:              []string{models.ContextKeyDeviceType, models.ContextKeyOS, models.ContextKeyBrowser, models.ContextKeyLanguage, models.ContextKeyIP, models.ContextKeyHour, models.ContextKeyDayOfWeek},
+		Location:          paris,
+		TrustForwardedFor: true,
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id": "123", "context": {"plan": "premium", "fs_os": "custom"}}`))
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1")
+	req.Header.Set("Accept-Language", "en-US;q=0.8, fr-FR, *;q=0.5")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 203.0.113.7")
+
+	hr, err := BuildHandleRequest(req, decisionContext)
+	assert.Nil(t, err)
+	now := hr.Time.In(paris)
+	assert.Equal(t, map[string]interface{}{
+		"plan":                      "premium",
+		models.ContextKeyDeviceType: "mobile",
+		models.ContextKeyOS:         "custom",
+		models.ContextKeyBrowser:    "Safari",
+		models.ContextKeyLanguage:   "fr",
+		models.ContextKeyIP:         "203.0.113.7",
+		models.ContextKeyHour:       float64(now.Hour()),
+		models.ContextKeyDayOfWeek:  float64((int(now.Weekday())+6)%7 + 1),
+	}, (&structpb.Struct{Fields: hr.FullVisitorContext.Standard}).AsMap())
+	// the derived keys are not sent with the visitor context hits
+	assert.Len(t, hr.DecisionRequest.GetContext(), 2)
+
+	decisionContext.ContextEnrichmentOptions = &models.ContextEnrichmentOptions{Keys: []string{models.ContextKeyLanguage, models.ContextKeyBrowser}}
+	req = httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id": "123", "context": {}}`))
+	req.Header.Set("Accept-Language", "de")
+	hr, err = BuildHandleRequest(req, decisionContext)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{models.ContextKeyLanguage: "de"}, (&structpb.Struct{Fields: hr.FullVisitorContext.Standard}).AsMap())
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "", preferredLanguage(""))
+	assert.Equal(t, "", preferredLanguage("*"))
+	assert.Equal(t, "en", preferredLanguage("en-US,en;q=0.9"))
+	assert.Equal(t, "fr", preferredLanguage("en;q=0.5, FR-ca;q=0.7, de;q=invalid"))
+}
@@ -0,0 +1,123 @@
+package apilogic
+
+import (
+	"slices"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/pkg/models"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/decision_response"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// OverridesExtra is the extra of the decision response listing the local overrides applied to the decision.
+// It is added to the response whenever an override is applied
+const OverridesExtra = "overrides"
+
+// AppliedOverrides are the local overrides applied to a decision
+type AppliedOverrides struct {
+	Flags              []string `json:"flags,omitempty"`
+	PausedCampaigns    []string `json:"pausedCampaigns,omitempty"`
+	ReferenceCampaigns []string `json:"referenceCampaigns,omitempty"`
+}
+
+func (a *AppliedOverrides) isEmpty() bool {
+	return len(a.Flags) == 0 && len(a.PausedCampaigns) == 0 && len(a.ReferenceCampaigns) == 0
+}
+
+// applyEnvironmentOverrides applies the panic mode, paused campaigns and reference campaigns overrides to a copy
+// of the loaded environment. The assignments of the campaigns pinned to their reference variation are not saved,
+// so that the visitors get their variation back when the override is removed
+func applyEnvironmentOverrides(handleRequest *handle.Request, overrides *models.Overrides) *AppliedOverrides {
+	applied := &AppliedOverrides{}
+	if overrides.IsEmpty() {
+		return applied
+	}
+
+	environment := *handleRequest.Environment
+	commonEnvironment := *environment.Common
+	commonEnvironment.IsPanic = commonEnvironment.IsPanic || overrides.Panic
+	commonEnvironment.Campaigns = []*common.Campaign{}
+	for _, campaign := range handleRequest.Environment.Common.Campaigns {
+		switch {
+		case slices.Contains(overrides.PausedCampaigns, campaign.ID):
+			applied.PausedCampaigns = append(applied.PausedCampaigns, campaign.ID)
+			continue
+		case slices.Contains(overrides.ReferenceCampaigns, campaign.ID):
+			if pinned := referenceCampaign(campaign); pinned != nil {
+				applied.ReferenceCampaigns = append(applied.ReferenceCampaigns, campaign.ID)
+				if handleRequest.UnsavedVariationGroups == nil {
+					handleRequest.UnsavedVariationGroups = map[string]bool{}
+				}
+				for _, vg := range pinned.VariationGroups {
+					handleRequest.UnsavedVariationGroups[vg.ID] = true
+				}
+				campaign = pinned
+			} else {
+				handleRequest.Logger.Warnf("campaign %s can not be pinned to its reference variation: a variation group has no reference", campaign.ID)
+			}
+		}
+		commonEnvironment.Campaigns = append(commonEnvironment.Campaigns, campaign)
+	}
+	environment.Common = &commonEnvironment
+	handleRequest.Environment = &environment
+	return applied
+}
+
+// referenceCampaign returns a copy of the campaign whose variation groups only allocate their reference variation,
+// or nil if a variation group has no reference variation
+func referenceCampaign(campaign *common.Campaign) *common.Campaign {
+	pinned := *campaign
+	pinned.VariationGroups = []*common.VariationGroup{}
+	for _, vg := range campaign.VariationGroups {
+		index := slices.IndexFunc(vg.Variations, func(v *common.Variation) bool {
+			return v.Reference
+		})
+		if index < 0 {
+			return nil
+		}
+		reference := *vg.Variations[index]
+		reference.Allocation = 100
+		pinnedVG := *vg
+		pinnedVG.Variations = []*common.Variation{&reference}
+		pinned.VariationGroups = append(pinned.VariationGroups, &pinnedVG)
+	}
+	return &pinned
+}
+
+// applyFlagOverrides forces the values of the overridden flag keys in the variations of the decision providing them
+func applyFlagOverrides(handleRequest *handle.Request, overrides *models.Overrides, applied *AppliedOverrides) {
+	if len(overrides.Flags) == 0 {
+		return
+	}
+	values := map[string]*structpb.Value{}
+	for key, value := range overrides.Flags {
+		pbValue, err := structpb.NewValue(value)
+		if err != nil {
+			handleRequest.Logger.Warnf("invalid override value of flag %s: %v", key, err)
+			continue
+		}
+		values[key] = pbValue
+	}
+
+	for _, campaign := range handleRequest.DecisionResponse.GetCampaigns() {
+		variation := campaign.GetVariation()
+		cloned := false
+		for key, value := range values {
+			if _, ok := variation.GetModifications().GetValue().GetFields()[key]; !ok {
+				continue
+			}
+			// the modifications are shared with the loaded environment
+			if !cloned {
+				variation.Modifications = proto.Clone(variation.Modifications).(*decision_response.Modifications)
+				cloned = true
+			}
+			variation.Modifications.Value.Fields[key] = value
+			if !slices.Contains(applied.Flags, key) {
+				applied.Flags = append(applied.Flags, key)
+			}
+		}
+	}
+	slices.Sort(applied.Flags)
+}
@@ -0,0 +1,247 @@
+package apilogic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-common/targeting"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// SimulationMaxVisitors is the maximum number of synthetic visitors of a simulation
+const SimulationMaxVisitors = 100000
+
+// simulationCancelCheckInterval is the number of visitors simulated between two checks of the request cancellation
+const simulationCancelCheckInterval = 1000
+
+// Visitor ID generators of the simulations
+const (
+	SimulationGeneratorSequential = "sequential"
+	SimulationGeneratorRandom     = "random"
+)
+
+// Placeholders of the simulation context templates, replaced in the string values for each visitor
+const (
+	SimulationVisitorIDPlaceholder = "{{visitor_id}}"
+	SimulationIndexPlaceholder     = "{{index}}"
+)
+
+// SimulationRequest describes the synthetic visitors of a campaign simulation.
+// The visitor IDs are either listed or generated, and each visitor gets a context template in turn
+type SimulationRequest struct {
+	VisitorIDs []string                 `json:"visitor_ids,omitempty"`
+	Count      int                      `json:"count,omitempty"`
+	Generator  string                   `json:"generator,omitempty"`
+	Prefix     string                   `json:"prefix,omitempty"`
+	Context    map[string]interface{}   `json:"context,omitempty"`
+	Contexts   []map[string]interface{} `json:"contexts,omitempty"`
+}
+
+// SimulationVariation is the observed distribution of a variation, compared to its configured allocation
+type SimulationVariation struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name,omitempty"`
+	Reference  bool    `json:"reference"`
+	Allocation float32 `json:"allocation"`
+	Visitors   int     `json:"visitors"`
+	Share      float64 `json:"share"`
+	Deviation  float64 `json:"deviation"`
+}
+
+// SimulationVariationGroup is the observed distribution of the visitors targeted by a variation group
+type SimulationVariationGroup struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name,omitempty"`
+	Targeted   int                    `json:"targeted"`
+	InBucket   int                    `json:"inBucket"`
+	Untracked  int                    `json:"untracked"`
+	Variations []*SimulationVariation `json:"variations"`
+}
+
+// SimulationResult is the result of a campaign simulation.
+// The shares and deviations are percentages of the visitors in bucket of the variation group, as the allocations
+type SimulationResult struct {
+	CampaignID         string                      `json:"campaignId"`
+	Name               string                      `json:"name,omitempty"`
+	Type               string                      `json:"type"`
+	Visitors           int                         `json:"visitors"`
+	Targeted           int                         `json:"targeted"`
+	TargetingMatchRate float64                     `json:"targetingMatchRate"`
+	InBucket           int                         `json:"inBucket"`
+	Allocated          int                         `json:"allocated"`
+	VariationGroups    []*SimulationVariationGroup `json:"variationGroups"`
+}
+
+// simulationVisitor is a synthetic visitor of a simulation
+type simulationVisitor struct {
+	ID      string
+	Context *targeting.Context
+}
+
+// SimulateCampaign evaluates the targeting and allocation of a campaign of the environment for synthetic visitors.
+// The evaluation does not trigger hits nor save assignments, and the visitors context is not enriched by the integration providers.
+// It stops when the context is done, for instance when the client goes away
+func SimulateCampaign(ctx context.Context, environment *models.Environment, campaignID string, request *SimulationRequest) (*SimulationResult, error) {
+	visitors, err := buildSimulationVisitors(request)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	var campaign *common.Campaign
+	if environment != nil && environment.Common != nil {
+		for _, c := range environment.Common.Campaigns {
+			if c.ID == campaignID {
+				campaign = c
+				break
+			}
+		}
+	}
+	if campaign == nil {
+		return nil, &Error{Status: http.StatusNotFound, Message: fmt.Sprintf("campaign %s not found in the loaded environment", campaignID)}
+	}
+
+	result := &SimulationResult{
+		CampaignID:      campaign.ID,
+		Name:            campaign.Name,
+		Type:            campaign.Type,
+		Visitors:        len(visitors),
+		VariationGroups: []*SimulationVariationGroup{},
+	}
+	for _, vg := range campaign.VariationGroups {
+		vgResult := &SimulationVariationGroup{ID: vg.ID, Name: vg.Name, Variations: []*SimulationVariation{}}
+		for _, variation := range vg.Variations {
+			vgResult.Variations = append(vgResult.Variations, &SimulationVariation{
+				ID:         variation.ID,
+				Name:       variation.Name,
+				Reference:  variation.Reference,
+				Allocation: variation.Allocation,
+			})
+		}
+		result.VariationGroups = append(result.VariationGroups, vgResult)
+	}
+
+	for i, visitor := range visitors {
+		if i%simulationCancelCheckInterval == 0 && ctx.Err() != nil {
+			return nil, &Error{Status: http.StatusRequestTimeout, Message: fmt.Sprintf("simulation canceled: %v", ctx.Err())}
+		}
+
+		// the visitor is targeted by the first matching variation group, as for the decision
+		vgIndex := -1
+		for i, vg := range campaign.VariationGroups {
+			if targetingMatch(vg.Targetings, visitor.ID, visitor.Context) {
+				vgIndex = i
+				break
+			}
+		}
+		if vgIndex < 0 {
+			continue
+		}
+		result.Targeted++
+		vgResult := result.VariationGroups[vgIndex]
+		vgResult.Targeted++
+
+		if !inBucketRanges(campaign, visitor.ID) {
+			continue
+		}
+		result.InBucket++
+		vgResult.InBucket++
+
+		vg := campaign.VariationGroups[vgIndex]
+		variationID := allocatedVariationID(vg, visitor.ID, "")
+		if variationID == "" {
+			vgResult.Untracked++
+			continue
+		}
+		result.Allocated++
+		for _, variation := range vgResult.Variations {
+			if variation.ID == variationID {
+				variation.Visitors++
+			}
+		}
+	}
+
+	if result.Visitors > 0 {
+		result.TargetingMatchRate = float64(result.Targeted) / float64(result.Visitors)
+	}
+	for _, vgResult := range result.VariationGroups {
+		for _, variation := range vgResult.Variations {
+			if vgResult.InBucket > 0 {
+				variation.Share = 100 * float64(variation.Visitors) / float64(vgResult.InBucket)
+			}
+			variation.Deviation = variation.Share - float64(variation.Allocation)
+		}
+	}
+	return result, nil
+}
+
+func buildSimulationVisitors(request *SimulationRequest) ([]*simulationVisitor, error) {
+	ids := request.VisitorIDs
+	if len(ids) == 0 {
+		if request.Count <= 0 {
+			return nil, errors.New("visitor_ids or a positive count is mandatory")
+		}
+		if request.Count > SimulationMaxVisitors {
+			return nil, fmt.Errorf("count must not exceed %d visitors", SimulationMaxVisitors)
+		}
+
+		ids = make([]string, request.Count)
+		for i := range ids {
+			switch request.Generator {
+			case "", SimulationGeneratorSequential:
+				ids[i] = request.Prefix + strconv.Itoa(i)
+			case SimulationGeneratorRandom:
+				ids[i] = fmt.Sprintf("%s%016x", request.Prefix, rand.Uint64())
+			default:
+				return nil, fmt.Errorf("unknown visitor ID generator %s", request.Generator)
+			}
+		}
+	}
+	if len(ids) > SimulationMaxVisitors {
+		return nil, fmt.Errorf("visitor_ids must not exceed %d visitors", SimulationMaxVisitors)
+	}
+
+	templates := request.Contexts
+	if len(templates) == 0 {
+		templates = []map[string]interface{}{request.Context}
+	}
+
+	visitors := make([]*simulationVisitor, len(ids))
+	for i, id := range ids {
+		contextMap, err := renderContextTemplate(templates[i%len(templates)], id, i)
+		if err != nil {
+			return nil, err
+		}
+		visitors[i] = &simulationVisitor{
+			ID: id,
+			Context: &targeting.Context{
+				Standard:             contextMap,
+				IntegrationProviders: map[string]targeting.ContextMap{},
+			},
+		}
+	}
+	return visitors, nil
+}
+
+// renderContextTemplate replaces the placeholders of the template string values for a visitor
+func renderContextTemplate(template map[string]interface{}, visitorID string, index int) (targeting.ContextMap, error) {
+	contextMap := targeting.ContextMap{}
+	for key, value := range template {
+		if str, ok := value.(string); ok {
+			str = strings.ReplaceAll(str, SimulationVisitorIDPlaceholder, visitorID)
+			value = strings.ReplaceAll(str, SimulationIndexPlaceholder, strconv.Itoa(index))
+		}
+		pbValue, err := structpb.NewValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context value for key %s: %w", key, err)
+		}
+		contextMap[key] = pbValue
+	}
+	return contextMap, nil
+}
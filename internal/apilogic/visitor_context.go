@@ -4,6 +4,8 @@ import (
 	"github.com/flagship-io/decision-api/internal/handle"
 	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // SendVisitorContext sends a pubsub event to handle visitor context
@@ -41,7 +43,10 @@ func SendVisitorContext(handleRequest *handle.Request) {
 			Timestamp:  handleRequest.Time.UnixNano() / 1000000,
 		})
 	}
+	_, span := tracing.StartSpan(handleRequest.Ctx, handleRequest.DecisionContext.Tracer, "HitsProcessor.TrackHits",
+		attribute.Int("hits", len(contexts)))
 	err := handleRequest.DecisionContext.HitsProcessor.TrackHits(connectors.TrackingHits{VisitorContext: contexts})
+	tracing.EndSpan(span, err)
 	if err != nil {
 		handleRequest.Logger.Errorf("Error on queuing visitor context : %v", err)
 	}
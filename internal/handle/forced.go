@@ -0,0 +1,96 @@
+package handle
+
+import (
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/flagship-io/flagship-proto/decision_response"
+)
+
+// forcedCampaigns splits the campaigns of the environment between the responses of the campaigns with a variation
+// forced by the request, built without targeting nor allocation, and the campaigns left to the decision
+func forcedCampaigns(handleRequest *Request) (map[string]*decision_response.Campaign, []*common.Campaign) {
+	campaigns := handleRequest.Environment.Common.Campaigns
+	if len(handleRequest.ForcedVariations) == 0 {
+		return nil, campaigns
+	}
+
+	forced := map[string]*decision_response.Campaign{}
+	decided := []*common.Campaign{}
+	for _, campaign := range campaigns {
+		if response := forcedCampaign(handleRequest, campaign); response != nil {
+			forced[campaign.ID] = response
+			continue
+		}
+		decided = append(decided, campaign)
+	}
+	return forced, decided
+}
+
+func forcedCampaign(handleRequest *Request, campaign *common.Campaign) *decision_response.Campaign {
+	variationID, ok := handleRequest.ForcedVariations[campaign.ID]
+	if !ok {
+		return nil
+	}
+	for _, vg := range campaign.VariationGroups {
+		for _, variation := range vg.Variations {
+			if variation.ID == variationID {
+				return buildCampaignResponse(vg, variation, handleRequest.ExposeAllKeys)
+			}
+		}
+	}
+	handleRequest.Logger.Warnf("forced variation %s not found in campaign %s", variationID, campaign.ID)
+	return nil
+}
+
+// mergeForcedCampaigns adds the forced campaigns responses to the decided ones, in the order of the environment campaigns
+func mergeForcedCampaigns(handleRequest *Request, forced map[string]*decision_response.Campaign, decided []*decision_response.Campaign) []*decision_response.Campaign {
+	responses := map[string]*decision_response.Campaign{}
+	for _, campaign := range decided {
+		responses[campaign.GetId().GetValue()] = campaign
+	}
+	for campaignID, campaign := range forced {
+		responses[campaignID] = campaign
+	}
+
+	merged := []*decision_response.Campaign{}
+	for _, campaign := range handleRequest.Environment.Common.Campaigns {
+		if response, ok := responses[campaign.ID]; ok {
+			merged = append(merged, response)
+			delete(responses, campaign.ID)
+		}
+	}
+	return merged
+}
+
+// IsForced returns whether the variation of the campaign response is forced by the request
+func (r Request) IsForced(campaign *decision_response.Campaign) bool {
+	variationID, ok := r.ForcedVariations[campaign.GetId().GetValue()]
+	return ok && variationID == campaign.GetVariation().GetId().GetValue()
+}
+
+// TrackForcedActivations tracks the activations of the forced campaigns as QA activations.
+// The forced variations are never persisted as visitor assignments
+func TrackForcedActivations(handleRequest *Request, campaigns []*decision_response.Campaign) error {
+	visitorID := handleRequest.DecisionRequest.GetVisitorId().GetValue()
+	anonymousID := visitorID
+	if handleRequest.Environment.Common.UseReconciliation && handleRequest.DecisionRequest.GetAnonymousId().GetValue() != "" {
+		anonymousID = handleRequest.DecisionRequest.GetAnonymousId().GetValue()
+	}
+
+	activations := []*models.CampaignActivation{}
+	for _, campaign := range campaigns {
+		activations = append(activations, &models.CampaignActivation{
+			EnvID:       handleRequest.DecisionContext.EnvID,
+			VisitorID:   anonymousID,
+			CustomerID:  visitorID,
+			CampaignID:  campaign.GetVariationGroupId().GetValue(),
+			VariationID: campaign.GetVariation().GetId().GetValue(),
+			Timestamp:   handleRequest.Time.UnixNano() / 1000000,
+			QA:          true,
+		})
+	}
+	return handleRequest.DecisionContext.HitsProcessor.TrackHits(connectors.TrackingHits{
+		CampaignActivations: activations,
+	})
+}
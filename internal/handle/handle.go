@@ -1,6 +1,7 @@
 package handle
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"strings"
@@ -9,11 +10,13 @@ import (
 	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/flagship-io/decision-api/pkg/utils/tracing"
 	common "github.com/flagship-io/flagship-common"
 	"github.com/flagship-io/flagship-common/targeting"
 
 	"github.com/flagship-io/flagship-proto/decision_request"
 	"github.com/flagship-io/flagship-proto/decision_response"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Request represents the infos of the requests needed for the decision API
@@ -28,8 +31,16 @@ type Request struct {
 	Extras             []string
 	ExposeAllKeys      bool
 	SendContextEvent   bool
-	Time               time.Time
-	Logger             *logger.Logger
+	// ForcedVariationsToken is the signed forced variations token of the request, verified before the decision
+	ForcedVariationsToken string
+	// ForcedVariations maps the campaign IDs to the variation IDs forced by the verified token
+	ForcedVariations map[string]string
+	// UnsavedVariationGroups are the IDs of the variation groups whose assignments are not saved
+	UnsavedVariationGroups map[string]bool
+	Time                   time.Time
+	Logger                 *logger.Logger
+	// Ctx is the context of the incoming request, used as parent of the decision spans
+	Ctx context.Context
 }
 
 func NewRequestFromHTTP(req *http.Request) Request {
@@ -42,6 +53,7 @@ func NewRequestFromHTTP(req *http.Request) Request {
 		Time:       time.Now(),
 		CampaignID: campaignId,
 		Extras:     []string{},
+		Ctx:        req.Context(),
 	}
 }
 
@@ -54,7 +66,8 @@ func (r Request) HasExtra(extra string) bool {
 	return false
 }
 
-func shouldTriggerHit(request *decision_request.DecisionRequest) bool {
+// ShouldTriggerHit returns false if the decision request disables the activation hits
+func ShouldTriggerHit(request *decision_request.DecisionRequest) bool {
 	if (request.GetTriggerHit() != nil && !request.GetTriggerHit().GetValue()) ||
 		(request.GetActivate() != nil && !request.GetActivate().GetValue()) {
 		return false
@@ -62,11 +75,24 @@ func shouldTriggerHit(request *decision_request.DecisionRequest) bool {
 	return true
 }
 
-// Decision returns a DecisionResponse from the DecisionRequest and the campaign information
-func Decision(handleRequest *Request, tracker *common.Tracker) error {
+// Decision returns a DecisionResponse from the DecisionRequest and the campaign information.
+// The campaigns with a forced variation bypass the targeting and allocation, and their assignments are not saved
+func Decision(handleRequest *Request, tracker *common.Tracker) (err error) {
 	if handleRequest.Environment == nil {
 		return errors.New("client context not initialized")
 	}
+
+	// the visitor ID is personal data, and is not exported to the tracing backend
+	ctx, span := tracing.StartSpan(handleRequest.Ctx, handleRequest.DecisionContext.Tracer, "handle.Decision",
+		attribute.String("env_id", handleRequest.DecisionContext.EnvID))
+	defer func() {
+		tracing.EndSpan(span, err)
+	}()
+
+	forced, campaigns := forcedCampaigns(handleRequest)
+	environment := *handleRequest.Environment.Common
+	environment.Campaigns = campaigns
+
 	decisionResponse, err := common.GetDecision(
 		common.Visitor{
 			ID:            handleRequest.DecisionRequest.VisitorId.GetValue(),
@@ -74,15 +100,18 @@ func Decision(handleRequest *Request, tracker *common.Tracker) error {
 			DecisionGroup: handleRequest.DecisionRequest.DecisionGroup.GetValue(),
 			Context:       handleRequest.FullVisitorContext,
 		},
-		*handleRequest.Environment.Common,
+		environment,
 		common.DecisionOptions{
-			TriggerHit:    shouldTriggerHit(handleRequest.DecisionRequest),
+			TriggerHit:    ShouldTriggerHit(handleRequest.DecisionRequest),
 			CampaignID:    handleRequest.CampaignID,
 			Tracker:       tracker,
 			ExposeAllKeys: handleRequest.ExposeAllKeys,
 		}, common.DecisionHandlers{
 			GetCache: func(environmentID, id string) (*common.VisitorAssignments, error) {
-				return handleRequest.DecisionContext.AssignmentsManager.LoadAssignments(environmentID, id)
+				_, span := tracing.StartSpan(ctx, handleRequest.DecisionContext.Tracer, "AssignmentsManager.LoadAssignments")
+				assignments, err := handleRequest.DecisionContext.AssignmentsManager.LoadAssignments(environmentID, id)
+				tracing.EndSpan(span, err)
+				return assignments, err
 			},
 			SaveCache: func(environmentID, id string, assignment *common.VisitorAssignments) error {
 				if !handleRequest.DecisionContext.AssignmentsManager.ShouldSaveAssignments(connectors.SaveAssignmentsContext{
@@ -90,7 +119,22 @@ func Decision(handleRequest *Request, tracker *common.Tracker) error {
 				}) {
 					return nil
 				}
-				return handleRequest.DecisionContext.AssignmentsManager.SaveAssignments(environmentID, id, assignment.Assignments, handleRequest.Time)
+				assignments := assignment.Assignments
+				if len(handleRequest.UnsavedVariationGroups) > 0 {
+					assignments = map[string]*common.VisitorCache{}
+					for vgID, cache := range assignment.Assignments {
+						if !handleRequest.UnsavedVariationGroups[vgID] {
+							assignments[vgID] = cache
+						}
+					}
+					if len(assignments) == 0 {
+						return nil
+					}
+				}
+				_, span := tracing.StartSpan(ctx, handleRequest.DecisionContext.Tracer, "AssignmentsManager.SaveAssignments")
+				err := handleRequest.DecisionContext.AssignmentsManager.SaveAssignments(environmentID, id, assignments, handleRequest.Time)
+				tracing.EndSpan(span, err)
+				return err
 			},
 			ActivateCampaigns: func(activations []*common.VisitorActivation) error {
 				// Initialize future campaign activations
@@ -105,12 +149,30 @@ func Decision(handleRequest *Request, tracker *common.Tracker) error {
 						Timestamp:   handleRequest.Time.UnixNano() / 1000000,
 					})
 				}
-				return handleRequest.DecisionContext.HitsProcessor.TrackHits(connectors.TrackingHits{
+				_, span := tracing.StartSpan(ctx, handleRequest.DecisionContext.Tracer, "HitsProcessor.TrackHits",
+					attribute.Int("hits", len(cActivations)))
+				err := handleRequest.DecisionContext.HitsProcessor.TrackHits(connectors.TrackingHits{
 					CampaignActivations: cActivations,
 				})
+				tracing.EndSpan(span, err)
+				return err
 			},
 		})
 
+	if len(forced) > 0 && decisionResponse != nil {
+		decisionResponse.Campaigns = mergeForcedCampaigns(handleRequest, forced, decisionResponse.Campaigns)
+		if ShouldTriggerHit(handleRequest.DecisionRequest) {
+			forcedResponses := []*decision_response.Campaign{}
+			for _, campaign := range decisionResponse.Campaigns {
+				if _, ok := forced[campaign.GetId().GetValue()]; ok {
+					forcedResponses = append(forcedResponses, campaign)
+				}
+			}
+			if trackErr := TrackForcedActivations(handleRequest, forcedResponses); trackErr != nil && err == nil {
+				err = trackErr
+			}
+		}
+	}
 	handleRequest.DecisionResponse = decisionResponse
 
 	return err
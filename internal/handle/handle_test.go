@@ -5,8 +5,10 @@ import (
 
 	"github.com/flagship-io/decision-api/internal/utils"
 	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
 	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	"github.com/flagship-io/flagship-common/targeting"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -321,3 +323,79 @@ func TestDecisionReconciliation(t *testing.T) {
 	assert.NotNil(t, handleRequest.DecisionResponse)
 	assert.Equal(t, 1, len(handleRequest.DecisionResponse.Campaigns))
 }
+
+func TestDecisionForcedVariations(t *testing.T) {
+	clientID := "client_id"
+	hitsProcessor := &hits_processors.MockHitProcessor{}
+	assignmentsManager := assignments_managers.InitMemoryManager()
+	handleRequest := Request{
+		DecisionContext: &connectors.DecisionContext{
+			EnvID: clientID,
+			Connectors: connectors.Connectors{
+				HitsProcessor:      hitsProcessor,
+				AssignmentsManager: assignmentsManager,
+			},
+		},
+		DecisionRequest: &decision_request.DecisionRequest{
+			VisitorId:  &wrapperspb.StringValue{Value: "vis_id"},
+			TriggerHit: wrapperspb.Bool(true),
+			Context:    map[string]*structpb.Value{},
+		},
+		FullVisitorContext: &targeting.Context{
+			Standard:             map[string]*structpb.Value{},
+			IntegrationProviders: make(map[string]targeting.ContextMap),
+		},
+		// the provider targeting of campaign1 does not match the visitor
+		ForcedVariations: map[string]string{"campaign1": "v_2"},
+	}
+
+	campaigns := []*common.Campaign{
+		utils.CreateABCampaignMock(
+			"campaign1",
+			"vg1",
+			utils.CreateTargetingWithProvider(),
+			utils.CreateModification("key", "value", decision_response.ModificationsType_FLAG),
+		),
+		utils.CreateABCampaignMock(
+			"campaign2",
+			"vg2",
+			utils.CreateAllUsersTargetingMock(),
+			utils.CreateModification("key2", "value", decision_response.ModificationsType_FLAG),
+		),
+	}
+	handleRequest.Environment = &models.Environment{Common: &common.Environment{
+		ID:           clientID,
+		Campaigns:    campaigns,
+		CacheEnabled: true,
+	}}
+
+	err := Decision(&handleRequest, nil)
+	assert.Nil(t, err)
+	assert.Len(t, handleRequest.DecisionResponse.Campaigns, 2)
+	assert.Equal(t, "campaign1", handleRequest.DecisionResponse.Campaigns[0].Id.GetValue())
+	assert.Equal(t, "v_2", handleRequest.DecisionResponse.Campaigns[0].Variation.Id.GetValue())
+	assert.True(t, handleRequest.IsForced(handleRequest.DecisionResponse.Campaigns[0]))
+	assert.Equal(t, "campaign2", handleRequest.DecisionResponse.Campaigns[1].Id.GetValue())
+	assert.False(t, handleRequest.IsForced(handleRequest.DecisionResponse.Campaigns[1]))
+
+	// the forced activations are tracked as QA activations
+	assert.Len(t, hitsProcessor.TrackedHits.CampaignActivations, 1)
+	assert.Equal(t, "vg1", hitsProcessor.TrackedHits.CampaignActivations[0].CampaignID)
+	assert.Equal(t, "v_2", hitsProcessor.TrackedHits.CampaignActivations[0].VariationID)
+	assert.Equal(t, "vis_id", hitsProcessor.TrackedHits.CampaignActivations[0].CustomerID)
+	assert.True(t, hitsProcessor.TrackedHits.CampaignActivations[0].QA)
+
+	// the forced variations are not persisted
+	assignments, err := assignmentsManager.LoadAssignments(clientID, "vis_id")
+	assert.Nil(t, err)
+	assert.NotNil(t, assignments.Assignments["vg2"])
+	assert.Nil(t, assignments.Assignments["vg1"])
+
+	// the unknown forced variations are ignored
+	handleRequest.ForcedVariations = map[string]string{"campaign2": "unknown"}
+	handleRequest.Logger = logger.New("debug", logger.FORMAT_TEXT, "test")
+	err = Decision(&handleRequest, nil)
+	assert.Nil(t, err)
+	assert.Len(t, handleRequest.DecisionResponse.Campaigns, 1)
+	assert.False(t, handleRequest.IsForced(handleRequest.DecisionResponse.Campaigns[0]))
+}
@@ -105,6 +105,36 @@ func CreateABCampaignMock(campaignID string, vgID string, targetings *targeting.
 	}
 }
 
+// CreateReferenceCampaignMock returns a mocked campaign allocating all the visitors to a reference variation without modifications,
+// the key being only set by its other variation
+func CreateReferenceCampaignMock(campaignID string, vgID string, key string) *common.Campaign {
+	return &common.Campaign{
+		ID:   campaignID,
+		Type: "ab",
+		VariationGroups: []*common.VariationGroup{
+			{
+				ID:         vgID,
+				Campaign:   &common.Campaign{ID: campaignID, Type: "ab"},
+				Targetings: CreateAllUsersTargetingMock(),
+				Variations: []*common.Variation{
+					{
+						ID:            "v_ref",
+						Allocation:    100,
+						Reference:     true,
+						Modifications: &decision_response.Modifications{Type: decision_response.ModificationsType_FLAG, Value: &structpb.Struct{}},
+					},
+					{
+						ID:            "v_2",
+						Allocation:    0,
+						Modifications: CreateModification(key, "blue", decision_response.ModificationsType_FLAG),
+					},
+				},
+			},
+		},
+		BucketRanges: [][]float64{{0, 100}},
+	}
+}
+
 func CreateMockDecisionContext() *connectors.DecisionContext {
 	modifications := CreateModification("testString", "string", decision_response.ModificationsType_FLAG)
 	modifications.Value.Fields["testBool"], _ = structpb.NewValue(true)
@@ -1,34 +1,207 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/flagship-io/flagship-proto/decision_request"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// ForcedVariationsField is the decision request body field and query parameter of the signed forced variations token
+const ForcedVariationsField = "forced_variations"
+
+// ForcedVariationsHeader is the request header of the signed forced variations token, if not sent in the request
+const ForcedVariationsHeader = "X-Forced-Variations"
+
 // GetDecisionRequest transforms http request into a DecisionRequest
 func GetDecisionRequest(r *http.Request) (*decision_request.DecisionRequest, error) {
-	decisionRequest, err := unmarshalHit(r)
+	decisionRequest, _, err := GetDecisionRequestAndForcedVariations(r)
+	return decisionRequest, err
+}
+
+// GetDecisionRequestAndForcedVariations transforms http request into a DecisionRequest, and returns the forced variations
+// token of the request body, query or header
+func GetDecisionRequestAndForcedVariations(r *http.Request) (*decision_request.DecisionRequest, string, error) {
+	decisionRequest, forcedVariationsToken, err := unmarshalHit(r)
 
 	if err != nil {
 		//raven.CaptureError(err, nil)
-		return nil, err
+		return nil, "", err
 	}
 
-	return decisionRequest, nil
+	if forcedVariationsToken == "" {
+		forcedVariationsToken = r.Header.Get(ForcedVariationsHeader)
+	}
+	return decisionRequest, forcedVariationsToken, nil
 }
 
-func unmarshalHit(r *http.Request) (*decision_request.DecisionRequest, error) {
-	if r.Method == http.MethodPost {
+// PayloadQueryParam is the query parameter of GET requests holding the base64url encoded JSON decision request
+const PayloadQueryParam = "payload"
+
+// contextQueryPrefix prefixes the query parameters of typed context values, as context.<key> or context.<key>:<type>
+const contextQueryPrefix = "context."
+
+var stringQueryParams = []string{"visitor_id", "anonymous_id", "decision_group", ForcedVariationsField}
+var boolQueryParams = []string{"trigger_hit", "visitor_consent", "format_response"}
+
+// GetDecisionRequestFromQuery transforms the query parameters of a GET request into a DecisionRequest.
+// The request is either the base64url encoded JSON body in the payload parameter, or the visitor_id, anonymous_id,
+// decision_group, trigger_hit, visitor_consent and format_response parameters with the visitor context as a JSON object
+// in the context parameter and as typed values in context.<key>[:string|number|bool] parameters
+func GetDecisionRequestFromQuery(r *http.Request) (*decision_request.DecisionRequest, error) {
+	decisionRequest, _, err := getDecisionRequestFromQuery(r)
+	return decisionRequest, err
+}
+
+func getDecisionRequestFromQuery(r *http.Request) (*decision_request.DecisionRequest, string, error) {
+	query := r.URL.Query()
+	if payload := query.Get(PayloadQueryParam); payload != "" {
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+		if err != nil {
+			return nil, "", fmt.Errorf("%s query parameter must be base64url encoded : %s", PayloadQueryParam, err.Error())
+		}
+		return parseDecisionBody(data)
+	}
+
+	body := map[string]interface{}{}
+	for _, param := range stringQueryParams {
+		if query.Has(param) {
+			body[param] = query.Get(param)
+		}
+	}
+	for _, param := range boolQueryParams {
+		if !query.Has(param) {
+			continue
+		}
+		value, err := strconv.ParseBool(query.Get(param))
+		if err != nil {
+			return nil, "", fmt.Errorf("%s query parameter must be a boolean", param)
+		}
+		body[param] = value
+	}
+
+	visitorContext := map[string]interface{}{}
+	if rawContext := query.Get("context"); rawContext != "" {
+		decoder := json.NewDecoder(strings.NewReader(rawContext))
+		decoder.UseNumber()
+		if err := decoder.Decode(&visitorContext); err != nil {
+			return nil, "", errors.New("context query parameter must be a valid json object")
+		}
+	}
+	for param, values := range query {
+		if !strings.HasPrefix(param, contextQueryPrefix) || len(values) == 0 {
+			continue
+		}
+		key, valueType, _ := strings.Cut(strings.TrimPrefix(param, contextQueryPrefix), ":")
+		value, err := parseContextValue(values[0], valueType)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s query parameter : %s", param, err.Error())
+		}
+		visitorContext[key] = value
+	}
+	if len(visitorContext) > 0 {
+		body["context"] = visitorContext
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return parseDecisionBody(data)
+}
+
+// parseContextValue parses a context query value of the type. Untyped values are booleans or numbers when they can be
+// parsed as such, strings otherwise
+func parseContextValue(value string, valueType string) (interface{}, error) {
+	switch valueType {
+	case "string":
+		return value, nil
+	case "number":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, errors.New("value must be a number")
+		}
+		return number, nil
+	case "bool":
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("value must be a boolean")
+		}
+		return boolean, nil
+	case "":
+		if value == "true" || value == "false" {
+			return value == "true", nil
+		}
+		if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+			return number, nil
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown type %s, must be string, number or bool", valueType)
+}
+
+// GetBatchDecisionRequests transforms a http request with a JSON array body into DecisionRequests.
+// An invalid item has a nil DecisionRequest and its parsing error at the same index
+func GetBatchDecisionRequests(r *http.Request) ([]*decision_request.DecisionRequest, []error, error) {
+	if r.Method != http.MethodPost {
+		return nil, nil, errors.New("only POST http method is allowed")
+	}
+
+	items := []json.RawMessage{}
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		return nil, nil, fmt.Errorf("syntax error in body json request. Must be a valid json array : %s", err.Error())
+	}
+
+	requests := make([]*decision_request.DecisionRequest, len(items))
+	errs := make([]error, len(items))
+	for i, item := range items {
+		requests[i], errs[i] = parseJSONBody(item)
+	}
+	return requests, errs, nil
+}
+
+func unmarshalHit(r *http.Request) (*decision_request.DecisionRequest, string, error) {
+	switch r.Method {
+	case http.MethodPost:
 		return unmarshalPost(r)
+	case http.MethodGet:
+		return getDecisionRequestFromQuery(r)
+	}
+	return nil, "", errors.New("only GET and POST http methods are allowed")
+}
+
+// parseDecisionBody parses a JSON decision request whose forced variations token field is not part of the DecisionRequest
+func parseDecisionBody(data []byte) (*decision_request.DecisionRequest, string, error) {
+	forcedVariationsToken := ""
+	if bytes.Contains(data, []byte(`"`+ForcedVariationsField+`"`)) {
+		fields := map[string]json.RawMessage{}
+		// invalid JSON bodies are reported by the DecisionRequest parsing
+		if err := json.Unmarshal(data, &fields); err == nil && fields[ForcedVariationsField] != nil {
+			if err := json.Unmarshal(fields[ForcedVariationsField], &forcedVariationsToken); err != nil {
+				return nil, "", fmt.Errorf("syntax error in body json request. %s must be a string", ForcedVariationsField)
+			}
+			delete(fields, ForcedVariationsField)
+			if data, err = json.Marshal(fields); err != nil {
+				return nil, "", err
+			}
+		}
 	}
-	return nil, errors.New("only POST http method is allowed")
+
+	decisionRequest, err := parseJSONBody(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return decisionRequest, forcedVariationsToken, nil
 }
 
 func parseJSONBody(data []byte) (*decision_request.DecisionRequest, error) {
@@ -47,10 +220,65 @@ func parseJSONBody(data []byte) (*decision_request.DecisionRequest, error) {
 	return decisionRequest, nil
 }
 
-func unmarshalPost(r *http.Request) (*decision_request.DecisionRequest, error) {
+func unmarshalPost(r *http.Request) (*decision_request.DecisionRequest, string, error) {
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return parseDecisionBody(data)
+}
+
+// ClientIP returns the IP of the client. If the X-Forwarded-For header is trusted, it returns its rightmost IP that is not
+// a trusted proxy, as the IPs on the left can be set by the client
+func ClientIP(r *http.Request, trustForwardedFor bool, trustedProxies []*net.IPNet) string {
+	return ForwardedClientIP(strings.Join(r.Header.Values("X-Forwarded-For"), ","), r.RemoteAddr, trustForwardedFor, trustedProxies)
+}
+
+// ForwardedClientIP returns the IP of the client like ClientIP, from the X-Forwarded-For value and the remote address
+func ForwardedClientIP(forwardedFor string, remoteAddr string, trustForwardedFor bool, trustedProxies []*net.IPNet) string {
+	if trustForwardedFor && strings.TrimSpace(forwardedFor) != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			// an invalid hop is not a trusted proxy, so the hops on its left are not trusted either
+			if i == 0 || ip == nil || !containsIP(trustedProxies, ip) {
+				return hop
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// ParseNetworks parses a list of IPs and CIDR ranges. An IP is parsed as the range of this single IP
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if strings.Contains(value, ":") {
+				value += "/128"
+			} else {
+				value += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
 	}
-	return parseJSONBody(data)
+	return false
 }
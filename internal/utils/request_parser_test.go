@@ -1,20 +1,28 @@
 package utils
 
 import (
+	"encoding/base64"
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/flagship-io/flagship-proto/decision_request"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetDecisionRequest(t *testing.T) {
 	_, err := GetDecisionRequest(&http.Request{
-		Method: "GET",
+		Method: "PUT",
 	})
-	assert.Equal(t, errors.New("only POST http method is allowed"), err)
+	assert.Equal(t, errors.New("only GET and POST http methods are allowed"), err)
+
+	r, err := GetDecisionRequest(httptest.NewRequest(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_1", r.VisitorId.GetValue())
 
 	_, err = GetDecisionRequest(&http.Request{
 		Method: "POST",
@@ -28,10 +36,133 @@ func TestGetDecisionRequest(t *testing.T) {
 	})
 	assert.Contains(t, err.Error(), "json body is not valid")
 
-	r, err := GetDecisionRequest(&http.Request{
+	r, err = GetDecisionRequest(&http.Request{
 		Method: "POST",
 		Body:   io.NopCloser(strings.NewReader("{}")),
 	})
 	assert.Nil(t, err)
 	assert.Equal(t, "", r.VisitorId.GetValue())
 }
+
+func TestGetDecisionRequestAndForcedVariations(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id":"visitor_1","forced_variations":"body_token"}`))
+	req.Header.Set(ForcedVariationsHeader, "header_token")
+	r, token, err := GetDecisionRequestAndForcedVariations(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_1", r.VisitorId.GetValue())
+	assert.Equal(t, "body_token", token)
+
+	req = httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id":"visitor_1"}`))
+	req.Header.Set(ForcedVariationsHeader, "header_token")
+	_, token, err = GetDecisionRequestAndForcedVariations(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "header_token", token)
+
+	_, token, err = GetDecisionRequestAndForcedVariations(httptest.NewRequest(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1&forced_variations=query_token", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "query_token", token)
+
+	_, _, err = GetDecisionRequestAndForcedVariations(httptest.NewRequest(http.MethodPost, "/v2/campaigns", strings.NewReader(`{"visitor_id":"visitor_1","forced_variations":{}}`)))
+	assert.Contains(t, err.Error(), "forced_variations must be a string")
+
+	// the forced variations are not part of the batch decision requests
+	_, errs, err := GetBatchDecisionRequests(httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch", strings.NewReader(`[{"visitor_id":"visitor_1","forced_variations":"token"}]`)))
+	assert.Nil(t, err)
+	assert.Contains(t, errs[0].Error(), "json body is not valid")
+}
+
+func TestGetDecisionRequestFromQuery(t *testing.T) {
+	getRequest := func(query url.Values) (*decision_request.DecisionRequest, error) {
+		return GetDecisionRequestFromQuery(httptest.NewRequest(http.MethodGet, "/v2/campaigns?"+query.Encode(), nil))
+	}
+
+	r, err := getRequest(url.Values{
+		"visitor_id":          {"visitor_1"},
+		"anonymous_id":        {"anonymous_1"},
+		"trigger_hit":         {"false"},
+		"context":             {`{"plan":"premium","age":32}`},
+		"context.vip":         {"true"},
+		"context.visits":      {"12"},
+		"context.zip:string":  {"75001"},
+		"context.age:number":  {"33"},
+		"context.beta:bool":   {"1"},
+		"context.city":        {"Paris"},
+		"context.nan":         {"NaN"},
+		"unknown_query_param": {"value"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_1", r.VisitorId.GetValue())
+	assert.Equal(t, "anonymous_1", r.AnonymousId.GetValue())
+	assert.False(t, r.TriggerHit.GetValue())
+	assert.Equal(t, "premium", r.Context["plan"].GetStringValue())
+	assert.Equal(t, 33., r.Context["age"].GetNumberValue())
+	assert.True(t, r.Context["vip"].GetBoolValue())
+	assert.Equal(t, 12., r.Context["visits"].GetNumberValue())
+	assert.Equal(t, "75001", r.Context["zip"].GetStringValue())
+	assert.True(t, r.Context["beta"].GetBoolValue())
+	assert.Equal(t, "Paris", r.Context["city"].GetStringValue())
+	assert.Equal(t, "NaN", r.Context["nan"].GetStringValue())
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"visitor_id":"visitor_2","context":{"plan":"free"}}`))
+	r, err = getRequest(url.Values{"payload": {payload}, "visitor_id": {"ignored"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_2", r.VisitorId.GetValue())
+	assert.Equal(t, "free", r.Context["plan"].GetStringValue())
+
+	_, err = getRequest(url.Values{"payload": {"not base64!"}})
+	assert.Contains(t, err.Error(), "must be base64url encoded")
+
+	_, err = getRequest(url.Values{"context": {"{"}})
+	assert.Contains(t, err.Error(), "must be a valid json object")
+
+	_, err = getRequest(url.Values{"context": {`["a"]`}})
+	assert.NotNil(t, err)
+
+	_, err = getRequest(url.Values{"trigger_hit": {"maybe"}})
+	assert.Contains(t, err.Error(), "trigger_hit query parameter must be a boolean")
+
+	_, err = getRequest(url.Values{"context.age:number": {"old"}})
+	assert.Contains(t, err.Error(), "value must be a number")
+
+	_, err = getRequest(url.Values{"context.age:date": {"2024-01-01"}})
+	assert.Contains(t, err.Error(), "unknown type date")
+}
+
+func TestGetBatchDecisionRequests(t *testing.T) {
+	_, _, err := GetBatchDecisionRequests(httptest.NewRequest(http.MethodGet, "/v2/campaigns/batch", nil))
+	assert.Equal(t, errors.New("only POST http method is allowed"), err)
+
+	_, _, err = GetBatchDecisionRequests(httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch", strings.NewReader(`{"visitor_id":"visitor_1"}`)))
+	assert.Contains(t, err.Error(), "Must be a valid json array")
+
+	requests, errs, err := GetBatchDecisionRequests(httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch",
+		strings.NewReader(`[{"visitor_id":"visitor_1"},{"wrong_key":true}]`)))
+	assert.Nil(t, err)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "visitor_1", requests[0].VisitorId.GetValue())
+	assert.Nil(t, errs[0])
+	assert.Nil(t, requests[1])
+	assert.Contains(t, errs[1].Error(), "json body is not valid")
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "1.1.1.1:1234"
+	req.Header.Set("X-Forwarded-For", "3.3.3.3, 2.2.2.2")
+	assert.Equal(t, "1.1.1.1", ClientIP(req, false, nil))
+	// the leftmost IPs can be set by the client
+	assert.Equal(t, "2.2.2.2", ClientIP(req, true, nil))
+
+	proxies, err := ParseNetworks([]string{"2.2.2.0/24", "4.4.4.4"})
+	assert.Nil(t, err)
+	req.Header.Add("X-Forwarded-For", "4.4.4.4")
+	assert.Equal(t, "3.3.3.3", ClientIP(req, true, proxies))
+
+	req.Header.Set("X-Forwarded-For", "2.2.2.1, 4.4.4.4")
+	assert.Equal(t, "2.2.2.1", ClientIP(req, true, proxies))
+	req.Header.Set("X-Forwarded-For", "3.3.3.3, invalid, 4.4.4.4")
+	assert.Equal(t, "invalid", ClientIP(req, true, proxies))
+
+	_, err = ParseNetworks([]string{"not an ip"})
+	assert.NotNil(t, err)
+}
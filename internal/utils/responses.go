@@ -2,9 +2,12 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/flagship-io/decision-api/internal/validation"
+
 	"github.com/flagship-io/flagship-proto/decision_response"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -15,6 +18,12 @@ type ClientErrorMessage struct {
 	Message string `json:"message"`
 }
 
+// ClientErrorCodeMessage represents a client error response with a machine readable error code
+type ClientErrorCodeMessage struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 // WriteServerError returns a 500 Internal Server Error response
 func WriteServerError(w http.ResponseWriter, err error) {
 	body := &ClientErrorMessage{Message: err.Error()}
@@ -37,6 +46,33 @@ func WriteClientError(w http.ResponseWriter, status int, message string) {
 	}
 }
 
+// WriteClientErrorCode returns a client error response with a machine readable error code
+func WriteClientErrorCode(w http.ResponseWriter, status int, code string, message string) {
+	body := &ClientErrorCodeMessage{Code: code, Message: message}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	jsonErr := json.NewEncoder(w).Encode(body)
+	if jsonErr != nil {
+		log.Printf("error when encoding body: %v", jsonErr)
+	}
+}
+
+// WriteRequestError writes a 400 Bad Request response for an invalid decision request.
+// The validation errors are written as is
+func WriteRequestError(w http.ResponseWriter, err error) {
+	var validationErr *validation.ErrorResponse
+	if !errors.As(err, &validationErr) {
+		WriteClientError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	jsonErr := json.NewEncoder(w).Encode(validationErr)
+	if jsonErr != nil {
+		log.Printf("error when encoding body: %v", jsonErr)
+	}
+}
+
 // WriteJSONStringOk similarly add a helper to send json stringified responses with status OK.
 func WriteJSONStringOk(w http.ResponseWriter, data string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -63,8 +99,8 @@ func WriteNoContent(w http.ResponseWriter) {
 	w.WriteHeader(204)
 }
 
-// WritePanicResponse writes a response for panic mode
-func WritePanicResponse(w http.ResponseWriter, visitorID *wrapperspb.StringValue) {
+// PanicResponse returns the JSON response for panic mode
+func PanicResponse(visitorID *wrapperspb.StringValue) ([]byte, error) {
 	decisionResponse := decision_response.DecisionResponsePanic{}
 	decisionResponse.Campaigns = []*decision_response.Campaign{}
 	decisionResponse.VisitorId = visitorID
@@ -73,7 +109,12 @@ func WritePanicResponse(w http.ResponseWriter, visitorID *wrapperspb.StringValue
 	marshalOptions := protojson.MarshalOptions{
 		EmitUnpopulated: true,
 	}
-	data, err := marshalOptions.Marshal(&decisionResponse)
+	return marshalOptions.Marshal(&decisionResponse)
+}
+
+// WritePanicResponse writes a response for panic mode
+func WritePanicResponse(w http.ResponseWriter, visitorID *wrapperspb.StringValue) {
+	data, err := PanicResponse(visitorID)
 	if err != nil {
 		WriteServerError(w, err)
 		return
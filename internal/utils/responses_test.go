@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/flagship-io/decision-api/internal/validation"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -32,6 +33,28 @@ func TestWriteClientError(t *testing.T) {
 	assert.Equal(t, "{\"message\":\"test_error\"}\n", string(json))
 }
 
+func TestWriteClientErrorCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteClientErrorCode(w, 401, "test_code", "test_error")
+	resp := w.Result()
+	json, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "{\"code\":\"test_code\",\"message\":\"test_error\"}\n", string(json))
+}
+
+func TestWriteRequestError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteRequestError(w, errors.New("test_error"))
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "{\"message\":\"test_error\"}\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteRequestError(w, validation.BuildErrorResponse(map[string]string{"context.age": "Expected a number."}))
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "{\"status\":\"error\",\"errors\":{\"context.age\":\"Expected a number.\"}}\n", w.Body.String())
+}
+
 // WriteJSONStringOk similarly add a helper to send json stringified responses with status OK.
 func TestWriteJSONStringOk(t *testing.T) {
 	w := httptest.NewRecorder()
@@ -0,0 +1,96 @@
+package useragent
+
+import "strings"
+
+// The device types of the user agents
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// UserAgent is the device type, OS and browser of a User-Agent header. Unknown values are empty
+type UserAgent struct {
+	DeviceType string
+	OS         string
+	Browser    string
+}
+
+type token struct {
+	match string
+	name  string
+}
+
+// the tokens are ordered so that the first match wins, as many user agents also hold the tokens of other browsers and OS
+var osTokens = []token{
+	{"windows phone", "Windows Phone"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"cros", "Chrome OS"},
+	{"windows", "Windows"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+var browserTokens = []token{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "headless", "curl/", "wget/"}
+
+// Parse returns the device type, OS and browser of a User-Agent header
+func Parse(header string) UserAgent {
+	ua := strings.ToLower(header)
+	if ua == "" {
+		return UserAgent{}
+	}
+	result := UserAgent{
+		OS:      firstMatch(ua, osTokens),
+		Browser: firstMatch(ua, browserTokens),
+	}
+
+	switch {
+	case containsAny(ua, botTokens):
+		result.DeviceType = DeviceBot
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		result.DeviceType = DeviceTablet
+	case strings.Contains(ua, "mobile") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		result.DeviceType = DeviceMobile
+	case result.OS != "":
+		result.DeviceType = DeviceDesktop
+	}
+	return result
+}
+
+func firstMatch(ua string, tokens []token) string {
+	for _, t := range tokens {
+		if strings.Contains(ua, t.match) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+func containsAny(ua string, matches []string) bool {
+	for _, match := range matches {
+		if strings.Contains(ua, match) {
+			return true
+		}
+	}
+	return false
+}
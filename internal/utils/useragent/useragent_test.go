@@ -0,0 +1,32 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		header string
+		result UserAgent
+	}{
+		"Empty":          {"", UserAgent{}},
+		"ChromeWindows":  {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", UserAgent{DeviceDesktop, "Windows", "Chrome"}},
+		"EdgeWindows":    {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0", UserAgent{DeviceDesktop, "Windows", "Edge"}},
+		"SafariMac":      {"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15", UserAgent{DeviceDesktop, "macOS", "Safari"}},
+		"FirefoxLinux":   {"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", UserAgent{DeviceDesktop, "Linux", "Firefox"}},
+		"SafariIPhone":   {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1", UserAgent{DeviceMobile, "iOS", "Safari"}},
+		"ChromeIPad":     {"Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1", UserAgent{DeviceTablet, "iOS", "Chrome"}},
+		"SamsungAndroid": {"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36", UserAgent{DeviceMobile, "Android", "Samsung Internet"}},
+		"AndroidTablet":  {"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", UserAgent{DeviceTablet, "Android", "Chrome"}},
+		"Googlebot":      {"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", UserAgent{DeviceBot, "", ""}},
+		"Curl":           {"curl/8.4.0", UserAgent{DeviceBot, "", ""}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.result, Parse(test.header))
+		})
+	}
+}
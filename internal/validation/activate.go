@@ -1,6 +1,8 @@
 package validation
 
 import (
+	"encoding/json"
+
 	"github.com/flagship-io/flagship-proto/activate_request"
 )
 
@@ -9,6 +11,12 @@ type ErrorResponse struct {
 	Errors map[string]string `json:"errors"`
 }
 
+// Error returns the JSON error response, so that it can be returned as an error
+func (e *ErrorResponse) Error() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
 func BuildErrorResponse(bodyError map[string]string) *ErrorResponse {
 	return &ErrorResponse{
 		Status: "error",
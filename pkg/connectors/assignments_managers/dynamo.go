@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	common "github.com/flagship-io/flagship-common"
 )
@@ -179,3 +180,32 @@ func (d *DynamoManager) SaveAssignments(envID string, visitorID string, vgIDAssi
 	}
 	return err
 }
+
+// DeleteAssignments deletes the assignments item of the visitor
+func (d *DynamoManager) DeleteAssignments(envID string, visitorID string) error {
+	_, err := d.options.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(d.options.TableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			d.options.PrimaryKeyField: {
+				S: aws.String(d.getPrimaryKey(envID, visitorID)),
+			},
+		},
+	})
+	return err
+}
+
+// Reload changes the log level and format of the manager
+func (d *DynamoManager) Reload(options *models.ReloadOptions) error {
+	if d.logger == nil || options.LogLevel == "" {
+		return nil
+	}
+	return d.logger.Configure(options.LogLevel, logger.LogFormat(options.LogFormat))
+}
+
+// HealthCheck checks that the dynamo table can be described
+func (d *DynamoManager) HealthCheck(ctx context.Context) error {
+	_, err := d.options.Client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(d.options.TableName),
+	})
+	return err
+}
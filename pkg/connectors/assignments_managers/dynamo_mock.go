@@ -71,3 +71,14 @@ func (m *mockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynam
 	lock.Unlock()
 	return &dynamodb.UpdateItemOutput{}, nil
 }
+
+func (m *mockDynamoDBClient) DescribeTableWithContext(ctx context.Context, input *dynamodb.DescribeTableInput, options ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	lock.Lock()
+	delete(m.assignments, *input.Key["id"].S)
+	lock.Unlock()
+	return &dynamodb.DeleteItemOutput{}, nil
+}
@@ -1,6 +1,7 @@
 package assignments_managers
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -28,6 +29,8 @@ func TestDynamoAssignmentsManager(t *testing.T) {
 		logger: logger.New("info", logger.FORMAT_TEXT, "dynamodbManager"),
 	}
 
+	assert.Nil(t, d.HealthCheck(context.Background()))
+
 	assignments, err := d.LoadAssignments(envID, visitorID)
 
 	assert.Nil(t, err)
@@ -57,4 +60,10 @@ func TestDynamoAssignmentsManager(t *testing.T) {
 		AssignmentScope: connectors.Activation,
 	})
 	assert.True(t, shouldSaveAssignments)
+
+	err = d.DeleteAssignments(envID, visitorID)
+	assert.Nil(t, err)
+	assignments, err = d.LoadAssignments(envID, visitorID)
+	assert.Nil(t, err)
+	assert.Nil(t, assignments)
 }
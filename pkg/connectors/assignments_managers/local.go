@@ -1,6 +1,7 @@
 package assignments_managers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -103,6 +104,19 @@ func (m *LocalManager) SaveAssignments(envID string, visitorID string, vgIDAssig
 	return err
 }
 
+// DeleteAssignments deletes the assignments of the visitor
+func (m *LocalManager) DeleteAssignments(envID string, visitorID string) error {
+	if m.db == nil {
+		return errors.New("local cache manager not initialized")
+	}
+
+	key := []byte(envID + m.keySeparator + visitorID)
+	if !m.db.Has(key) {
+		return nil
+	}
+	return m.db.Delete(key)
+}
+
 // Dispose frees IO resources
 func (m *LocalManager) Dispose() error {
 	if m.db == nil {
@@ -110,3 +124,17 @@ func (m *LocalManager) Dispose() error {
 	}
 	return m.db.Close()
 }
+
+// Close closes the local db when the server shuts down
+func (m *LocalManager) Close() error {
+	return m.Dispose()
+}
+
+// HealthCheck checks that the local db is open
+func (m *LocalManager) HealthCheck(ctx context.Context) error {
+	if m.db == nil {
+		return errors.New("local cache manager not initialized")
+	}
+	_, err := m.db.Stats()
+	return err
+}
@@ -1,6 +1,7 @@
 package assignments_managers
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -21,10 +22,13 @@ func TestLocalCache(t *testing.T) {
 	err = notInitialized.SaveAssignments(envID, visID, nil, time.Now())
 	assert.Equal(t, "local cache manager not initialized", err.Error())
 
+	assert.NotNil(t, notInitialized.HealthCheck(context.Background()))
+
 	m, err := InitLocalCacheManager(LocalOptions{
 		DbPath: testFolder,
 	})
 	assert.Nil(t, err)
+	assert.Nil(t, m.HealthCheck(context.Background()))
 
 	r, err := m.LoadAssignments(envID, visID)
 
@@ -55,7 +59,14 @@ func TestLocalCache(t *testing.T) {
 	assert.Equal(t, "vID2", r.Assignments["vgID2"].VariationID)
 	assert.Equal(t, true, r.Assignments["vgID2"].Activated)
 
-	err = m.Dispose()
+	err = m.DeleteAssignments(envID, visID)
+	assert.Nil(t, err)
+	r, err = m.LoadAssignments(envID, visID)
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	assert.Nil(t, m.DeleteAssignments(envID, visID))
+
+	err = m.Close()
 	assert.Nil(t, err)
 
 	err = os.RemoveAll(testFolder)
@@ -52,3 +52,11 @@ func (m *MemoryManager) SaveAssignments(envID string, visitorID string, vgIDAssi
 	m.lock.Unlock()
 	return nil
 }
+
+// DeleteAssignments deletes the assignments of the visitor
+func (m *MemoryManager) DeleteAssignments(envID string, visitorID string) error {
+	m.lock.Lock()
+	delete(m.cache, envID+m.separator+visitorID)
+	m.lock.Unlock()
+	return nil
+}
@@ -51,4 +51,10 @@ func TestMemoryCache(t *testing.T) {
 		AssignmentScope: connectors.Activation,
 	})
 	assert.True(t, shouldSaveAssignments)
+
+	err = m.DeleteAssignments(envID, visID)
+	assert.Nil(t, err)
+	r, err = m.LoadAssignments(envID, visID)
+	assert.Nil(t, err)
+	assert.Nil(t, r)
 }
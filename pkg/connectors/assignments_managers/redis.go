@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	common "github.com/flagship-io/flagship-common"
 	"github.com/go-redis/redis/v8"
@@ -17,9 +18,11 @@ import (
 
 // RedisManager represents a redis db manager object
 type RedisManager struct {
-	client *redis.Client
-	logger *logger.Logger
-	TTL    time.Duration
+	client      *redis.Client
+	logger      *logger.Logger
+	separator   string
+	legacyEnvID string
+	TTL         time.Duration
 }
 
 // RedisOptions are the options necessary to make redis cache manager work
@@ -32,6 +35,9 @@ type RedisOptions struct {
 	LogLevel  string
 	LogFormat logger.LogFormat
 	TTL       time.Duration
+	// LegacyEnvID is the environment of the assignments saved under the visitor ID key, before the keys were scoped
+	// by environment. They are migrated to the scoped keys of this environment only. No migration is done if empty
+	LegacyEnvID string
 }
 
 var rdb *redis.Client
@@ -57,12 +63,47 @@ func InitRedisManager(options RedisOptions) (*RedisManager, error) {
 	logger.Info("Successfully connected to redis server")
 
 	return &RedisManager{
-		client: rdb,
-		logger: logger,
-		TTL:    options.TTL,
+		client:      rdb,
+		logger:      logger,
+		separator:   ".",
+		legacyEnvID: options.LegacyEnvID,
+		TTL:         options.TTL,
 	}, nil
 }
 
+// getKey returns the visitor key scoped by environment
+func (m *RedisManager) getKey(envID string, visitorID string) string {
+	return envID + m.separator + visitorID
+}
+
+// isLegacyEnv returns true if the assignments saved under the visitor ID key belong to the environment
+func (m *RedisManager) isLegacyEnv(envID string) bool {
+	return m.legacyEnvID != "" && envID == m.legacyEnvID
+}
+
+// loadLegacyAssignments reads the assignments saved under the visitor ID key, before the keys were scoped
+// by environment, and copies them to the scoped key. The legacy key is left to expire, as it can still be written
+// by the instances running the previous version during an upgrade
+func (m *RedisManager) loadLegacyAssignments(key string, visitorID string) (map[string]string, error) {
+	data, err := m.client.HGetAll(ctx, visitorID).Result()
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+
+	m.logger.Infof("Migrating legacy visitor cache for ID %s", visitorID)
+	values := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		values[k] = v
+	}
+	pipe := m.client.Pipeline()
+	pipe.HSet(ctx, key, values)
+	pipe.Expire(ctx, key, m.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		m.logger.Warnf("Error when migrating legacy visitor cache for ID %s: %v", visitorID, err)
+	}
+	return data, nil
+}
+
 // Get returns the campaigns in cache for this visitor
 func (m *RedisManager) LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error) {
 	if m.client == nil {
@@ -70,8 +111,12 @@ func (m *RedisManager) LoadAssignments(envID string, visitorID string) (*common.
 	}
 
 	m.logger.Infof("Getting visitor cache for ID %s", visitorID)
-	cmd := m.client.HGetAll(ctx, visitorID)
+	key := m.getKey(envID, visitorID)
+	cmd := m.client.HGetAll(ctx, key)
 	data, err := cmd.Result()
+	if err == nil && len(data) == 0 && m.isLegacyEnv(envID) {
+		data, err = m.loadLegacyAssignments(key, visitorID)
+	}
 
 	if err != nil {
 		if err == redis.Nil {
@@ -119,6 +164,35 @@ func (m *RedisManager) SaveAssignments(envID string, visitorID string, vgIDAssig
 	}
 
 	m.logger.Infof("Setting visitor cache for ID %s", visitorID)
+	pipe := m.client.Pipeline()
+	if err := m.queueAssignments(pipe, envID, visitorID, vgIDAssignments, date); err != nil {
+		return err
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SaveAssignmentsBulk saves the assignments of several visitors in a single pipeline
+func (m *RedisManager) SaveAssignmentsBulk(envID string, assignments []*connectors.VisitorAssignmentsSave) error {
+	if m.client == nil {
+		return errors.New("redis cache manager not initialized")
+	}
+
+	m.logger.Infof("Setting visitor cache for %d visitors", len(assignments))
+	pipe := m.client.Pipeline()
+	for _, a := range assignments {
+		if err := m.queueAssignments(pipe, envID, a.VisitorID, a.Assignments, a.Date); err != nil {
+			return err
+		}
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// queueAssignments adds the commands saving the visitor assignments to the pipeline
+func (m *RedisManager) queueAssignments(pipe redis.Pipeliner, envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
 	values := map[string]interface{}{}
 	for k, v := range vgIDAssignments {
 		data, err := json.Marshal(v)
@@ -129,10 +203,47 @@ func (m *RedisManager) SaveAssignments(envID string, visitorID string, vgIDAssig
 	}
 	values["ts"] = fmt.Sprintf("%d", date.UnixMilli())
 
-	pipe := m.client.Pipeline()
-	pipe.HSet(ctx, visitorID, values)
-	pipe.Expire(ctx, visitorID, m.TTL)
+	key := m.getKey(envID, visitorID)
+	pipe.HSet(ctx, key, values)
+	pipe.Expire(ctx, key, m.TTL)
+	return nil
+}
 
-	_, err := pipe.Exec(ctx)
-	return err
+// DeleteAssignments deletes the assignments of the visitor
+func (m *RedisManager) DeleteAssignments(envID string, visitorID string) error {
+	if m.client == nil {
+		return errors.New("redis cache manager not initialized")
+	}
+
+	m.logger.Infof("Deleting visitor cache for ID %s", visitorID)
+	keys := []string{m.getKey(envID, visitorID)}
+	if m.isLegacyEnv(envID) {
+		// the legacy key is deleted too, so that the assignments are not migrated back
+		keys = append(keys, visitorID)
+	}
+	return m.client.Del(ctx, keys...).Err()
+}
+
+// Reload changes the log level and format of the manager
+func (m *RedisManager) Reload(options *models.ReloadOptions) error {
+	if m.logger == nil || options.LogLevel == "" {
+		return nil
+	}
+	return m.logger.Configure(options.LogLevel, logger.LogFormat(options.LogFormat))
+}
+
+// Close closes the redis client when the server shuts down
+func (m *RedisManager) Close() error {
+	if m.client == nil {
+		return nil
+	}
+	return m.client.Close()
+}
+
+// HealthCheck pings the redis server
+func (m *RedisManager) HealthCheck(ctx context.Context) error {
+	if m.client == nil {
+		return errors.New("redis cache manager not initialized")
+	}
+	return m.client.Ping(ctx).Err()
 }
@@ -1,6 +1,7 @@
 package assignments_managers
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -38,6 +39,8 @@ func TestRedisCache(t *testing.T) {
 	})
 
 	assert.Equal(t, nil, err)
+	assert.Nil(t, m.HealthCheck(context.Background()))
+	assert.NotNil(t, notInitialized.HealthCheck(context.Background()))
 
 	r, err := m.LoadAssignments(envID, visID)
 
@@ -69,6 +72,11 @@ func TestRedisCache(t *testing.T) {
 	assert.Equal(t, "vID2", r.Assignments["vgID2"].VariationID)
 	assert.Equal(t, true, r.Assignments["vgID2"].Activated)
 
+	// assignments are scoped by environment
+	r, err = m.LoadAssignments("other_env_id", visID)
+	assert.Equal(t, nil, err)
+	assert.Nil(t, r)
+
 	cache.Assignments["expireVG"] = &decision.VisitorCache{VariationID: "expireVID"}
 	m.TTL = time.Second
 	err = m.SaveAssignments(envID, visID, cache.Assignments, time.Now())
@@ -87,4 +95,55 @@ func TestRedisCache(t *testing.T) {
 		AssignmentScope: connectors.Activation,
 	})
 	assert.True(t, shouldSaveAssignments)
+
+	err = m.SaveAssignmentsBulk(envID, []*connectors.VisitorAssignmentsSave{
+		{VisitorID: "visID1", Assignments: map[string]*decision.VisitorCache{"vgID": {VariationID: "vID1"}}, Date: time.Now()},
+		{VisitorID: "visID2", Assignments: map[string]*decision.VisitorCache{"vgID": {VariationID: "vID2"}}, Date: time.Now()},
+	})
+	assert.Nil(t, err)
+	r, err = m.LoadAssignments(envID, "visID2")
+	assert.Nil(t, err)
+	assert.Equal(t, "vID2", r.Assignments["vgID"].VariationID)
+	assert.NotNil(t, notInitialized.SaveAssignmentsBulk(envID, nil))
+
+	// the assignments saved under the legacy visitor ID key are not migrated unless the legacy environment is set
+	s.HSet("legacyVisID", "vgID", `{"VariationID":"legacyVID","Activated":true}`, "ts", "1000")
+	r, err = m.LoadAssignments(envID, "legacyVisID")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	// and only to the legacy environment
+	m.legacyEnvID = envID
+	r, err = m.LoadAssignments("other_env_id", "legacyVisID")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	assert.False(t, s.Exists("other_env_id.legacyVisID"))
+	assert.Nil(t, m.DeleteAssignments("other_env_id", "legacyVisID"))
+	assert.True(t, s.Exists("legacyVisID"))
+
+	// the legacy assignments are migrated to the scoped key of the legacy environment
+	r, err = m.LoadAssignments(envID, "legacyVisID")
+	assert.Nil(t, err)
+	assert.Equal(t, "legacyVID", r.Assignments["vgID"].VariationID)
+	assert.True(t, r.Assignments["vgID"].Activated)
+	assert.EqualValues(t, 1000, r.Timestamp)
+	assert.Contains(t, s.HGet(envID+".legacyVisID", "vgID"), "legacyVID")
+	assert.Equal(t, time.Second, s.TTL(envID+".legacyVisID"))
+	assert.True(t, s.Exists("legacyVisID"))
+	assert.Nil(t, m.DeleteAssignments(envID, "legacyVisID"))
+	assert.False(t, s.Exists("legacyVisID"))
+	r, err = m.LoadAssignments(envID, "legacyVisID")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	err = m.DeleteAssignments(envID, visID)
+	assert.Nil(t, err)
+	r, err = m.LoadAssignments(envID, visID)
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+	assert.NotNil(t, notInitialized.DeleteAssignments(envID, visID))
+
+	assert.Nil(t, m.Close())
+	assert.NotNil(t, m.HealthCheck(context.Background()))
+	assert.Nil(t, notInitialized.Close())
 }
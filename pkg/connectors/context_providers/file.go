@@ -0,0 +1,39 @@
+package context_providers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+)
+
+// FileProvider serves the visitor contexts of a static JSON file
+type FileProvider struct {
+	contexts map[string]map[string]connectors.ProvidersContext
+}
+
+// FileOptions are the options of the static file provider
+type FileOptions struct {
+	// Path is the path of the JSON file, mapping the environment IDs to the visitor IDs to the contexts by provider
+	Path string
+}
+
+// InitFileProvider loads the visitor contexts of the file
+func InitFileProvider(options FileOptions) (*FileProvider, error) {
+	data, err := os.ReadFile(options.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &FileProvider{}
+	if err := json.Unmarshal(data, &p.contexts); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadVisitorContext returns the visitor context of the file
+func (p *FileProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	return p.contexts[envID][visitorID], nil
+}
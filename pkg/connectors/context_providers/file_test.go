@@ -0,0 +1,33 @@
+package context_providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileProvider(t *testing.T) {
+	_, err := InitFileProvider(FileOptions{Path: filepath.Join(t.TempDir(), "missing.json")})
+	assert.NotNil(t, err)
+
+	path := filepath.Join(t.TempDir(), "contexts.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{`), 0600))
+	_, err = InitFileProvider(FileOptions{Path: path})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"env_id": {"visitor_id": {"crm": {"plan": "premium", "age": 30}}}}`), 0600))
+	p, err := InitFileProvider(FileOptions{Path: path})
+	assert.Nil(t, err)
+
+	result, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.ProvidersContext{"crm": {"plan": "premium", "age": 30.}}, result)
+
+	result, err = p.LoadVisitorContext(context.Background(), "other_env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,103 @@
+package context_providers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultRedisKeyPrefix is the prefix of the visitor context keys
+const DefaultRedisKeyPrefix = "visitor_context:"
+
+// RedisProvider reads the visitor contexts from redis hashes, mapping the providers to their context as a JSON object
+type RedisProvider struct {
+	client    *redis.Client
+	keyPrefix string
+	separator string
+}
+
+// RedisOptions are the options of the redis provider
+type RedisOptions struct {
+	Host      string
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+	Db        int
+	// KeyPrefix is the prefix of the visitor context keys. DefaultRedisKeyPrefix is used if empty
+	KeyPrefix string
+}
+
+// InitRedisProvider connects to the redis server
+func InitRedisProvider(options RedisOptions) (*RedisProvider, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:      options.Host,
+		Username:  options.Username,
+		TLSConfig: options.TLSConfig,
+		Password:  options.Password,
+		DB:        options.Db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = DefaultRedisKeyPrefix
+	}
+	return &RedisProvider{
+		client:    client,
+		keyPrefix: options.KeyPrefix,
+		separator: ".",
+	}, nil
+}
+
+// getKey returns the visitor key scoped by environment
+func (p *RedisProvider) getKey(envID string, visitorID string) string {
+	return p.keyPrefix + envID + p.separator + visitorID
+}
+
+// LoadVisitorContext returns the visitor context stored in redis
+func (p *RedisProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	if p.client == nil {
+		return nil, errors.New("redis context provider not initialized")
+	}
+
+	data, err := p.client.HGetAll(ctx, p.getKey(envID, visitorID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	result := connectors.ProvidersContext{}
+	for provider, value := range data {
+		providerContext := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(value), &providerContext); err != nil {
+			return nil, fmt.Errorf("invalid context of provider %s: %w", provider, err)
+		}
+		result[provider] = providerContext
+	}
+	return result, nil
+}
+
+// Close closes the redis client when the server shuts down
+func (p *RedisProvider) Close() error {
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
+}
+
+// HealthCheck pings the redis server
+func (p *RedisProvider) HealthCheck(ctx context.Context) error {
+	if p.client == nil {
+		return errors.New("redis context provider not initialized")
+	}
+	return p.client.Ping(ctx).Err()
+}
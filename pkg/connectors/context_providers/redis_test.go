@@ -0,0 +1,44 @@
+package context_providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisProvider(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	_, err = InitRedisProvider(RedisOptions{Host: "localhost:4567"})
+	assert.NotNil(t, err)
+
+	notInitialized := &RedisProvider{}
+	_, err = notInitialized.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.EqualError(t, err, "redis context provider not initialized")
+	assert.NotNil(t, notInitialized.HealthCheck(context.Background()))
+
+	p, err := InitRedisProvider(RedisOptions{Host: s.Addr()})
+	assert.Nil(t, err)
+	defer p.Close()
+	assert.Nil(t, p.HealthCheck(context.Background()))
+
+	result, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+
+	s.HSet(DefaultRedisKeyPrefix+"env_id.visitor_id", "crm", `{"plan": "premium", "vip": true}`)
+	result, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.ProvidersContext{"crm": {"plan": "premium", "vip": true}}, result)
+
+	s.HSet(DefaultRedisKeyPrefix+"env_id.visitor_id", "crm", `invalid`)
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,136 @@
+package context_providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+// ErrCircuitOpen is returned while the circuit breaker skips the context provider calls
+var ErrCircuitOpen = errors.New("context provider circuit breaker is open")
+
+// maxCacheEntries bounds the number of cached visitor contexts
+const maxCacheEntries = 100000
+
+type cacheEntry struct {
+	context connectors.ProvidersContext
+	expires time.Time
+}
+
+// ResilientProvider wraps a context provider with a cache of the visitor contexts, a timeout and a circuit breaker
+type ResilientProvider struct {
+	provider connectors.ContextProvider
+	options  models.ContextProviderOptions
+	now      func() time.Time
+
+	lock      sync.Mutex
+	cache     map[string]cacheEntry
+	failures  int
+	openUntil time.Time
+	stats     connectors.ContextProviderStats
+}
+
+// NewResilientProvider wraps the context provider with the options
+func NewResilientProvider(provider connectors.ContextProvider, options models.ContextProviderOptions) *ResilientProvider {
+	return &ResilientProvider{
+		provider: provider,
+		options:  options,
+		now:      time.Now,
+		cache:    map[string]cacheEntry{},
+	}
+}
+
+// LoadVisitorContext returns the cached visitor context, or calls the context provider if the circuit breaker is closed
+func (p *ResilientProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	key := envID + "." + visitorID
+	if result, ok := p.cached(key); ok {
+		return result, nil
+	}
+	if !p.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	if p.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.options.Timeout)
+		defer cancel()
+	}
+	result, err := p.provider.LoadVisitorContext(ctx, envID, visitorID)
+	p.record(key, result, err)
+	return result, err
+}
+
+func (p *ResilientProvider) cached(key string) (connectors.ProvidersContext, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, ok := p.cache[key]
+	if !ok || !p.now().Before(entry.expires) {
+		return nil, false
+	}
+	p.stats.CacheHits++
+	return entry.context, true
+}
+
+// allow returns false while the circuit breaker is open. Once the cooldown is over, a single call is allowed
+// to probe the provider and the circuit breaker stays open until it succeeds
+func (p *ResilientProvider) allow() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.options.CircuitBreakerThreshold <= 0 || p.failures < p.options.CircuitBreakerThreshold {
+		return true
+	}
+	now := p.now()
+	if now.Before(p.openUntil) {
+		p.stats.Rejected++
+		return false
+	}
+	p.openUntil = now.Add(p.options.CircuitBreakerCooldown)
+	return true
+}
+
+func (p *ResilientProvider) record(key string, result connectors.ProvidersContext, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := p.now()
+	if err != nil {
+		p.stats.Failures++
+		p.failures++
+		if p.options.CircuitBreakerThreshold > 0 && p.failures == p.options.CircuitBreakerThreshold {
+			p.openUntil = now.Add(p.options.CircuitBreakerCooldown)
+		}
+		return
+	}
+
+	p.stats.Successes++
+	p.failures = 0
+	if p.options.CacheTTL <= 0 {
+		return
+	}
+	if len(p.cache) >= maxCacheEntries {
+		for k, entry := range p.cache {
+			if !now.Before(entry.expires) {
+				delete(p.cache, k)
+			}
+		}
+		if len(p.cache) >= maxCacheEntries {
+			return
+		}
+	}
+	p.cache[key] = cacheEntry{context: result, expires: now.Add(p.options.CacheTTL)}
+}
+
+// ContextProviderStats returns the results of the context provider calls
+func (p *ResilientProvider) ContextProviderStats() connectors.ContextProviderStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.stats
+}
+
+// Shutdown shuts the wrapped context provider down
+func (p *ResilientProvider) Shutdown(ctx context.Context) error {
+	return connectors.ShutdownConnector(ctx, p.provider)
+}
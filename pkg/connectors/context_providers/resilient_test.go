@@ -0,0 +1,87 @@
+package context_providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubProvider struct {
+	calls  int
+	err    error
+	result connectors.ProvidersContext
+	ctx    context.Context
+}
+
+func (p *stubProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	p.calls++
+	p.ctx = ctx
+	return p.result, p.err
+}
+
+func TestResilientProviderCache(t *testing.T) {
+	stub := &stubProvider{result: connectors.ProvidersContext{"crm": {"plan": "premium"}}}
+	p := NewResilientProvider(stub, models.ContextProviderOptions{CacheTTL: time.Minute, Timeout: time.Second})
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		result, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+		assert.Nil(t, err)
+		assert.Equal(t, stub.result, result)
+	}
+	assert.Equal(t, 1, stub.calls)
+	_, ok := stub.ctx.Deadline()
+	assert.True(t, ok)
+
+	// the contexts are cached by environment and visitor
+	_, err := p.LoadVisitorContext(context.Background(), "env_id", "other_visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stub.calls)
+
+	now = now.Add(time.Minute)
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, stub.calls)
+	assert.Equal(t, connectors.ContextProviderStats{CacheHits: 1, Successes: 3}, p.ContextProviderStats())
+}
+
+func TestResilientProviderCircuitBreaker(t *testing.T) {
+	stub := &stubProvider{err: errors.New("unavailable")}
+	p := NewResilientProvider(stub, models.ContextProviderOptions{CacheTTL: time.Minute, CircuitBreakerThreshold: 2, CircuitBreakerCooldown: 10 * time.Second})
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+		assert.EqualError(t, err, "unavailable")
+	}
+	_, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 2, stub.calls)
+	_, ok := stub.ctx.Deadline()
+	assert.False(t, ok)
+
+	// a single call probes the provider after the cooldown
+	now = now.Add(10 * time.Second)
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.EqualError(t, err, "unavailable")
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 3, stub.calls)
+
+	now = now.Add(10 * time.Second)
+	stub.err = nil
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	stub.err = errors.New("unavailable")
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "other_visitor_id")
+	assert.EqualError(t, err, "unavailable")
+	assert.Equal(t, 5, stub.calls)
+	assert.Equal(t, connectors.ContextProviderStats{Successes: 1, Failures: 4, Rejected: 2}, p.ContextProviderStats())
+}
@@ -0,0 +1,87 @@
+package context_providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+)
+
+// DefaultUDCURL is the URL of the universal data connector API
+const DefaultUDCURL = "https://api-data-connector.flagship.io"
+
+// UDCVisitorRow is a visitor segment of a data partner returned by the universal data connector API
+type UDCVisitorRow struct {
+	Segment string `json:"segment"`
+	Value   string `json:"value"`
+	Partner string `json:"partner"`
+}
+
+// UDCProvider fetches the visitor segments of the data partners from the universal data connector API
+type UDCProvider struct {
+	url    string
+	client *http.Client
+}
+
+// UDCOptions are the options of the universal data connector provider
+type UDCOptions struct {
+	// URL is the URL of the API. DefaultUDCURL is used if empty
+	URL string
+	// HTTPClient is the client of the API calls. http.DefaultClient is used if nil
+	HTTPClient *http.Client
+}
+
+// NewUDCProvider returns a context provider calling the universal data connector API
+func NewUDCProvider(options UDCOptions) *UDCProvider {
+	if options.URL == "" {
+		options.URL = DefaultUDCURL
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	return &UDCProvider{
+		url:    strings.TrimSuffix(options.URL, "/"),
+		client: options.HTTPClient,
+	}
+}
+
+// LoadVisitorContext returns the visitor segments by data partner
+func (p *UDCProvider) LoadVisitorContext(ctx context.Context, envID string, visitorID string) (connectors.ProvidersContext, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/accounts/%s/segments/%s", p.url, url.PathEscape(envID), url.PathEscape(visitorID)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected universal data connector status code %d", resp.StatusCode)
+	}
+
+	var rows []UDCVisitorRow
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("error when decoding universal data connector response: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	result := connectors.ProvidersContext{}
+	for _, row := range rows {
+		if _, ok := result[row.Partner]; !ok {
+			result[row.Partner] = map[string]interface{}{}
+		}
+		result[row.Partner][row.Segment] = row.Value
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package context_providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUDCProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/accounts/env_id/segments/visitor_id":
+			w.Write([]byte(`[{"partner": "mixpanel", "segment": "plan", "value": "premium"}, {"partner": "mixpanel", "segment": "country", "value": "FR"}]`))
+		case "/accounts/env_id/segments/unknown":
+			w.WriteHeader(http.StatusNotFound)
+		case "/accounts/env_id/segments/invalid":
+			w.Write([]byte(`{`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	assert.Equal(t, DefaultUDCURL, NewUDCProvider(UDCOptions{}).url)
+
+	p := NewUDCProvider(UDCOptions{URL: server.URL + "/"})
+	result, err := p.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.ProvidersContext{"mixpanel": {"plan": "premium", "country": "FR"}}, result)
+
+	result, err = p.LoadVisitorContext(context.Background(), "env_id", "unknown")
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+
+	_, err = p.LoadVisitorContext(context.Background(), "env_id", "invalid")
+	assert.NotNil(t, err)
+
+	_, err = p.LoadVisitorContext(context.Background(), "other_env_id", "visitor_id")
+	assert.EqualError(t, err, "unexpected universal data connector status code 500")
+}
@@ -1,12 +1,16 @@
 package environment_loaders
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	common "github.com/flagship-io/flagship-common"
@@ -22,14 +26,28 @@ const defaultPollingInterval = time.Second * 5
 const logName = "CDN Loader"
 
 type CDNLoader struct {
-	baseURL           string
-	httpClient        *http.Client
+	baseURL         string
+	httpClient      *http.Client
+	timeout         time.Duration
+	pollingInternal time.Duration
+	environments    map[string]*cdnEnvironment
+	logger          *logger.Logger
+	lock            *sync.RWMutex
+	stop            chan struct{}
+	stopOnce        *sync.Once
+	pollers         *sync.WaitGroup
+	subscribers     map[string]map[chan struct{}]struct{}
+}
+
+// cdnEnvironment holds the polling state of a single environment
+type cdnEnvironment struct {
+	ticker            *time.Ticker
 	lastModified      string
-	timeout           time.Duration
-	pollingInternal   time.Duration
+	lastUpdate        time.Time
 	loadedEnvironment *models.Environment
-	logger            *logger.Logger
-	lock              *sync.RWMutex
+	bucketingFile     *connectors.BucketingFile
+	refreshSuccesses  uint64
+	refreshFailures   uint64
 }
 
 type CDNLoaderOptionBuilder func(*CDNLoader)
@@ -64,8 +82,13 @@ func NewCDNLoader(opts ...CDNLoaderOptionBuilder) *CDNLoader {
 		httpClient:      &http.Client{},
 		timeout:         defaultTimeout,
 		pollingInternal: defaultPollingInterval,
+		environments:    map[string]*cdnEnvironment{},
 		logger:          logger.New(logrus.WarnLevel.String(), logger.FORMAT_TEXT, logName),
 		lock:            &sync.RWMutex{},
+		stop:            make(chan struct{}),
+		stopOnce:        &sync.Once{},
+		pollers:         &sync.WaitGroup{},
+		subscribers:     map[string]map[chan struct{}]struct{}{},
 	}
 
 	for _, o := range opts {
@@ -77,15 +100,33 @@ func NewCDNLoader(opts ...CDNLoaderOptionBuilder) *CDNLoader {
 	return loader
 }
 
+// Init starts polling the bucketing file of the environment. It can be called once per environment
+// to serve several environments from the same loader
 func (loader *CDNLoader) Init(envID string, APIKey string) error {
+	loader.lock.Lock()
+	if _, ok := loader.environments[envID]; ok {
+		loader.lock.Unlock()
+		return fmt.Errorf("environment %s already initialized", envID)
+	}
 	ticker := time.NewTicker(loader.pollingInternal)
-	loader.logger.Info("initializing CDN environment loader")
+	loader.environments[envID] = &cdnEnvironment{ticker: ticker}
+	loader.lock.Unlock()
 
+	loader.logger.Infof("initializing CDN environment loader for environment %s", envID)
+
+	loader.pollers.Add(1)
 	go func() {
-		for range ticker.C {
-			err := loader.fetchEnvironment(envID, APIKey)
-			if err != nil {
-				loader.logger.Errorf("error when fetching environment: %v", err)
+		defer loader.pollers.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := loader.fetchEnvironment(envID, APIKey)
+				if err != nil {
+					loader.logger.Errorf("error when fetching environment: %v", err)
+				}
+			case <-loader.stop:
+				return
 			}
 		}
 	}()
@@ -93,26 +134,51 @@ func (loader *CDNLoader) Init(envID string, APIKey string) error {
 	return loader.fetchEnvironment(envID, APIKey)
 }
 
+// fetchEnvironment fetches the environment and counts the refresh result
 func (l *CDNLoader) fetchEnvironment(envID string, APIKey string) error {
+	err := l.doFetchEnvironment(envID)
+
+	l.lock.Lock()
+	if env, ok := l.environments[envID]; ok {
+		if err != nil {
+			env.refreshFailures++
+		} else {
+			env.refreshSuccesses++
+		}
+	}
+	l.lock.Unlock()
+
+	return err
+}
+
+func (l *CDNLoader) doFetchEnvironment(envID string) error {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/bucketing.json", l.baseURL, envID), nil)
 	if err != nil {
 		return fmt.Errorf("error when creating HTTP request: %v", err)
 	}
 
 	l.lock.RLock()
-	req.Header.Set("If-Modified-Since", l.lastModified)
+	if env, ok := l.environments[envID]; ok {
+		req.Header.Set("If-Modified-Since", env.lastModified)
+	}
 	l.lock.RUnlock()
 
 	resp, err := l.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("network error: %v", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		return fmt.Errorf("environment loader HTTP error: %v", resp.Status)
 	}
 
 	if resp.StatusCode == 304 {
+		l.lock.Lock()
+		if env, ok := l.environments[envID]; ok {
+			env.lastUpdate = time.Now()
+		}
+		l.lock.Unlock()
 		return nil
 	}
 
@@ -134,7 +200,12 @@ func (l *CDNLoader) fetchEnvironment(envID string, APIKey string) error {
 	}
 
 	l.lock.Lock()
-	l.loadedEnvironment = &models.Environment{
+	env, ok := l.environments[envID]
+	if !ok {
+		env = &cdnEnvironment{}
+		l.environments[envID] = env
+	}
+	env.loadedEnvironment = &models.Environment{
 		Common: &common.Environment{
 			ID:                envID,
 			Campaigns:         campaigns,
@@ -145,13 +216,31 @@ func (l *CDNLoader) fetchEnvironment(envID string, APIKey string) error {
 		},
 		HasIntegrations: false,
 	}
-	l.lastModified = resp.Header.Get("Last-Modified")
+	env.bucketingFile = newBucketingFile(response, resp.Header.Get("Last-Modified"))
+	env.lastModified = resp.Header.Get("Last-Modified")
+	env.lastUpdate = time.Now()
+	l.notifySubscribers(envID)
 	l.lock.Unlock()
 	l.logger.Infof("environment with id %s loaded", envID)
 
 	return nil
 }
 
+// newBucketingFile keeps the raw payload with its ETag and modification time, which defaults to now
+// if the CDN did not send a valid Last-Modified header
+func newBucketingFile(payload []byte, lastModified string) *connectors.BucketingFile {
+	hash := sha256.Sum256(payload)
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		modified = time.Now().UTC().Truncate(time.Second)
+	}
+	return &connectors.BucketingFile{
+		Payload:      payload,
+		ETag:         fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])),
+		LastModified: modified,
+	}
+}
+
 func variationToCommonStruct(v *decision_response.FullVariation) *common.Variation {
 	return &common.Variation{
 		ID:            v.Id.Value,
@@ -207,23 +296,148 @@ func campaignToCommonStruct(c *bucketing.Bucketing_BucketingCampaign) *common.Ca
 	}
 }
 
-func (l *CDNLoader) LoadEnvironment(envID string, APIKey string) (*models.Environment, error) {
+func (l *CDNLoader) getLoadedEnvironment(envID string) *models.Environment {
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 
+	if env, ok := l.environments[envID]; ok {
+		return env.loadedEnvironment
+	}
+	return nil
+}
+
+// LoadEnvironment returns a copy of the last environment fetched for the environment ID
+func (l *CDNLoader) LoadEnvironment(envID string, APIKey string) (*models.Environment, error) {
 	var err error
-	if l.loadedEnvironment == nil {
+	loadedEnvironment := l.getLoadedEnvironment(envID)
+	if loadedEnvironment == nil {
 		err = l.fetchEnvironment(envID, APIKey)
+		loadedEnvironment = l.getLoadedEnvironment(envID)
 	}
 
 	environment := models.Environment{}
-	if l.loadedEnvironment != nil {
+	if loadedEnvironment != nil {
 		// copy loaded environment to prevent campaigns slice reference modification
-		environment = *l.loadedEnvironment
-		commonEnv := *l.loadedEnvironment.Common
-		commonEnv.Campaigns = make([]*common.Campaign, len(l.loadedEnvironment.Common.Campaigns))
-		copy(commonEnv.Campaigns, l.loadedEnvironment.Common.Campaigns)
+		environment = *loadedEnvironment
+		commonEnv := *loadedEnvironment.Common
+		commonEnv.Campaigns = make([]*common.Campaign, len(loadedEnvironment.Common.Campaigns))
+		copy(commonEnv.Campaigns, loadedEnvironment.Common.Campaigns)
 		environment.Common = &commonEnv
 	}
 	return &environment, err
 }
+
+// EnvironmentStatus returns whether the environment is loaded, when it was last successfully refreshed and the refresh counts
+func (l *CDNLoader) EnvironmentStatus(envID string) connectors.EnvironmentStatus {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	env, ok := l.environments[envID]
+	if !ok {
+		return connectors.EnvironmentStatus{}
+	}
+	return connectors.EnvironmentStatus{
+		Loaded:           env.loadedEnvironment != nil,
+		LastUpdate:       env.lastUpdate,
+		LastModified:     env.lastModified,
+		RefreshSuccesses: env.refreshSuccesses,
+		RefreshFailures:  env.refreshFailures,
+	}
+}
+
+// Shutdown stops polling the environments, and waits for the running fetches to end
+func (l *CDNLoader) Shutdown(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		l.pollers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		l.logger.Info("environments polling stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reload changes the logger and the polling interval of the running loader
+func (l *CDNLoader) Reload(options *models.ReloadOptions) error {
+	if options.LogLevel != "" {
+		if err := l.logger.Configure(options.LogLevel, logger.LogFormat(options.LogFormat)); err != nil {
+			return err
+		}
+	}
+
+	if options.PollingInterval > 0 {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if options.PollingInterval != l.pollingInternal {
+			l.logger.Infof("changing polling interval to %v", options.PollingInterval)
+			l.pollingInternal = options.PollingInterval
+			for _, env := range l.environments {
+				// the environments loaded without Init are not polled
+				if env.ticker != nil {
+					env.ticker.Reset(options.PollingInterval)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RefreshEnvironment fetches the environment immediately
+func (l *CDNLoader) RefreshEnvironment(envID string) error {
+	l.lock.RLock()
+	_, ok := l.environments[envID]
+	l.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("environment %s not initialized", envID)
+	}
+	return l.fetchEnvironment(envID, "")
+}
+
+// BucketingFile returns the last fetched bucketing file of the environment
+func (l *CDNLoader) BucketingFile(envID string) *connectors.BucketingFile {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	env, ok := l.environments[envID]
+	if !ok {
+		return nil
+	}
+	return env.bucketingFile
+}
+
+// SubscribeEnvironment returns a channel notified each time a new bucketing file of the environment is loaded
+func (l *CDNLoader) SubscribeEnvironment(envID string) (<-chan struct{}, func()) {
+	updates := make(chan struct{}, 1)
+
+	l.lock.Lock()
+	if _, ok := l.subscribers[envID]; !ok {
+		l.subscribers[envID] = map[chan struct{}]struct{}{}
+	}
+	l.subscribers[envID][updates] = struct{}{}
+	l.lock.Unlock()
+
+	return updates, func() {
+		l.lock.Lock()
+		delete(l.subscribers[envID], updates)
+		l.lock.Unlock()
+	}
+}
+
+// notifySubscribers notifies the environment subscribers without blocking. It must be called with the lock held
+func (l *CDNLoader) notifySubscribers(envID string) {
+	for updates := range l.subscribers[envID] {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -1,16 +1,19 @@
 package environment_loaders
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	"github.com/flagship-io/flagship-proto/bucketing"
 	"github.com/flagship-io/flagship-proto/decision_response"
 	"github.com/flagship-io/flagship-proto/targeting"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -75,10 +78,11 @@ func TestNewCDNLoader(t *testing.T) {
 
 	err := loader.Init("env_id", "api_key")
 	assert.Nil(t, err)
-	campaign := loader.loadedEnvironment.Common.Campaigns[0]
-	assert.EqualValues(t, conf.Panic, loader.loadedEnvironment.Common.IsPanic)
-	assert.EqualValues(t, conf.AccountSettings.Enabled1V1T, loader.loadedEnvironment.Common.SingleAssignment)
-	assert.EqualValues(t, conf.AccountSettings.EnabledXPC, loader.loadedEnvironment.Common.UseReconciliation)
+	loadedEnvironment := loader.environments["env_id"].loadedEnvironment
+	campaign := loadedEnvironment.Common.Campaigns[0]
+	assert.EqualValues(t, conf.Panic, loadedEnvironment.Common.IsPanic)
+	assert.EqualValues(t, conf.AccountSettings.Enabled1V1T, loadedEnvironment.Common.SingleAssignment)
+	assert.EqualValues(t, conf.AccountSettings.EnabledXPC, loadedEnvironment.Common.UseReconciliation)
 	assert.EqualValues(t, conf.Campaigns[0].Id, campaign.ID)
 	assert.EqualValues(t, conf.Campaigns[0].Slug.Value, *campaign.Slug)
 	assert.EqualValues(t, conf.Campaigns[0].Type, campaign.Type)
@@ -103,3 +107,176 @@ func TestNewCDNLoader(t *testing.T) {
 	assert.Equal(t, conf.Panic, data.Common.IsPanic)
 	lock.Unlock()
 }
+
+func TestCDNLoaderMultipleEnvironments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conf := &bucketing.Bucketing_BucketingResponse{
+			Panic:           req.URL.Path == "/env_id_2/bucketing.json",
+			AccountSettings: &decision_response.AccountSettings{},
+		}
+		confJSON, _ := protojson.Marshal(conf)
+		_, err := rw.Write(confJSON)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	loader := NewCDNLoader(WithBaseURL(server.URL), WithPollingInterval(time.Minute))
+
+	err := loader.Init("env_id_1", "api_key_1")
+	assert.Nil(t, err)
+	err = loader.Init("env_id_2", "api_key_2")
+	assert.Nil(t, err)
+	err = loader.Init("env_id_2", "api_key_2")
+	assert.NotNil(t, err)
+
+	env1, err := loader.LoadEnvironment("env_id_1", "api_key_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "env_id_1", env1.Common.ID)
+	assert.False(t, env1.Common.IsPanic)
+
+	env2, err := loader.LoadEnvironment("env_id_2", "api_key_2")
+	assert.Nil(t, err)
+	assert.Equal(t, "env_id_2", env2.Common.ID)
+	assert.True(t, env2.Common.IsPanic)
+}
+
+func TestCDNLoaderEnvironmentStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/env_id_2/bucketing.json" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		confJSON, _ := protojson.Marshal(&bucketing.Bucketing_BucketingResponse{
+			AccountSettings: &decision_response.AccountSettings{},
+		})
+		_, err := rw.Write(confJSON)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	loader := NewCDNLoader(WithBaseURL(server.URL), WithPollingInterval(time.Minute))
+	assert.False(t, loader.EnvironmentStatus("env_id_1").Loaded)
+
+	err := loader.Init("env_id_1", "api_key_1")
+	assert.Nil(t, err)
+	status := loader.EnvironmentStatus("env_id_1")
+	assert.True(t, status.Loaded)
+	assert.WithinDuration(t, time.Now(), status.LastUpdate, time.Second)
+	assert.EqualValues(t, 1, status.RefreshSuccesses)
+	assert.EqualValues(t, 0, status.RefreshFailures)
+
+	file := loader.BucketingFile("env_id_1")
+	assert.NotNil(t, file)
+	assert.Contains(t, string(file.Payload), "accountSettings")
+	assert.NotEmpty(t, file.ETag)
+	assert.WithinDuration(t, time.Now(), file.LastModified, 2*time.Second)
+	assert.Nil(t, loader.BucketingFile("env_id_2"))
+
+	err = loader.RefreshEnvironment("env_id_1")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, loader.EnvironmentStatus("env_id_1").RefreshSuccesses)
+	assert.NotNil(t, loader.RefreshEnvironment("unknown_env_id"))
+
+	err = loader.Init("env_id_2", "api_key_2")
+	assert.NotNil(t, err)
+	status = loader.EnvironmentStatus("env_id_2")
+	assert.False(t, status.Loaded)
+	assert.EqualValues(t, 0, status.RefreshSuccesses)
+	assert.EqualValues(t, 1, status.RefreshFailures)
+}
+
+func TestCDNLoaderShutdown(t *testing.T) {
+	calls := 0
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		calls++
+		lock.Unlock()
+		confJSON, _ := protojson.Marshal(&bucketing.Bucketing_BucketingResponse{
+			AccountSettings: &decision_response.AccountSettings{},
+		})
+		_, err := rw.Write(confJSON)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	loader := NewCDNLoader(WithBaseURL(server.URL), WithPollingInterval(10*time.Millisecond))
+	err := loader.Init("env_id", "api_key")
+	assert.Nil(t, err)
+
+	err = loader.Shutdown(context.Background())
+	assert.Nil(t, err)
+
+	// shutting down twice is a no-op
+	err = loader.Shutdown(context.Background())
+	assert.Nil(t, err)
+
+	lock.Lock()
+	callsAfterShutdown := calls
+	lock.Unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, callsAfterShutdown, calls)
+}
+
+func TestCDNLoaderReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		confJSON, _ := protojson.Marshal(&bucketing.Bucketing_BucketingResponse{
+			AccountSettings: &decision_response.AccountSettings{},
+		})
+		_, err := rw.Write(confJSON)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	loader := NewCDNLoader(WithBaseURL(server.URL), WithPollingInterval(time.Hour))
+	defer loader.Shutdown(context.Background())
+	err := loader.Init("env_id", "api_key")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, loader.EnvironmentStatus("env_id").RefreshSuccesses)
+	// an environment loaded without Init has no polling ticker
+	_, err = loader.LoadEnvironment("lazy_env_id", "api_key")
+	assert.Nil(t, err)
+
+	err = loader.Reload(&models.ReloadOptions{LogLevel: "debug", PollingInterval: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, logrus.DebugLevel, loader.logger.Logger.Level)
+	assert.Eventually(t, func() bool {
+		return loader.EnvironmentStatus("env_id").RefreshSuccesses > 1
+	}, time.Second, 10*time.Millisecond)
+
+	err = loader.Reload(&models.ReloadOptions{LogLevel: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestCDNLoaderSubscribeEnvironment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		confJSON, _ := protojson.Marshal(&bucketing.Bucketing_BucketingResponse{
+			AccountSettings: &decision_response.AccountSettings{},
+		})
+		_, err := rw.Write(confJSON)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+
+	loader := NewCDNLoader(WithBaseURL(server.URL), WithPollingInterval(time.Hour))
+	defer loader.Shutdown(context.Background())
+
+	updates, unsubscribe := loader.SubscribeEnvironment("env_id")
+	err := loader.Init("env_id", "api_key")
+	assert.Nil(t, err)
+	assert.Len(t, updates, 1)
+
+	// notifications are coalesced
+	err = loader.RefreshEnvironment("env_id")
+	assert.Nil(t, err)
+	assert.Len(t, updates, 1)
+	<-updates
+
+	unsubscribe()
+	err = loader.RefreshEnvironment("env_id")
+	assert.Nil(t, err)
+	assert.Len(t, updates, 0)
+}
@@ -46,7 +46,12 @@ type DataCollectProcessor struct {
 	lastTick       time.Time
 	logger         *logger.Logger
 	httpClient     *http.Client
+	sentHits       uint64
+	sendFailures   uint64
 	lock           *sync.Mutex
+	stop           chan struct{}
+	stopOnce       *sync.Once
+	workers        *sync.WaitGroup
 }
 
 type DatacollectOptionBuilder func(*DataCollectProcessor)
@@ -91,7 +96,10 @@ func NewDataCollectProcessor(opts ...DatacollectOptionBuilder) *DataCollectProce
 		httpClient: &http.Client{
 			Timeout: 2 * time.Second,
 		},
-		lock: &sync.Mutex{},
+		lock:     &sync.Mutex{},
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		workers:  &sync.WaitGroup{},
 	}
 
 	for _, o := range opts {
@@ -101,30 +109,66 @@ func NewDataCollectProcessor(opts ...DatacollectOptionBuilder) *DataCollectProce
 	processor.logger.Info("initializing datacollect hits processor")
 	processor.ticker = make(chan time.Time)
 
+	processor.workers.Add(2)
 	go func() {
+		defer processor.workers.Done()
 		for {
-			time.Sleep(processor.batchingWindow)
+			if !processor.wait(processor.getBatchingWindow()) {
+				return
+			}
 			processor.lock.Lock()
 			durationSinceLastTick := time.Since(processor.lastTick)
+			batchingWindow := processor.batchingWindow
+			processor.lock.Unlock()
 			// If last tick was trigger in between because of full batch, wait a little more
-			if durationSinceLastTick < processor.batchingWindow {
-				time.Sleep(processor.batchingWindow - durationSinceLastTick)
+			if durationSinceLastTick < batchingWindow && !processor.wait(batchingWindow-durationSinceLastTick) {
+				return
+			}
+			select {
+			case processor.ticker <- time.Now():
+			case <-processor.stop:
+				return
 			}
-			processor.lock.Unlock()
-			processor.ticker <- time.Now()
 		}
 	}()
 
 	go func() {
-		for t := range processor.ticker {
-			processor.sendHits(processor.hits, t)
-			processor.hits = []models.MappableHit{}
+		defer processor.workers.Done()
+		for {
+			select {
+			case t := <-processor.ticker:
+				processor.lock.Lock()
+				hits := processor.hits
+				processor.hits = []models.MappableHit{}
+				processor.lock.Unlock()
+				processor.sendHits(hits, t)
+			case <-processor.stop:
+				return
+			}
 		}
 	}()
 
 	return processor
 }
 
+// wait waits for the duration, and returns false if the processor is stopped in between
+func (d *DataCollectProcessor) wait(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-d.stop:
+		return false
+	}
+}
+
+func (d *DataCollectProcessor) getBatchingWindow() time.Duration {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.batchingWindow
+}
+
 // sendBatchHit sends a batch of hits to the trackingURL using the httpClient.
 func (d *DataCollectProcessor) sendBatchHit(ctx context.Context, mappableHits []models.MappableHit) error {
 	if len(mappableHits) == 0 {
@@ -166,6 +210,7 @@ func (d *DataCollectProcessor) sendBatchHit(ctx context.Context, mappableHits []
 	if err != nil {
 		return fmt.Errorf("error when making HTTP request: %v", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		return fmt.Errorf("got status %v when calling HTTP request", resp.Status)
@@ -181,6 +226,11 @@ func (d *DataCollectProcessor) sendHits(hits []models.MappableHit, tick time.Tim
 		d.logger.Errorf("error when sending batch hit: %v", err)
 	}
 	d.lock.Lock()
+	if err != nil {
+		d.sendFailures++
+	} else {
+		d.sentHits += uint64(len(hits))
+	}
 	d.lastTick = tick
 	d.lock.Unlock()
 }
@@ -195,16 +245,97 @@ func (d *DataCollectProcessor) TrackHits(hits connectors.TrackingHits) error {
 	for _, vc := range hits.VisitorContext {
 		mappableHits = append(mappableHits, vc)
 	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	d.hits = append(d.hits, mappableHits...)
+	select {
+	case <-d.stop:
+		// the hits are sent by the final flush of the shutdown
+		return nil
+	default:
+	}
 	if len(d.hits) >= d.batchSize {
-		go d.sendHits(d.hits, time.Now())
-		d.lock.Lock()
+		hits := d.hits
+		d.workers.Add(1)
+		go func() {
+			defer d.workers.Done()
+			d.sendHits(hits, time.Now())
+		}()
 		d.hits = []models.MappableHit{}
-		d.lock.Unlock()
 	}
 	return nil
 }
 
+// HitsProcessorStats returns the number of hits waiting to be sent, and the sent hits and send failures counts
+func (d *DataCollectProcessor) HitsProcessorStats() connectors.HitsProcessorStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return connectors.HitsProcessorStats{
+		QueueDepth:   len(d.hits),
+		SentHits:     d.sentHits,
+		SendFailures: d.sendFailures,
+	}
+}
+
+// Flush sends the queued hits immediately
+func (d *DataCollectProcessor) Flush(ctx context.Context) error {
+	d.lock.Lock()
+	hits := d.hits
+	d.hits = []models.MappableHit{}
+	d.lock.Unlock()
+
+	err := d.sendBatchHit(ctx, hits)
+	d.lock.Lock()
+	if err != nil {
+		d.sendFailures++
+	} else {
+		d.sentHits += uint64(len(hits))
+	}
+	d.lock.Unlock()
+	return err
+}
+
+// Shutdown stops the batching window, waits for the running sends to end, then flushes the remaining hits,
+// so that no hit is sent once it returns
 func (d *DataCollectProcessor) Shutdown(ctx context.Context) error {
-	return d.sendBatchHit(ctx, d.hits)
+	d.stopOnce.Do(func() {
+		// closed under the lock, so that TrackHits does not start a send once the workers are awaited
+		d.lock.Lock()
+		close(d.stop)
+		d.lock.Unlock()
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return d.Flush(ctx)
+}
+
+// Reload changes the logger and the batch options of the running processor.
+// The new batching window is used after the current window ends
+func (d *DataCollectProcessor) Reload(options *models.ReloadOptions) error {
+	if options.LogLevel != "" {
+		if err := d.logger.Configure(options.LogLevel, logger.LogFormat(options.LogFormat)); err != nil {
+			return err
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if options.HitsBatchSize > 0 {
+		d.batchSize = options.HitsBatchSize
+	}
+	if options.HitsBatchingWindow > 0 {
+		d.batchingWindow = options.HitsBatchingWindow
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package hits_processors
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -96,3 +97,94 @@ func TestDataCollectTrack(t *testing.T) {
 	}, batch.Hits[1]["s"])
 	assert.True(t, batch.Hits[1]["qt"].(float64) < 1010 && batch.Hits[1]["qt"].(float64) >= 1000)
 }
+
+func TestDataCollectStats(t *testing.T) {
+	failed := true
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if failed {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	dcProcessor := NewDataCollectProcessor(WithBatchOptions(2, time.Minute), WithTrackingURL(server.URL))
+	hit := &models.CampaignActivation{EnvID: "env_id", VisitorID: "visitor_id", CampaignID: "campaign_id"}
+
+	err := dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit}})
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.HitsProcessorStats{QueueDepth: 1}, dcProcessor.HitsProcessorStats())
+
+	err = dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit}})
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, connectors.HitsProcessorStats{SendFailures: 1}, dcProcessor.HitsProcessorStats())
+
+	lock.Lock()
+	failed = false
+	lock.Unlock()
+	err = dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit, hit}})
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, connectors.HitsProcessorStats{SentHits: 2, SendFailures: 1}, dcProcessor.HitsProcessorStats())
+
+	err = dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit}})
+	assert.Nil(t, err)
+	err = dcProcessor.Flush(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.HitsProcessorStats{SentHits: 3, SendFailures: 1}, dcProcessor.HitsProcessorStats())
+}
+
+func TestDataCollectShutdown(t *testing.T) {
+	lock := &sync.Mutex{}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests++
+	}))
+	defer server.Close()
+
+	dcProcessor := NewDataCollectProcessor(WithBatchOptions(2, 10*time.Millisecond), WithTrackingURL(server.URL))
+	hit := &models.CampaignActivation{EnvID: "env_id", VisitorID: "visitor_id", CampaignID: "campaign_id"}
+
+	err := dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit, hit, hit}})
+	assert.Nil(t, err)
+	err = dcProcessor.Shutdown(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.HitsProcessorStats{SentHits: 3}, dcProcessor.HitsProcessorStats())
+
+	lock.Lock()
+	sent := requests
+	lock.Unlock()
+
+	// the batching window is stopped, and the hits tracked after shutdown are only sent by a flush
+	err = dcProcessor.TrackHits(connectors.TrackingHits{CampaignActivations: []*models.CampaignActivation{hit, hit}})
+	assert.Nil(t, err)
+	time.Sleep(50 * time.Millisecond)
+	lock.Lock()
+	assert.Equal(t, sent, requests)
+	lock.Unlock()
+	assert.Equal(t, connectors.HitsProcessorStats{SentHits: 3, QueueDepth: 2}, dcProcessor.HitsProcessorStats())
+
+	err = dcProcessor.Shutdown(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, connectors.HitsProcessorStats{SentHits: 5}, dcProcessor.HitsProcessorStats())
+}
+
+func TestDataCollectReload(t *testing.T) {
+	dc := NewDataCollectProcessor(WithBatchOptions(10, time.Minute))
+
+	err := dc.Reload(&models.ReloadOptions{LogLevel: "debug", HitsBatchSize: 20, HitsBatchingWindow: time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, logrus.DebugLevel, dc.logger.Logger.Level)
+	assert.Equal(t, 20, dc.batchSize)
+	assert.Equal(t, time.Second, dc.batchingWindow)
+
+	// zero values keep the running options
+	err = dc.Reload(&models.ReloadOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 20, dc.batchSize)
+}
@@ -0,0 +1,38 @@
+package connectors
+
+import (
+	"context"
+	"io"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+// Shutdowner can be implemented by environment loaders and assignments managers to stop their background tasks
+// and release their resources when the server shuts down. Connectors implementing io.Closer are closed as well
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Reloadable can be implemented by connectors to apply the configuration changes while the server is running
+type Reloadable interface {
+	Reload(options *models.ReloadOptions) error
+}
+
+// ShutdownConnector shuts the connector down if it implements Shutdowner, or closes it if it implements io.Closer
+func ShutdownConnector(ctx context.Context, connector interface{}) error {
+	switch c := connector.(type) {
+	case Shutdowner:
+		return c.Shutdown(ctx)
+	case io.Closer:
+		return c.Close()
+	}
+	return nil
+}
+
+// ReloadConnector applies the reload options to the connector if it implements Reloadable
+func ReloadConnector(connector interface{}, options *models.ReloadOptions) error {
+	if c, ok := connector.(Reloadable); ok {
+		return c.Reload(options)
+	}
+	return nil
+}
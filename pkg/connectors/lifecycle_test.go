@@ -0,0 +1,34 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type shutdownConnector struct {
+	ctx context.Context
+}
+
+func (c *shutdownConnector) Shutdown(ctx context.Context) error {
+	c.ctx = ctx
+	return nil
+}
+
+type closeConnector struct{}
+
+func (c *closeConnector) Close() error {
+	return errors.New("close error")
+}
+
+func TestShutdownConnector(t *testing.T) {
+	ctx := context.Background()
+	connector := &shutdownConnector{}
+	assert.Nil(t, ShutdownConnector(ctx, connector))
+	assert.Equal(t, ctx, connector.ctx)
+
+	assert.EqualError(t, ShutdownConnector(ctx, &closeConnector{}), "close error")
+	assert.Nil(t, ShutdownConnector(ctx, struct{}{}))
+}
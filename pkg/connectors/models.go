@@ -2,17 +2,35 @@ package connectors
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
 	common "github.com/flagship-io/flagship-common"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrNotSupported is returned when the connector does not support an optional operation
+var ErrNotSupported = errors.New("operation not supported by the connector")
+
 type DecisionContext struct {
 	EnvID  string
 	APIKey string
 	Logger *logger.Logger
+	Tracer trace.Tracer
+	// ForcedVariationsOptions verifies the forced variations tokens of the decision requests
+	ForcedVariationsOptions *models.ForcedVariationsOptions
+	// ContextSchema validates the visitor contexts of the decision requests, if set
+	ContextSchema *contextschema.Validator
+	// ContextEnrichmentOptions are the visitor context keys derived from the decision requests, if set
+	ContextEnrichmentOptions *models.ContextEnrichmentOptions
+	// FallbackOptions are the flags served when the environment can not be loaded or is in panic mode
+	FallbackOptions *models.FallbackOptions
+	// Overrides holds the local overrides applied on top of the loaded environment
+	Overrides *overrides.Manager
 	Connectors
 }
 
@@ -20,6 +38,8 @@ type Connectors struct {
 	HitsProcessor      HitsProcessor
 	EnvironmentLoader  EnvironmentLoader
 	AssignmentsManager AssignmentsManager
+	// ContextProvider fetches the visitor context of the integration providers, if set
+	ContextProvider ContextProvider
 }
 
 type TrackingHits struct {
@@ -32,11 +52,94 @@ type HitsProcessor interface {
 	Shutdown(context.Context) error
 }
 
+// HitsProcessorStats represents the state of the hits processor queue
+type HitsProcessorStats struct {
+	QueueDepth   int
+	SentHits     uint64
+	SendFailures uint64
+}
+
+// HitsProcessorStatsProvider can be implemented by hits processors to report their queue state
+type HitsProcessorStatsProvider interface {
+	HitsProcessorStats() HitsProcessorStats
+}
+
+// HitsFlusher can be implemented by hits processors to send the queued hits immediately
+type HitsFlusher interface {
+	Flush(ctx context.Context) error
+}
+
 type EnvironmentLoader interface {
 	Init(envID string, APIKey string) error
 	LoadEnvironment(envID string, APIKey string) (*models.Environment, error)
 }
 
+// EnvironmentStatus represents the loading status of an environment
+type EnvironmentStatus struct {
+	Loaded           bool
+	LastUpdate       time.Time
+	LastModified     string
+	RefreshSuccesses uint64
+	RefreshFailures  uint64
+}
+
+// EnvironmentStatusProvider can be implemented by environment loaders to report when an environment was last refreshed
+type EnvironmentStatusProvider interface {
+	EnvironmentStatus(envID string) EnvironmentStatus
+}
+
+// BucketingFile is the raw bucketing file of an environment, as fetched by the environment loader
+type BucketingFile struct {
+	Payload      []byte
+	ETag         string
+	LastModified time.Time
+}
+
+// BucketingFileProvider can be implemented by environment loaders to serve the last fetched bucketing file.
+// It returns nil if the bucketing file is not loaded
+type BucketingFileProvider interface {
+	BucketingFile(envID string) *BucketingFile
+}
+
+// EnvironmentNotifier can be implemented by environment loaders to notify when a new version of an environment is loaded.
+// The updates channel is not closed by unsubscribe, and pending notifications are coalesced
+type EnvironmentNotifier interface {
+	SubscribeEnvironment(envID string) (updates <-chan struct{}, unsubscribe func())
+}
+
+// EnvironmentRefresher can be implemented by environment loaders to refresh an environment immediately
+type EnvironmentRefresher interface {
+	RefreshEnvironment(envID string) error
+}
+
+// HealthChecker can be implemented by connectors to check that their backend is reachable
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// ProvidersContext are the visitor context keys and values by integration provider
+type ProvidersContext map[string]map[string]interface{}
+
+// ContextProvider fetches the visitor context of the integration providers, such as the data partners segments.
+// It returns nil if the visitor has no context
+type ContextProvider interface {
+	LoadVisitorContext(ctx context.Context, envID string, visitorID string) (ProvidersContext, error)
+}
+
+// ContextProviderStats represents the results of the context provider calls
+type ContextProviderStats struct {
+	CacheHits uint64
+	Successes uint64
+	Failures  uint64
+	// Rejected is the number of calls rejected while the circuit breaker is open
+	Rejected uint64
+}
+
+// ContextProviderStatsProvider can be implemented by context providers to report the results of their calls
+type ContextProviderStatsProvider interface {
+	ContextProviderStats() ContextProviderStats
+}
+
 type AssignmentScope int64
 
 const (
@@ -53,3 +156,36 @@ type AssignmentsManager interface {
 	LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error)
 	SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error
 }
+
+// AssignmentsDeleter can be implemented by assignments managers to delete the assignments of a visitor
+type AssignmentsDeleter interface {
+	DeleteAssignments(envID string, visitorID string) error
+}
+
+// VisitorAssignmentsSave are the new assignments of a visitor to save
+type VisitorAssignmentsSave struct {
+	VisitorID   string
+	Assignments map[string]*common.VisitorCache
+	Date        time.Time
+}
+
+// AssignmentsBulkSaver can be implemented by assignments managers to save the assignments of several visitors at once
+type AssignmentsBulkSaver interface {
+	SaveAssignmentsBulk(envID string, assignments []*VisitorAssignmentsSave) error
+}
+
+// SaveAssignmentsBulk saves the assignments of several visitors with a single call if the manager implements AssignmentsBulkSaver,
+// or one call per visitor otherwise. It returns the first error encountered
+func SaveAssignmentsBulk(manager AssignmentsManager, envID string, assignments []*VisitorAssignmentsSave) error {
+	if saver, ok := manager.(AssignmentsBulkSaver); ok {
+		return saver.SaveAssignmentsBulk(envID, assignments)
+	}
+
+	var firstErr error
+	for _, a := range assignments {
+		if err := manager.SaveAssignments(envID, a.VisitorID, a.Assignments, a.Date); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
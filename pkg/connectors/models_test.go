@@ -0,0 +1,55 @@
+package connectors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "github.com/flagship-io/flagship-common"
+	"github.com/stretchr/testify/assert"
+)
+
+type savingManager struct {
+	saved []string
+}
+
+func (m *savingManager) ShouldSaveAssignments(context SaveAssignmentsContext) bool {
+	return true
+}
+
+func (m *savingManager) LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error) {
+	return nil, nil
+}
+
+func (m *savingManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	m.saved = append(m.saved, visitorID)
+	if visitorID == "failing_visitor" {
+		return errors.New("save error")
+	}
+	return nil
+}
+
+type bulkSavingManager struct {
+	savingManager
+	bulks int
+}
+
+func (m *bulkSavingManager) SaveAssignmentsBulk(envID string, assignments []*VisitorAssignmentsSave) error {
+	m.bulks++
+	return nil
+}
+
+func TestSaveAssignmentsBulk(t *testing.T) {
+	assignments := []*VisitorAssignmentsSave{{VisitorID: "failing_visitor"}, {VisitorID: "visitor_2"}}
+
+	manager := &savingManager{}
+	err := SaveAssignmentsBulk(manager, "env_id", assignments)
+	assert.EqualError(t, err, "save error")
+	assert.Equal(t, []string{"failing_visitor", "visitor_2"}, manager.saved)
+
+	bulkManager := &bulkSavingManager{}
+	err = SaveAssignmentsBulk(bulkManager, "env_id", assignments)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, bulkManager.bulks)
+	assert.Empty(t, bulkManager.saved)
+}
@@ -0,0 +1,60 @@
+package grpc_server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodName returns the short method name from the full gRPC method
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// metricsInterceptor records the response time, status code and internal errors of the call in the handlers metrics
+func metricsInterceptor(registry *middlewares.MetricsRegistry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+		registry.ObserveRequest("grpc_"+methodName(info.FullMethod), code.String(), time.Since(start), code == codes.Internal || code == codes.Unknown)
+		return resp, err
+	}
+}
+
+// recoverInterceptor returns an internal error instead of crashing the server when the call panics
+func recoverInterceptor(enabled bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if enabled {
+			defer func() {
+				if r := recover(); r != nil {
+					err = status.Error(codes.Internal, fmt.Sprintf("unexpected error occurred: %v", r))
+				}
+			}()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// loggingInterceptor logs the calls like the HTTP request logger
+func loggingInterceptor(logger *logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		addr := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			addr = p.Addr.String()
+		}
+		logger.Infof("%s | %s | gRPC | %v | %s", addr, status.Code(err), time.Since(start), info.FullMethod)
+		return resp, err
+	}
+}
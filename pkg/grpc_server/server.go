@@ -0,0 +1,42 @@
+package grpc_server
+
+import (
+	"crypto/tls"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Options are the options necessary to create the gRPC server
+type Options struct {
+	DecisionContexts []*connectors.DecisionContext
+	AuthOptions      *models.AuthOptions
+	Logger           *logger.Logger
+	Recover          bool
+	TLSConfig        *tls.Config
+	Metrics          *middlewares.MetricsRegistry
+}
+
+// NewServer creates a gRPC server exposing the decision service, with the metrics, recover and logging interceptors
+func NewServer(options Options) *grpc.Server {
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			loggingInterceptor(options.Logger),
+			metricsInterceptor(options.Metrics),
+			recoverInterceptor(options.Recover),
+		),
+	}
+
+	if options.TLSConfig != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(options.TLSConfig)))
+	}
+
+	server := grpc.NewServer(serverOptions...)
+	server.RegisterService(&serviceDesc, NewDecisionServer(options.DecisionContexts, options.AuthOptions))
+
+	return server
+}
@@ -0,0 +1,299 @@
+package grpc_server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/internal/validation"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/flagship-proto/activate_request"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"github.com/flagship-io/flagship-proto/decision_response"
+	"github.com/flagship-io/flagship-proto/flags"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ServiceName is the full name of the gRPC decision service
+const ServiceName = "flagship.decisionapi.DecisionService"
+
+// DecisionServer implements the gRPC decision service for the configured environments
+type DecisionServer struct {
+	decisionContexts map[string]*connectors.DecisionContext
+	defaultEnvID     string
+	authOptions      *models.AuthOptions
+}
+
+// NewDecisionServer creates a decision service for the decision contexts. The first decision context is used
+// when the x-env-id metadata is not set
+func NewDecisionServer(decisionContexts []*connectors.DecisionContext, authOptions *models.AuthOptions) *DecisionServer {
+	s := &DecisionServer{
+		decisionContexts: map[string]*connectors.DecisionContext{},
+		authOptions:      authOptions,
+	}
+	for i, c := range decisionContexts {
+		if i == 0 {
+			s.defaultEnvID = c.EnvID
+		}
+		s.decisionContexts[c.EnvID] = c
+	}
+	return s
+}
+
+func getMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// getDecisionContext returns the decision context of the environment requested in the call metadata,
+// and checks the call API key if authentication is enabled
+func (s *DecisionServer) getDecisionContext(ctx context.Context) (*connectors.DecisionContext, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	envID := getMetadata(md, "x-env-id")
+	if envID == "" {
+		envID = s.defaultEnvID
+	}
+
+	decisionContext, ok := s.decisionContexts[envID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "environment %s not found", envID)
+	}
+
+	if s.authOptions != nil && s.authOptions.Enabled {
+		apiKey := getMetadata(md, "x-api-key")
+		if authorization := getMetadata(md, "authorization"); apiKey == "" && len(authorization) > 7 && strings.EqualFold(authorization[:7], "bearer ") {
+			apiKey = strings.TrimSpace(authorization[7:])
+		}
+		if apiKey == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API key. Set the x-api-key or authorization metadata")
+		}
+		if !middlewares.CheckAPIKey(s.authOptions, decisionContext.APIKey, apiKey) {
+			return nil, status.Error(codes.PermissionDenied, "invalid API key")
+		}
+	}
+
+	return decisionContext, nil
+}
+
+// getRequestMetadata returns the metadata of a gRPC decision request used to enrich the visitor context:
+// the user-agent, accept-language and x-forwarded-for metadata, and the address of the peer
+func getRequestMetadata(ctx context.Context) apilogic.RequestMetadata {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestMetadata := apilogic.RequestMetadata{
+		UserAgent:      getMetadata(md, "user-agent"),
+		AcceptLanguage: getMetadata(md, "accept-language"),
+		ForwardedFor:   strings.Join(md.Get("x-forwarded-for"), ","),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		requestMetadata.RemoteAddr = p.Addr.String()
+	}
+	return requestMetadata
+}
+
+// toStatusError converts an error of the decision pipeline to a gRPC status error.
+// The validation errors are InvalidArgument errors with the invalid fields as BadRequest details
+func toStatusError(err error) error {
+	var validationErr *validation.ErrorResponse
+	if errors.As(err, &validationErr) {
+		badRequest := &errdetails.BadRequest{}
+		fields := make([]string, 0, len(validationErr.Errors))
+		for field := range validationErr.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field,
+				Description: validationErr.Errors[field],
+			})
+		}
+		st, detailsErr := status.New(codes.InvalidArgument, "invalid decision request").WithDetails(badRequest)
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, validationErr.Error())
+		}
+		return st.Err()
+	}
+
+	var apiErr *apilogic.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Status >= http.StatusInternalServerError:
+			return status.Error(codes.Internal, apiErr.Message)
+		case apiErr.Status == http.StatusForbidden:
+			return status.Error(codes.PermissionDenied, apiErr.Message)
+		default:
+			return status.Error(codes.InvalidArgument, apiErr.Message)
+		}
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// computeDecision computes the campaigns decision of the request.
+// With withFallback, the fallback flags are returned instead when the environment can not be loaded or is in panic mode,
+// as the HTTP flags endpoint serves them
+func (s *DecisionServer) computeDecision(ctx context.Context, req *decision_request.DecisionRequest, exposeAllKeys bool, withFallback bool) (*handle.Request, map[string]interface{}, error) {
+	decisionContext, err := s.getDecisionContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.GetVisitorId().GetValue() == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "missing visitor_id")
+	}
+
+	handleRequest := apilogic.NewHandleRequest(req)
+	handleRequest.ExposeAllKeys = exposeAllKeys
+	handleRequest.Ctx = ctx
+	md, _ := metadata.FromIncomingContext(ctx)
+	forcedVariationsToken := getMetadata(md, strings.ToLower(utils.ForcedVariationsHeader))
+	if err := apilogic.PrepareHandleRequest(handleRequest, forcedVariationsToken, getRequestMetadata(ctx), decisionContext); err != nil {
+		return nil, nil, toStatusError(err)
+	}
+	err = apilogic.ComputeCampaigns(handleRequest, decisionContext, utils.NewTracker())
+	if withFallback {
+		if fallbackFlags, ok := apilogic.DecisionFallbackFlags(handleRequest, decisionContext, err); ok {
+			return handleRequest, fallbackFlags, nil
+		}
+	}
+	if err != nil {
+		return nil, nil, toStatusError(err)
+	}
+
+	if handleRequest.Environment.Common.IsPanic {
+		handleRequest.DecisionResponse = &decision_response.DecisionResponse{
+			VisitorId: req.GetVisitorId(),
+			Campaigns: []*decision_response.Campaign{},
+		}
+	}
+
+	return handleRequest, nil, nil
+}
+
+// GetCampaigns returns the campaigns decision for the visitor
+func (s *DecisionServer) GetCampaigns(ctx context.Context, req *decision_request.DecisionRequest) (*decision_response.DecisionResponse, error) {
+	handleRequest, _, err := s.computeDecision(ctx, req, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return handleRequest.DecisionResponse, nil
+}
+
+// GetCampaign returns the decision for the visitor and the campaign ID or slug of the request
+func (s *DecisionServer) GetCampaign(ctx context.Context, req *decision_request.DecisionRequest) (*decision_response.Campaign, error) {
+	if req.GetCampaignId().GetValue() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing campaign_id")
+	}
+
+	handleRequest, _, err := s.computeDecision(ctx, req, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(handleRequest.DecisionResponse.Campaigns) == 0 {
+		return nil, status.Errorf(codes.NotFound, "no variation assigned for campaign %s", req.GetCampaignId().GetValue())
+	}
+	return handleRequest.DecisionResponse.Campaigns[0], nil
+}
+
+// GetFlags returns the flags values and metadata for the visitor.
+// The fallback flags are returned without metadata when the environment can not be loaded or is in panic mode
+func (s *DecisionServer) GetFlags(ctx context.Context, req *decision_request.DecisionRequest) (*flags.FlagInfos, error) {
+	handleRequest, fallbackFlags, err := s.computeDecision(ctx, req, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	flagInfos := &flags.FlagInfos{
+		Flags: map[string]*flags.FlagInfo{},
+	}
+	if fallbackFlags != nil {
+		for k, v := range fallbackFlags {
+			value, err := structpb.NewValue(v)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "invalid fallback flag %s: %v", k, err)
+			}
+			flagInfos.Flags[k] = &flags.FlagInfo{Value: value}
+		}
+		return flagInfos, nil
+	}
+	for _, c := range handleRequest.DecisionResponse.Campaigns {
+		for k, v := range c.GetVariation().GetModifications().GetValue().GetFields() {
+			flagInfos.Flags[k] = &flags.FlagInfo{
+				Value: v,
+				Metadata: &flags.FlagMetadata{
+					CampaignId:         c.GetId().GetValue(),
+					CampaignName:       c.GetName().GetValue(),
+					VariationGroupId:   c.GetVariationGroupId().GetValue(),
+					VariationGroupName: c.GetVariationGroupName().GetValue(),
+					VariationId:        c.GetVariation().GetId().GetValue(),
+					VariationName:      c.GetVariation().GetName().GetValue(),
+				},
+			}
+		}
+	}
+	return flagInfos, nil
+}
+
+// Activate activates a campaign variation for the visitor
+func (s *DecisionServer) Activate(ctx context.Context, req *activate_request.ActivateRequest) (*emptypb.Empty, error) {
+	decisionContext, err := s.getDecisionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = apilogic.ActivateCampaigns(decisionContext, []*activate_request.ActivateRequest{req})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func unaryHandler[Req any, Resp any](call func(*DecisionServer, context.Context, *Req) (Resp, error), method string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(Req)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(*DecisionServer), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/" + ServiceName + "/" + method,
+		}
+		return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(*DecisionServer), ctx, req.(*Req))
+		})
+	}
+}
+
+// serviceDesc describes the decision service. It uses the flagship-proto messages for requests and responses
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "GetCampaigns", Handler: unaryHandler((*DecisionServer).GetCampaigns, "GetCampaigns")},
+		{MethodName: "GetCampaign", Handler: unaryHandler((*DecisionServer).GetCampaign, "GetCampaign")},
+		{MethodName: "GetFlags", Handler: unaryHandler((*DecisionServer).GetFlags, "GetFlags")},
+		{MethodName: "Activate", Handler: unaryHandler((*DecisionServer).Activate, "Activate")},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "decision_service.proto",
+}
@@ -0,0 +1,260 @@
+package grpc_server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
+	"github.com/flagship-io/decision-api/pkg/utils/qa"
+	"github.com/flagship-io/flagship-proto/activate_request"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"github.com/flagship-io/flagship-proto/decision_response"
+	"github.com/flagship-io/flagship-proto/flags"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// dialTestServer serves the decision contexts on an in-memory listener and returns a client connection to it
+func dialTestServer(t *testing.T, decisionContext *connectors.DecisionContext, metrics *middlewares.MetricsRegistry) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(Options{
+		DecisionContexts: []*connectors.DecisionContext{decisionContext},
+		AuthOptions:      &models.AuthOptions{Enabled: true},
+		Logger:           decisionContext.Logger,
+		Recover:          true,
+		Metrics:          metrics,
+	})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDecisionServer(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+
+	metrics := middlewares.NewMetricsRegistry()
+	conn := dialTestServer(t, decisionContext, metrics)
+	var err error
+
+	req := &decision_request.DecisionRequest{
+		VisitorId:  wrapperspb.String("visitor_id"),
+		TriggerHit: wrapperspb.Bool(false),
+		Context:    map[string]*structpb.Value{},
+	}
+
+	// missing API key
+	err = conn.Invoke(context.Background(), "/"+ServiceName+"/GetCampaigns", req, &decision_response.DecisionResponse{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", decisionContext.APIKey)
+
+	campaigns := &decision_response.DecisionResponse{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_id", campaigns.VisitorId.GetValue())
+	assert.Len(t, campaigns.Campaigns, 2)
+
+	flagInfos := &flags.FlagInfos{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetFlags", req, flagInfos)
+	assert.Nil(t, err)
+	assert.Equal(t, "string", flagInfos.Flags["testString"].Value.GetStringValue())
+	assert.Equal(t, "campaign_1", flagInfos.Flags["testString"].Metadata.CampaignId)
+
+	campaign := &decision_response.Campaign{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaign", req, campaign)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.CampaignId = wrapperspb.String("image")
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaign", req, campaign)
+	assert.Nil(t, err)
+	assert.Equal(t, "vg_1", campaign.VariationGroupId.GetValue())
+
+	err = conn.Invoke(ctx, "/"+ServiceName+"/Activate", &activate_request.ActivateRequest{}, &emptypb.Empty{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = conn.Invoke(ctx, "/"+ServiceName+"/Activate", &activate_request.ActivateRequest{
+		Cid:  decisionContext.EnvID,
+		Vid:  "visitor_id",
+		Caid: "vg_1",
+		Vaid: "v_1",
+	}, &emptypb.Empty{})
+	assert.Nil(t, err)
+	hitsProcessor := decisionContext.HitsProcessor.(*hits_processors.MockHitProcessor)
+	assert.Equal(t, "vg_1", hitsProcessor.TrackedHits.CampaignActivations[0].CampaignID)
+
+	validator, err := contextschema.NewValidator(&models.ContextSchemaOptions{
+		Mode:   models.ContextSchemaModeReject,
+		Fields: []*models.ContextField{{Key: "age", Type: "number"}},
+	})
+	assert.Nil(t, err)
+	decisionContext.ContextSchema = validator
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaigns", &decision_request.DecisionRequest{
+		VisitorId:  wrapperspb.String("visitor_id"),
+		TriggerHit: wrapperspb.Bool(false),
+		Context:    map[string]*structpb.Value{"age": structpb.NewStringValue("30")},
+	}, campaigns)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	details := status.Convert(err).Details()
+	assert.Len(t, details, 1)
+	badRequest, ok := details[0].(*errdetails.BadRequest)
+	assert.True(t, ok)
+	assert.Equal(t, "context.age", badRequest.GetFieldViolations()[0].GetField())
+	assert.Equal(t, "Expected a number.", badRequest.GetFieldViolations()[0].GetDescription())
+	decisionContext.ContextSchema = nil
+
+	unknownEnvCtx := metadata.AppendToOutgoingContext(ctx, "x-env-id", "unknown")
+	err = conn.Invoke(unknownEnvCtx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	w := httptest.NewRecorder()
+	metrics.PrometheusHandler()(w, httptest.NewRequest(http.MethodGet, "/v2/metrics/prometheus", nil))
+	assert.Contains(t, w.Body.String(), `decision_api_request_duration_seconds_count{code="NotFound",handler="grpc_GetCampaigns"} 1`)
+}
+
+func TestDecisionServerFallback(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	loader := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader)
+	loader.ErrorReturned = errors.New("cdn unreachable")
+
+	conn := dialTestServer(t, decisionContext, middlewares.NewMetricsRegistry())
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", decisionContext.APIKey)
+	req := &decision_request.DecisionRequest{
+		VisitorId:  wrapperspb.String("visitor_id"),
+		TriggerHit: wrapperspb.Bool(false),
+		Context:    map[string]*structpb.Value{"plan": structpb.NewStringValue("premium")},
+	}
+
+	// without fallback flags, the degraded responses are unchanged
+	err := conn.Invoke(ctx, "/"+ServiceName+"/GetFlags", req, &flags.FlagInfos{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	decisionContext.FallbackOptions = &models.FallbackOptions{Flags: []*models.FallbackFlag{
+		{Key: "btnColor", Value: "red"},
+		{Key: "limit", Value: 10, Targeting: []*models.FallbackTargeting{{Key: "plan", Operator: "EQUALS", Value: "free"}}},
+	}}
+
+	flagInfos := &flags.FlagInfos{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetFlags", req, flagInfos)
+	assert.Nil(t, err)
+	assert.Len(t, flagInfos.Flags, 1)
+	assert.Equal(t, "red", flagInfos.Flags["btnColor"].Value.GetStringValue())
+	assert.Nil(t, flagInfos.Flags["btnColor"].Metadata)
+
+	// the campaigns are not served with fallback flags
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaigns", req, &decision_response.DecisionResponse{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// panic mode
+	loader.ErrorReturned = nil
+	loader.MockedEnvironment.Common.IsPanic = true
+	flagInfos = &flags.FlagInfos{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetFlags", req, flagInfos)
+	assert.Nil(t, err)
+	assert.Equal(t, "red", flagInfos.Flags["btnColor"].Value.GetStringValue())
+
+	campaigns := &decision_response.DecisionResponse{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Nil(t, err)
+	assert.Empty(t, campaigns.Campaigns)
+}
+
+func TestGetRequestMetadata(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"user-agent", "Mozilla/5.0",
+		"accept-language", "fr-FR,fr;q=0.9",
+		"x-forwarded-for", "10.0.0.1",
+		"x-forwarded-for", "203.0.113.7",
+	))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}})
+
+	assert.Equal(t, apilogic.RequestMetadata{
+		UserAgent:      "Mozilla/5.0",
+		AcceptLanguage: "fr-FR,fr;q=0.9",
+		ForwardedFor:   "10.0.0.1,203.0.113.7",
+		RemoteAddr:     "192.0.2.1:1234",
+	}, getRequestMetadata(ctx))
+	assert.Equal(t, apilogic.RequestMetadata{}, getRequestMetadata(context.Background()))
+}
+
+func TestDecisionServerForcedVariationsAndOverrides(t *testing.T) {
+	decisionContext := utils.CreateMockDecisionContext()
+	environment := decisionContext.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	decisionContext.ForcedVariationsOptions = &models.ForcedVariationsOptions{Secret: "secret"}
+	manager, err := overrides.NewManager("", decisionContext.Logger)
+	assert.Nil(t, err)
+	decisionContext.Overrides = manager
+	_, err = manager.Update(func(o *models.Overrides) {
+		o.Flags = map[string]interface{}{"testString": "overridden"}
+		o.PausedCampaigns = []string{"image"}
+	})
+	assert.Nil(t, err)
+
+	conn := dialTestServer(t, decisionContext, middlewares.NewMetricsRegistry())
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", decisionContext.APIKey)
+	req := &decision_request.DecisionRequest{
+		VisitorId:  wrapperspb.String("visitor_id"),
+		TriggerHit: wrapperspb.Bool(false),
+	}
+
+	// the local overrides are applied like on the HTTP decisions
+	flagInfos := &flags.FlagInfos{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetFlags", req, flagInfos)
+	assert.Nil(t, err)
+	assert.Equal(t, "overridden", flagInfos.Flags["testString"].Value.GetStringValue())
+	campaigns := &decision_response.DecisionResponse{}
+	err = conn.Invoke(ctx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Nil(t, err)
+	assert.Len(t, campaigns.Campaigns, 1)
+	assert.Equal(t, "campaign_1", campaigns.Campaigns[0].GetId().GetValue())
+
+	// the forced variations token is read from the x-forced-variations metadata
+	token, err := qa.SignForcedVariations("secret", &qa.ForcedVariations{
+		Variations: map[string]string{"campaign_1": "v_2"},
+		VisitorID:  "visitor_id",
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Nil(t, err)
+	forcedCtx := metadata.AppendToOutgoingContext(ctx, "x-forced-variations", token)
+	err = conn.Invoke(forcedCtx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Nil(t, err)
+	assert.Equal(t, "v_2", campaigns.Campaigns[0].GetVariation().GetId().GetValue())
+
+	invalidCtx := metadata.AppendToOutgoingContext(ctx, "x-forced-variations", token+"invalid")
+	err = conn.Invoke(invalidCtx, "/"+ServiceName+"/GetCampaigns", req, campaigns)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
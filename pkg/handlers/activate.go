@@ -1,17 +1,13 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"net/http"
-	"time"
 
+	"github.com/flagship-io/decision-api/internal/apilogic"
 	"github.com/flagship-io/decision-api/internal/utils"
-	"github.com/flagship-io/decision-api/internal/validation"
 	"github.com/flagship-io/decision-api/pkg/connectors"
-	"github.com/flagship-io/decision-api/pkg/models"
-	decision "github.com/flagship-io/flagship-common"
 	"github.com/flagship-io/flagship-proto/activate_request"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -55,94 +51,17 @@ func Activate(context *connectors.DecisionContext) func(http.ResponseWriter, *ht
 			activateItems = []*activate_request.ActivateRequest{activateRequest}
 		}
 
-		// error management & campaign activations
-		errorsLength := 0
-		errors := make(chan error)
-		campaignActivations := []*models.CampaignActivation{}
+		err = apilogic.ActivateCampaigns(context, activateItems)
 
-		for _, activateItem := range activateItems {
-			if bodyErr := validation.CheckErrorBody(context.EnvID, activateItem); bodyErr != nil {
-				data, _ := json.Marshal(bodyErr)
-				utils.WriteClientError(w, http.StatusBadRequest, string(data))
-				return
-			}
-
-			now := time.Now()
-
-			visitorID := activateItem.Vid
-			// If anonymous id is defined
-			if activateItem.Aid != nil {
-				visitorID = activateItem.Aid.Value
-			}
-
-			shouldPersistActivation := false
-			environment, err := context.EnvironmentLoader.LoadEnvironment(activateItem.Cid, context.APIKey)
-			if err != nil {
-				log.Printf("Error when reading existing environment : %v", err)
-			} else {
-				shouldPersistActivation = environment.Common.CacheEnabled && environment.Common.SingleAssignment
-			}
-
-			if shouldPersistActivation {
-				existingAssignments, err := context.AssignmentsManager.LoadAssignments(activateItem.Cid, activateItem.Vid)
-				if err != nil {
-					log.Printf("Error when reading existing assignments : %v", err)
-				}
-
-				var vgAssign *decision.VisitorCache
-				if existingAssignments != nil {
-					vgAssign = existingAssignments.Assignments[activateItem.Caid]
-				}
-
-				shouldPersistActivation = vgAssign == nil || !vgAssign.Activated || vgAssign.VariationID != activateItem.Vaid
-			}
-
-			if shouldPersistActivation {
-				errorsLength++
-				go func(activateItem *activate_request.ActivateRequest) {
-					var err error = nil
-					if context.AssignmentsManager.ShouldSaveAssignments(connectors.SaveAssignmentsContext{
-						AssignmentScope: connectors.Activation,
-					}) {
-						err = context.AssignmentsManager.SaveAssignments(context.EnvID, activateItem.Vid, map[string]*decision.VisitorCache{
-							activateItem.Caid: {
-								VariationID: activateItem.Vaid,
-								Activated:   true,
-							},
-						}, now)
-					}
-					errors <- err
-				}(activateItem)
-			}
-
-			campaignActivations = append(campaignActivations, &models.CampaignActivation{
-				EnvID:           activateItem.Cid,
-				VisitorID:       visitorID,
-				CustomerID:      activateItem.Vid,
-				CampaignID:      activateItem.Caid,
-				VariationID:     activateItem.Vaid,
-				Timestamp:       now.UnixNano() / 1000000,
-				PersistActivate: shouldPersistActivation,
-				QA:              activateItem.Qa,
-				QueueTime:       activateItem.Qt,
-			})
+		var apiErr *apilogic.Error
+		if errors.As(err, &apiErr) {
+			utils.WriteClientError(w, apiErr.Status, apiErr.Message)
+			return
 		}
 
-		errorsLength++
-		go func() {
-			errors <- context.HitsProcessor.TrackHits(
-				connectors.TrackingHits{
-					CampaignActivations: campaignActivations,
-				})
-
-		}()
-
-		for i := 0; i < errorsLength; i++ {
-			err := <-errors
-			if err != nil {
-				utils.WriteServerError(w, err)
-				return
-			}
+		if err != nil {
+			utils.WriteServerError(w, err)
+			return
 		}
 
 		// Return a response with a 200 OK status and the campaign payload as an example
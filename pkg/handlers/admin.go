@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	common "github.com/flagship-io/flagship-common"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// AdminVariation represents a variation of the loaded environment
+type AdminVariation struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Allocation    float32         `json:"allocation"`
+	Reference     bool            `json:"reference"`
+	Modifications json.RawMessage `json:"modifications,omitempty"`
+}
+
+// AdminVariationGroup represents a variation group of the loaded environment
+type AdminVariationGroup struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Targeting  json.RawMessage   `json:"targeting,omitempty"`
+	Variations []*AdminVariation `json:"variations"`
+}
+
+// AdminCampaign represents a campaign of the loaded environment
+type AdminCampaign struct {
+	ID              string                 `json:"id"`
+	Name            string                 `json:"name"`
+	Slug            *string                `json:"slug,omitempty"`
+	Type            string                 `json:"type"`
+	CreatedAt       time.Time              `json:"created_at"`
+	VariationGroups []*AdminVariationGroup `json:"variation_groups"`
+}
+
+// AdminAccountSettings represents the account settings of the loaded environment
+type AdminAccountSettings struct {
+	SingleAssignment  bool `json:"single_assignment"`
+	UseReconciliation bool `json:"use_reconciliation"`
+	CacheEnabled      bool `json:"cache_enabled"`
+	HasIntegrations   bool `json:"has_integrations"`
+}
+
+// AdminEnvironmentResponse represents the environment currently served by the API
+type AdminEnvironmentResponse struct {
+	EnvID           string                `json:"env_id"`
+	Panic           bool                  `json:"panic"`
+	LastUpdate      *time.Time            `json:"last_update,omitempty"`
+	LastModified    string                `json:"last_modified,omitempty"`
+	AccountSettings *AdminAccountSettings `json:"account_settings"`
+	Campaigns       []*AdminCampaign      `json:"campaigns"`
+}
+
+// AdminVisitorCache represents the assigned variation of a variation group
+type AdminVisitorCache struct {
+	VariationID string `json:"variation_id"`
+	Activated   bool   `json:"activated"`
+}
+
+// AdminAssignmentsResponse represents the stored assignments of a visitor
+type AdminAssignmentsResponse struct {
+	VisitorID   string                        `json:"visitor_id"`
+	Timestamp   int64                         `json:"timestamp"`
+	Assignments map[string]*AdminVisitorCache `json:"assignments"`
+}
+
+func marshalProto(message proto.Message) json.RawMessage {
+	if message == nil {
+		return nil
+	}
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func newAdminEnvironmentResponse(envID string, environment *models.Environment) *AdminEnvironmentResponse {
+	response := &AdminEnvironmentResponse{
+		EnvID:     envID,
+		Campaigns: []*AdminCampaign{},
+		AccountSettings: &AdminAccountSettings{
+			HasIntegrations: environment.HasIntegrations,
+		},
+	}
+	if environment.Common == nil {
+		return response
+	}
+
+	response.Panic = environment.Common.IsPanic
+	response.AccountSettings.SingleAssignment = environment.Common.SingleAssignment
+	response.AccountSettings.UseReconciliation = environment.Common.UseReconciliation
+	response.AccountSettings.CacheEnabled = environment.Common.CacheEnabled
+
+	for _, c := range environment.Common.Campaigns {
+		campaign := &AdminCampaign{
+			ID:              c.ID,
+			Name:            c.Name,
+			Slug:            c.Slug,
+			Type:            c.Type,
+			CreatedAt:       c.CreatedAt,
+			VariationGroups: []*AdminVariationGroup{},
+		}
+		for _, vg := range c.VariationGroups {
+			variationGroup := &AdminVariationGroup{
+				ID:         vg.ID,
+				Name:       vg.Name,
+				CreatedAt:  vg.CreatedAt,
+				Targeting:  marshalProto(vg.Targetings),
+				Variations: []*AdminVariation{},
+			}
+			for _, v := range vg.Variations {
+				variationGroup.Variations = append(variationGroup.Variations, &AdminVariation{
+					ID:            v.ID,
+					Name:          v.Name,
+					Allocation:    v.Allocation,
+					Reference:     v.Reference,
+					Modifications: marshalProto(v.Modifications),
+				})
+			}
+			campaign.VariationGroups = append(campaign.VariationGroups, variationGroup)
+		}
+		response.Campaigns = append(response.Campaigns, campaign)
+	}
+	return response
+}
+
+func writeAdminEnvironment(w http.ResponseWriter, context *connectors.DecisionContext) {
+	environment, err := context.EnvironmentLoader.LoadEnvironment(context.EnvID, context.APIKey)
+	if err != nil {
+		utils.WriteServerError(w, err)
+		return
+	}
+
+	response := newAdminEnvironmentResponse(context.EnvID, environment)
+	if statusProvider, ok := context.EnvironmentLoader.(connectors.EnvironmentStatusProvider); ok {
+		status := statusProvider.EnvironmentStatus(context.EnvID)
+		if status.Loaded {
+			response.LastUpdate = &status.LastUpdate
+		}
+		response.LastModified = status.LastModified
+	}
+	utils.WriteJSONOk(w, response)
+}
+
+// AdminEnvironment returns the environment currently served by the API
+// @Summary Get the loaded environment
+// @Tags Admin
+// @Description Get the campaigns, variation groups, panic flag and account settings of the environment currently served by the API
+// @ID admin-environment
+// @Produce  json
+// @Success 200 {object} AdminEnvironmentResponse
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/environment [get]
+func AdminEnvironment(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeAdminEnvironment(w, context)
+	}
+}
+
+// AdminRefreshEnvironment refreshes the environment immediately and returns it
+// @Summary Refresh the environment
+// @Tags Admin
+// @Description Fetch the environment immediately instead of waiting for the next polling
+// @ID admin-environment-refresh
+// @Produce  json
+// @Success 200 {object} AdminEnvironmentResponse
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Failure 501 {object} errorMessage
+// @Router /admin/environment/refresh [post]
+func AdminRefreshEnvironment(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		refresher, ok := context.EnvironmentLoader.(connectors.EnvironmentRefresher)
+		if !ok {
+			utils.WriteClientError(w, http.StatusNotImplemented, connectors.ErrNotSupported.Error())
+			return
+		}
+		if err := refresher.RefreshEnvironment(context.EnvID); err != nil {
+			utils.WriteServerError(w, err)
+			return
+		}
+		writeAdminEnvironment(w, context)
+	}
+}
+
+// AdminVisitorAssignments returns the stored assignments of a visitor
+// @Summary Get the visitor assignments
+// @Tags Admin
+// @Description Get the variations assigned to a visitor from the assignments manager
+// @ID admin-visitor-assignments
+// @Produce  json
+// @Param visitorId path string true "Visitor ID"
+// @Success 200 {object} AdminAssignmentsResponse
+// @Failure 401 {object} errorMessage
+// @Failure 404 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/visitors/{visitorId}/assignments [get]
+func AdminVisitorAssignments(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		visitorID := req.PathValue("visitorId")
+		assignments, err := context.AssignmentsManager.LoadAssignments(context.EnvID, visitorID)
+		if err != nil {
+			utils.WriteServerError(w, err)
+			return
+		}
+		if assignments == nil {
+			utils.WriteClientError(w, http.StatusNotFound, "no assignments found for the visitor")
+			return
+		}
+
+		response := &AdminAssignmentsResponse{
+			VisitorID:   visitorID,
+			Timestamp:   assignments.Timestamp,
+			Assignments: map[string]*AdminVisitorCache{},
+		}
+		for vgID, cache := range assignments.Assignments {
+			response.Assignments[vgID] = newAdminVisitorCache(cache)
+		}
+		utils.WriteJSONOk(w, response)
+	}
+}
+
+func newAdminVisitorCache(cache *common.VisitorCache) *AdminVisitorCache {
+	if cache == nil {
+		return nil
+	}
+	return &AdminVisitorCache{VariationID: cache.VariationID, Activated: cache.Activated}
+}
+
+// AdminDeleteVisitorAssignments deletes the stored assignments of a visitor
+// @Summary Delete the visitor assignments
+// @Tags Admin
+// @Description Delete the variations assigned to a visitor from the assignments manager
+// @ID admin-delete-visitor-assignments
+// @Param visitorId path string true "Visitor ID"
+// @Success 204
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Failure 501 {object} errorMessage
+// @Router /admin/visitors/{visitorId}/assignments [delete]
+func AdminDeleteVisitorAssignments(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		deleter, ok := context.AssignmentsManager.(connectors.AssignmentsDeleter)
+		if !ok {
+			utils.WriteClientError(w, http.StatusNotImplemented, connectors.ErrNotSupported.Error())
+			return
+		}
+
+		err := deleter.DeleteAssignments(context.EnvID, req.PathValue("visitorId"))
+		if errors.Is(err, connectors.ErrNotSupported) {
+			utils.WriteClientError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		if err != nil {
+			utils.WriteServerError(w, err)
+			return
+		}
+		utils.WriteNoContent(w)
+	}
+}
+
+// AdminFlushHits sends the queued hits immediately
+// @Summary Flush the hits
+// @Tags Admin
+// @Description Send the hits queued by the hits processor immediately
+// @ID admin-flush-hits
+// @Success 204
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Failure 501 {object} errorMessage
+// @Router /admin/hits/flush [post]
+func AdminFlushHits(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := context.HitsProcessor.(connectors.HitsFlusher)
+		if !ok {
+			utils.WriteClientError(w, http.StatusNotImplemented, connectors.ErrNotSupported.Error())
+			return
+		}
+		if err := flusher.Flush(req.Context()); err != nil {
+			utils.WriteServerError(w, err)
+			return
+		}
+		utils.WriteNoContent(w)
+	}
+}
+
+// AdminSimulateCampaign simulates the targeting and allocation of a campaign for synthetic visitors
+// @Summary Simulate a campaign
+// @Tags Admin
+// @Description Evaluate the targeting and allocation of a campaign of the loaded environment for listed or generated visitor IDs
+// @Description and a context template, without triggering hits nor saving assignments. The string values of the context template
+// @Description can hold the {{visitor_id}} and {{index}} placeholders. The result compares the variations distribution to their allocation
+// @ID admin-simulate-campaign
+// @Accept  json
+// @Produce  json
+// @Param campaignId path string true "Campaign ID"
+// @Param request body apilogic.SimulationRequest true "Simulation request body"
+// @Success 200 {object} apilogic.SimulationResult
+// @Failure 400 {object} errorMessage
+// @Failure 401 {object} errorMessage
+// @Failure 404 {object} errorMessage
+// @Failure 408 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/campaigns/{campaignId}/simulation [post]
+func AdminSimulateCampaign(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		request := &apilogic.SimulationRequest{}
+		if err := json.NewDecoder(req.Body).Decode(request); err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		environment, err := context.EnvironmentLoader.LoadEnvironment(context.EnvID, context.APIKey)
+		if err != nil {
+			utils.WriteServerError(w, err)
+			return
+		}
+
+		result, err := apilogic.SimulateCampaign(req.Context(), environment, req.PathValue("campaignId"), request)
+		if err != nil {
+			writeDecisionError(w, err)
+			return
+		}
+		utils.WriteJSONOk(w, result)
+	}
+}
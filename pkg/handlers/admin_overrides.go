@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
+)
+
+// updateOverrides applies the change to the local overrides and writes the resulting overrides
+func updateOverrides(w http.ResponseWriter, context *connectors.DecisionContext, change func(o *models.Overrides)) {
+	result, err := context.Overrides.Update(change)
+	if err != nil {
+		utils.WriteServerError(w, err)
+		return
+	}
+	context.Logger.Warnf("local overrides updated from admin API")
+	utils.WriteJSONOk(w, result)
+}
+
+// AdminOverrides returns the local overrides
+// @Summary Get the local overrides
+// @Tags Admin
+// @Description Get the local overrides applied on top of the loaded environment
+// @ID admin-overrides
+// @Produce  json
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Router /admin/overrides [get]
+func AdminOverrides(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		utils.WriteJSONOk(w, context.Overrides.Overrides())
+	}
+}
+
+// AdminReplaceOverrides replaces the local overrides
+// @Summary Replace the local overrides
+// @Tags Admin
+// @Description Replace the local overrides applied on top of the loaded environment. They are written to the overrides file if set
+// @ID admin-replace-overrides
+// @Accept  json
+// @Produce  json
+// @Param request body models.Overrides true "Overrides"
+// @Success 200 {object} models.Overrides
+// @Failure 400 {object} errorMessage
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides [put]
+func AdminReplaceOverrides(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		replacement := &models.Overrides{}
+		if err := json.NewDecoder(req.Body).Decode(replacement); err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updateOverrides(w, context, func(o *models.Overrides) {
+			*o = *replacement
+		})
+	}
+}
+
+// AdminClearOverrides removes all the local overrides
+// @Summary Clear the local overrides
+// @Tags Admin
+// @Description Remove all the local overrides applied on top of the loaded environment
+// @ID admin-clear-overrides
+// @Produce  json
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides [delete]
+func AdminClearOverrides(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		updateOverrides(w, context, func(o *models.Overrides) {
+			*o = models.Overrides{}
+		})
+	}
+}
+
+// AdminSetPanicOverride enables or disables the panic mode override
+// @Summary Toggle the panic mode override
+// @Tags Admin
+// @Description Enable (PUT) or disable (DELETE) the panic mode of the loaded environment
+// @ID admin-panic-override
+// @Produce  json
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides/panic [put]
+// @Router /admin/overrides/panic [delete]
+func AdminSetPanicOverride(context *connectors.DecisionContext, enabled bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		updateOverrides(w, context, func(o *models.Overrides) {
+			o.Panic = enabled
+		})
+	}
+}
+
+// AdminSetFlagOverride forces the value of a flag key
+// @Summary Force a flag value
+// @Tags Admin
+// @Description Force the value of a flag key in the variations providing it
+// @ID admin-set-flag-override
+// @Accept  json
+// @Produce  json
+// @Param key path string true "Flag key"
+// @Param value body interface{} true "Flag value"
+// @Success 200 {object} models.Overrides
+// @Failure 400 {object} errorMessage
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides/flags/{key} [put]
+func AdminSetFlagOverride(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var value interface{}
+		if err := json.NewDecoder(req.Body).Decode(&value); err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		updateOverrides(w, context, func(o *models.Overrides) {
+			if o.Flags == nil {
+				o.Flags = map[string]interface{}{}
+			}
+			o.Flags[req.PathValue("key")] = value
+		})
+	}
+}
+
+// AdminDeleteFlagOverride removes the forced value of a flag key
+// @Summary Remove a forced flag value
+// @Tags Admin
+// @Description Remove the forced value of a flag key
+// @ID admin-delete-flag-override
+// @Produce  json
+// @Param key path string true "Flag key"
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides/flags/{key} [delete]
+func AdminDeleteFlagOverride(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		updateOverrides(w, context, func(o *models.Overrides) {
+			delete(o.Flags, req.PathValue("key"))
+		})
+	}
+}
+
+// AdminSetPausedCampaignOverride pauses or resumes a campaign
+// @Summary Toggle the paused campaign override
+// @Tags Admin
+// @Description Pause (PUT) or resume (DELETE) a campaign of the loaded environment
+// @ID admin-paused-campaign-override
+// @Produce  json
+// @Param campaignId path string true "Campaign ID"
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides/campaigns/{campaignId}/paused [put]
+// @Router /admin/overrides/campaigns/{campaignId}/paused [delete]
+func AdminSetPausedCampaignOverride(context *connectors.DecisionContext, enabled bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		updateOverrides(w, context, func(o *models.Overrides) {
+			o.PausedCampaigns = overrides.Toggle(o.PausedCampaigns, req.PathValue("campaignId"), enabled)
+		})
+	}
+}
+
+// AdminSetReferenceCampaignOverride pins a campaign to its reference variation, or unpins it
+// @Summary Toggle the reference campaign override
+// @Tags Admin
+// @Description Pin (PUT) or unpin (DELETE) all the visitors of a campaign of the loaded environment to its reference variation.
+// @Description The pinned variations are not saved as visitor assignments
+// @ID admin-reference-campaign-override
+// @Produce  json
+// @Param campaignId path string true "Campaign ID"
+// @Success 200 {object} models.Overrides
+// @Failure 401 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /admin/overrides/campaigns/{campaignId}/reference [put]
+// @Router /admin/overrides/campaigns/{campaignId}/reference [delete]
+func AdminSetReferenceCampaignOverride(context *connectors.DecisionContext, enabled bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		updateOverrides(w, context, func(o *models.Overrides) {
+			o.ReferenceCampaigns = overrides.Toggle(o.ReferenceCampaigns, req.PathValue("campaignId"), enabled)
+		})
+	}
+}
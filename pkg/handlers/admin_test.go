@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/stretchr/testify/assert"
+)
+
+type refreshLoader struct {
+	statusLoader
+	refreshed bool
+}
+
+func (l *refreshLoader) RefreshEnvironment(envID string) error {
+	l.refreshed = true
+	return nil
+}
+
+type flushHitsProcessor struct {
+	hits_processors.MockHitProcessor
+	flushed bool
+}
+
+func (p *flushHitsProcessor) Flush(ctx context.Context) error {
+	p.flushed = true
+	return nil
+}
+
+func TestAdminEnvironment(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	loader := &refreshLoader{}
+	loader.MockedEnvironment, _ = context.EnvironmentLoader.LoadEnvironment(context.EnvID, context.APIKey)
+	loader.status = connectors.EnvironmentStatus{Loaded: true, LastUpdate: time.Now(), LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+
+	// loaders without refresh support
+	w := httptest.NewRecorder()
+	AdminRefreshEnvironment(context)(w, httptest.NewRequest(http.MethodPost, "/v2/admin/environment/refresh", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	context.EnvironmentLoader = loader
+	w = httptest.NewRecorder()
+	AdminEnvironment(context)(w, httptest.NewRequest(http.MethodGet, "/v2/admin/environment", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := &AdminEnvironmentResponse{}
+	err := json.NewDecoder(w.Body).Decode(response)
+	assert.Nil(t, err)
+	assert.Equal(t, "env_id_1", response.EnvID)
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", response.LastModified)
+	assert.True(t, response.AccountSettings.HasIntegrations)
+	assert.Len(t, response.Campaigns, 3)
+	assert.Equal(t, "campaign_1", response.Campaigns[0].ID)
+	assert.Equal(t, "vg_2", response.Campaigns[0].VariationGroups[0].ID)
+	assert.NotEmpty(t, response.Campaigns[0].VariationGroups[0].Targeting)
+	assert.Contains(t, string(response.Campaigns[0].VariationGroups[0].Variations[0].Modifications), "testString")
+
+	w = httptest.NewRecorder()
+	AdminRefreshEnvironment(context)(w, httptest.NewRequest(http.MethodPost, "/v2/admin/environment/refresh", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, loader.refreshed)
+}
+
+func TestAdminVisitorAssignments(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	err := context.AssignmentsManager.SaveAssignments(context.EnvID, "visitor_id", map[string]*common.VisitorCache{
+		"vg_1": {VariationID: "v_1", Activated: true},
+	}, time.Now())
+	assert.Nil(t, err)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v2/admin/visitors/visitor_id/assignments", nil)
+		req.SetPathValue("visitorId", "visitor_id")
+		AdminVisitorAssignments(context)(w, req)
+		return w
+	}
+
+	w := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	response := &AdminAssignmentsResponse{}
+	err = json.NewDecoder(w.Body).Decode(response)
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_id", response.VisitorID)
+	assert.Equal(t, &AdminVisitorCache{VariationID: "v_1", Activated: true}, response.Assignments["vg_1"])
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/v2/admin/visitors/visitor_id/assignments", nil)
+	req.SetPathValue("visitorId", "visitor_id")
+	AdminDeleteVisitorAssignments(context)(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Equal(t, http.StatusNotFound, get().Code)
+}
+
+func TestAdminFlushHits(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	w := httptest.NewRecorder()
+	AdminFlushHits(context)(w, httptest.NewRequest(http.MethodPost, "/v2/admin/hits/flush", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	processor := &flushHitsProcessor{}
+	context.HitsProcessor = processor
+	w = httptest.NewRecorder()
+	AdminFlushHits(context)(w, httptest.NewRequest(http.MethodPost, "/v2/admin/hits/flush", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.True(t, processor.flushed)
+}
+
+func TestAdminSimulateCampaign(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	context := utils.CreateMockDecisionContext()
+	environment := context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.Common.CacheEnabled = true
+	// the mocked allocations are cumulative
+	environment.Common.Campaigns[0].VariationGroups[0].Variations[1].Allocation = 50
+
+	simulate := func(campaignID string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v2/admin/campaigns/"+campaignID+"/simulation", strings.NewReader(body))
+		req.SetPathValue("campaignId", campaignID)
+		AdminSimulateCampaign(context)(w, req)
+		return w
+	}
+
+	w := simulate("campaign_1", `{"count": 1000, "prefix": "visitor_", "context": {"id": "{{visitor_id}}"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	result := &apilogic.SimulationResult{}
+	err := json.NewDecoder(w.Body).Decode(result)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, result.Visitors)
+	assert.Equal(t, 1000, result.Targeted)
+	assert.Equal(t, 1.0, result.TargetingMatchRate)
+	assert.Equal(t, 1000, result.Allocated)
+	variations := result.VariationGroups[0].Variations
+	assert.Len(t, variations, 2)
+	assert.Equal(t, 1000, variations[0].Visitors+variations[1].Visitors)
+	assert.EqualValues(t, 50, variations[0].Allocation)
+	assert.InDelta(t, 0, variations[0].Deviation, 10)
+	assert.InDelta(t, variations[0].Share-50, variations[0].Deviation, 0.001)
+
+	// the simulation does not trigger hits nor save assignments
+	assert.Len(t, context.HitsProcessor.(*hits_processors.MockHitProcessor).TrackedHits.CampaignActivations, 0)
+	assignments, err := context.AssignmentsManager.LoadAssignments(context.EnvID, "visitor_0")
+	assert.Nil(t, err)
+	assert.Nil(t, assignments)
+
+	// the provider context is not available to the simulation
+	w = simulate("campaign_2", `{"visitor_ids": ["a", "b"], "contexts": [{"age": 30}, {"age": 10}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	result = &apilogic.SimulationResult{}
+	err = json.NewDecoder(w.Body).Decode(result)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Visitors)
+	assert.Equal(t, 0, result.Targeted)
+	assert.Equal(t, 0.0, result.TargetingMatchRate)
+
+	// the response keys are camel cased like the other responses
+	w = simulate("campaign_1", `{"count": 1}`)
+	assert.Contains(t, w.Body.String(), `"campaignId":"campaign_1"`)
+	assert.Contains(t, w.Body.String(), `"targetingMatchRate":`)
+	assert.Contains(t, w.Body.String(), `"inBucket":`)
+	assert.Contains(t, w.Body.String(), `"variationGroups":`)
+
+	// the simulation stops when the client goes away
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v2/admin/campaigns/campaign_1/simulation", strings.NewReader(`{"count": 1000}`)).WithContext(canceled)
+	req.SetPathValue("campaignId", "campaign_1")
+	AdminSimulateCampaign(context)(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	assert.Equal(t, http.StatusNotFound, simulate("unknown", `{"count": 1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, simulate("campaign_1", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, simulate("campaign_1", `{"count": 1, "generator": "unknown"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, simulate("campaign_1", `{"count": 1000001}`).Code)
+	assert.Equal(t, http.StatusBadRequest, simulate("campaign_1", `{"count": "1"}`).Code)
+}
+
+func TestAdminOverrides(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	manager, err := overrides.NewManager(filepath.Join(t.TempDir(), "overrides.json"), context.Logger)
+	assert.Nil(t, err)
+	context.Overrides = manager
+
+	call := func(handler func(http.ResponseWriter, *http.Request), method string, body string, pathValues ...string) *models.Overrides {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/v2/admin/overrides", strings.NewReader(body))
+		for i := 0; i < len(pathValues); i += 2 {
+			req.SetPathValue(pathValues[i], pathValues[i+1])
+		}
+		handler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		result := &models.Overrides{}
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(result))
+		return result
+	}
+
+	assert.True(t, call(AdminOverrides(context), http.MethodGet, "").IsEmpty())
+	assert.True(t, call(AdminSetPanicOverride(context, true), http.MethodPut, "").Panic)
+	assert.False(t, call(AdminSetPanicOverride(context, false), http.MethodDelete, "").Panic)
+
+	result := call(AdminSetFlagOverride(context), http.MethodPut, `false`, "key", "feature")
+	assert.Equal(t, map[string]interface{}{"feature": false}, result.Flags)
+	result = call(AdminSetPausedCampaignOverride(context, true), http.MethodPut, "", "campaignId", "campaign_1")
+	assert.Equal(t, []string{"campaign_1"}, result.PausedCampaigns)
+	result = call(AdminSetReferenceCampaignOverride(context, true), http.MethodPut, "", "campaignId", "campaign_2")
+	assert.Equal(t, []string{"campaign_2"}, result.ReferenceCampaigns)
+	assert.Equal(t, result, call(AdminOverrides(context), http.MethodGet, ""))
+
+	result = call(AdminDeleteFlagOverride(context), http.MethodDelete, "", "key", "feature")
+	assert.Empty(t, result.Flags)
+	result = call(AdminSetPausedCampaignOverride(context, false), http.MethodDelete, "", "campaignId", "campaign_1")
+	assert.Empty(t, result.PausedCampaigns)
+
+	result = call(AdminReplaceOverrides(context), http.MethodPut, `{"panic": true, "paused_campaigns": ["image"]}`)
+	assert.True(t, result.Panic)
+	assert.Equal(t, []string{"image"}, result.PausedCampaigns)
+	assert.Empty(t, result.ReferenceCampaigns)
+	assert.True(t, call(AdminClearOverrides(context), http.MethodDelete, "").IsEmpty())
+
+	w := httptest.NewRecorder()
+	AdminReplaceOverrides(context)(w, httptest.NewRequest(http.MethodPut, "/v2/admin/overrides", strings.NewReader(`{"panic": "yes"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/flagship-proto/decision_request"
+)
+
+// ndjsonContentType is the content type of the streamed batch responses, with one result per line
+const ndjsonContentType = "application/x-ndjson"
+
+// BatchError is the error of the decision of a visitor of a batch
+type BatchError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// BatchResult is the decision response of a visitor of a batch, or its error
+type BatchResult struct {
+	Index     int             `json:"index"`
+	VisitorID string          `json:"visitorId"`
+	Response  json.RawMessage `json:"response,omitempty" swaggertype:"object"`
+	Error     *BatchError     `json:"error,omitempty"`
+}
+
+// BatchResponse is the response of the batch endpoints, with the results in the order of the requests
+type BatchResponse struct {
+	Results []*BatchResult `json:"results"`
+}
+
+// CampaignsBatch returns a campaigns batch handler
+// @Summary Get all campaigns for several visitors
+// @Tags Campaigns
+// @Description Get all campaigns value and metadata for each visitor ID and context of the batch.
+// @Description Send the Accept: application/x-ndjson header to stream one result per line as soon as it is computed.
+// @Description The forced variations token of the X-Forced-Variations header applies to every visitor of the batch
+// @ID get-campaigns-batch
+// @Accept  json
+// @Produce  json
+// @Param request body []campaignsBodySwagger true "Campaigns requests body"
+// @Success 200 {object} BatchResponse
+// @Failure 400 {object} errorMessage
+// @Failure 413 {object} errorMessage
+// @Router /campaigns/batch [post]
+func CampaignsBatch(context *connectors.DecisionContext, options *models.BatchOptions) func(http.ResponseWriter, *http.Request) {
+	return batchHandler(context, options, func(handleRequest *handle.Request) ([]byte, error) {
+		if handleRequest.Environment.Common.IsPanic {
+			return utils.PanicResponse(handleRequest.DecisionRequest.VisitorId)
+		}
+		return buildCampaignsResponse(handleRequest)
+	})
+}
+
+// FlagsBatch returns a flags batch handler
+// @Summary Get all flags for several visitors
+// @Tags Flags
+// @Description Get all flags value and metadata for each visitor ID and context of the batch.
+// @Description Send the Accept: application/x-ndjson header to stream one result per line as soon as it is computed.
+// @Description The forced variations token of the X-Forced-Variations header applies to every visitor of the batch
+// @ID get-flags-batch
+// @Accept  json
+// @Produce  json
+// @Param request body []campaignsBodySwagger true "Flags requests body"
+// @Success 200 {object} BatchResponse
+// @Failure 400 {object} errorMessage
+// @Failure 413 {object} errorMessage
+// @Router /flags/batch [post]
+func FlagsBatch(context *connectors.DecisionContext, options *models.BatchOptions) func(http.ResponseWriter, *http.Request) {
+	return batchHandler(context, options, func(handleRequest *handle.Request) ([]byte, error) {
+		if handleRequest.Environment.Common.IsPanic {
+			return []byte("{}"), nil
+		}
+		return json.Marshal(buildFlagInfos(handleRequest.DecisionResponse))
+	})
+}
+
+// batchHandler computes the decisions of the batch and formats each of them with formatResponse
+func batchHandler(context *connectors.DecisionContext, options *models.BatchOptions, formatResponse func(*handle.Request) ([]byte, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		requests, parseErrors, err := utils.GetBatchDecisionRequests(req)
+		if err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if options.MaxSize > 0 && len(requests) > options.MaxSize {
+			utils.WriteClientError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the batch contains %d requests, the maximum is %d", len(requests), options.MaxSize))
+			return
+		}
+
+		stream := strings.Contains(req.Header.Get("Accept"), ndjsonContentType)
+		controller := http.NewResponseController(w)
+		// large batches can outlive the write timeout of the server
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		results := make([]*BatchResult, len(requests))
+		encoder := json.NewEncoder(w)
+		if stream {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		addResult := func(result *BatchResult) {
+			results[result.Index] = result
+			if !stream {
+				return
+			}
+			if err := encoder.Encode(result); err != nil {
+				context.Logger.Warnf("error when streaming batch result: %v", err)
+				return
+			}
+			_ = controller.Flush()
+		}
+
+		// invalid requests are answered first, the valid ones are decided in parallel
+		validRequests := []*decision_request.DecisionRequest{}
+		indexes := []int{}
+		for i, decisionRequest := range requests {
+			if parseErrors[i] != nil {
+				addResult(&BatchResult{Index: i, Error: &BatchError{Status: http.StatusBadRequest, Message: parseErrors[i].Error()}})
+				continue
+			}
+			validRequests = append(validRequests, decisionRequest)
+			indexes = append(indexes, i)
+		}
+
+		apilogic.HandleBatch(req, context, validRequests, options.Concurrency, func(decision *apilogic.BatchDecision) {
+			addResult(newBatchResult(indexes[decision.Index], decision, formatResponse))
+		})
+
+		if !stream {
+			utils.WriteJSONOk(w, &BatchResponse{Results: results})
+		}
+	}
+}
+
+func newBatchResult(index int, decision *apilogic.BatchDecision, formatResponse func(*handle.Request) ([]byte, error)) *BatchResult {
+	result := &BatchResult{
+		Index:     index,
+		VisitorID: decision.HandleRequest.DecisionRequest.GetVisitorId().GetValue(),
+	}
+
+	var apiErr *apilogic.Error
+	switch {
+	case errors.As(decision.Err, &apiErr):
+		result.Error = &BatchError{Status: apiErr.Status, Message: apiErr.Message}
+	case decision.Err != nil:
+		result.Error = &BatchError{Status: http.StatusBadRequest, Message: decision.Err.Error()}
+	default:
+		data, err := formatResponse(decision.HandleRequest)
+		if err != nil {
+			result.Error = &BatchError{Status: http.StatusInternalServerError, Message: err.Error()}
+			break
+		}
+		result.Response = data
+	}
+	return result
+}
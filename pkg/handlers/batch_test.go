@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const batchBody = `[
+	{"visitor_id": "visitor_1", "context": {}, "trigger_hit": false},
+	{"unknown_field": true},
+	{"visitor_id": "visitor_2", "context": {}, "trigger_hit": false}
+]`
+
+func createBatchDecisionContext() *connectors.DecisionContext {
+	context := utils.CreateMockDecisionContext()
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+	return context
+}
+
+func TestFlagsBatch(t *testing.T) {
+	options := &models.BatchOptions{MaxSize: 3, Concurrency: 1}
+	w := httptest.NewRecorder()
+	FlagsBatch(createBatchDecisionContext(), options)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/batch?sendContextEvent=false", strings.NewReader(batchBody)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := &BatchResponse{}
+	err := json.NewDecoder(w.Body).Decode(response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Results, 3)
+
+	assert.Equal(t, "visitor_1", response.Results[0].VisitorID)
+	assert.Nil(t, response.Results[0].Error)
+	flags := map[string]FlagInfo{}
+	err = json.Unmarshal(response.Results[0].Response, &flags)
+	assert.Nil(t, err)
+	assert.Equal(t, "string", flags["testString"].Value)
+
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Error.Status)
+	assert.Contains(t, response.Results[1].Error.Message, "json body is not valid")
+	assert.Equal(t, "visitor_2", response.Results[2].VisitorID)
+
+	// the batch size is capped
+	options.MaxSize = 2
+	w = httptest.NewRecorder()
+	FlagsBatch(createBatchDecisionContext(), options)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/batch", strings.NewReader(batchBody)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	FlagsBatch(createBatchDecisionContext(), options)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/batch", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCampaignsBatchStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns/batch?sendContextEvent=false", strings.NewReader(batchBody))
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	CampaignsBatch(createBatchDecisionContext(), &models.BatchOptions{Concurrency: 2})(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	results := map[int]*BatchResult{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		result := &BatchResult{}
+		err := json.Unmarshal(scanner.Bytes(), result)
+		assert.Nil(t, err)
+		results[result.Index] = result
+	}
+	assert.Len(t, results, 3)
+	assert.NotNil(t, results[1].Error)
+
+	campaigns := map[string]interface{}{}
+	err := json.Unmarshal(results[2].Response, &campaigns)
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_2", campaigns["visitorId"])
+	assert.Len(t, campaigns["campaigns"], 2)
+}
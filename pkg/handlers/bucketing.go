@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+)
+
+// Bucketing returns a bucketing file handler
+// @Summary Get the bucketing file
+// @Tags Bucketing
+// @Description Get the last bucketing file fetched from the CDN, for the SDKs running in bucketing mode. Supports the If-Modified-Since and If-None-Match conditional requests
+// @ID bucketing
+// @Produce  json
+// @Success 200 {object} object
+// @Success 304
+// @Failure 405 {object} errorMessage
+// @Failure 501 {object} errorMessage
+// @Failure 503 {object} errorMessage
+// @Router /bucketing [get]
+func Bucketing(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			utils.WriteClientError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		provider, ok := context.EnvironmentLoader.(connectors.BucketingFileProvider)
+		if !ok {
+			utils.WriteClientError(w, http.StatusNotImplemented, connectors.ErrNotSupported.Error())
+			return
+		}
+
+		file := provider.BucketingFile(context.EnvID)
+		if file == nil {
+			utils.WriteClientError(w, http.StatusServiceUnavailable, "bucketing file not loaded")
+			return
+		}
+
+		w.Header().Set("ETag", file.ETag)
+		w.Header().Set("Cache-Control", "no-cache")
+		// ServeContent handles the conditional requests and sets the Last-Modified header
+		http.ServeContent(w, req, "bucketing.json", file.LastModified, bytes.NewReader(file.Payload))
+	}
+}
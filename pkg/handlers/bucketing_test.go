@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/stretchr/testify/assert"
+)
+
+type bucketingLoader struct {
+	statusLoader
+	file *connectors.BucketingFile
+}
+
+func (l *bucketingLoader) BucketingFile(envID string) *connectors.BucketingFile {
+	return l.file
+}
+
+func TestBucketing(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	call := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/v2/bucketing", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		Bucketing(context)(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotImplemented, call(http.MethodGet, nil).Code)
+
+	loader := &bucketingLoader{}
+	context.EnvironmentLoader = loader
+	assert.Equal(t, http.StatusServiceUnavailable, call(http.MethodGet, nil).Code)
+
+	lastModified := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	loader.file = &connectors.BucketingFile{Payload: []byte(`{"campaigns":[]}`), ETag: `"etag"`, LastModified: lastModified}
+
+	w := call(http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"campaigns":[]}`, w.Body.String())
+	assert.Equal(t, `"etag"`, w.Header().Get("ETag"))
+	assert.Equal(t, lastModified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusNotModified, call(http.MethodGet, map[string]string{"If-None-Match": `"etag"`}).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, map[string]string{"If-None-Match": `"other"`}).Code)
+	assert.Equal(t, http.StatusNotModified, call(http.MethodGet, map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)}).Code)
+	w = call(http.MethodPost, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+}
@@ -17,16 +17,24 @@ import (
 // Campaign returns a campaign handler
 // @Summary Get a single campaigns for the visitor
 // @Tags Campaigns
-// @Description Get a single campaign value and metadata for a visitor ID and context
+// @Description Get a single campaign value and metadata for a visitor ID and context.
+// @Description GET requests read the visitor from the query, and are cacheable when they do not trigger hits
 // @ID get-campaign
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Campaign ID"
-// @Param request body campaignsBodySwagger true "Campaign request body"
+// @Param request body campaignsBodySwagger false "Campaign request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Param visitor_id query string false "Visitor ID of GET requests"
+// @Param anonymous_id query string false "Anonymous ID of GET requests"
+// @Param context query string false "Visitor context JSON object of GET requests. Typed values can also be sent as context.key:type=value"
+// @Param trigger_hit query boolean false "Whether to trigger hits for GET requests"
+// @Param payload query string false "Base64url encoded JSON request body, for GET requests"
 // @Success 200 {object} campaignResponse
 // @Failure 400 {object} errorMessage
 // @Failure 500 {object} errorMessage
 // @Router /campaigns/{id} [post]
+// @Router /campaigns/{id} [get]
 func Campaign(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 		apilogic.HandleCampaigns(w, req, context, requestCampaignHandler, utils.NewTracker())
@@ -94,15 +102,7 @@ func sendSingleFormatResponse(w http.ResponseWriter, campaignDecisionResponse *d
 		return
 	}
 
-	dataValue := ""
-	switch value.Kind.(type) {
-	case (*structpb.Value_StringValue):
-		dataValue = value.GetStringValue()
-	case (*structpb.Value_BoolValue):
-		dataValue = strconv.FormatBool(value.GetBoolValue())
-	case (*structpb.Value_NumberValue):
-		dataValue = strconv.FormatFloat(value.GetNumberValue(), 'f', -1, 64)
-	}
+	dataValue, _ := formatScalarValue(value)
 
 	w.Header().Add("Content-Type", contentType)
 	w.WriteHeader(http.StatusOK)
@@ -112,3 +112,16 @@ func sendSingleFormatResponse(w http.ResponseWriter, campaignDecisionResponse *d
 		logger.Errorf("error when writing data: %v", err)
 	}
 }
+
+// formatScalarValue returns the raw text of a string, boolean or number value. It returns false for the other values
+func formatScalarValue(value *structpb.Value) (string, bool) {
+	switch value.GetKind().(type) {
+	case (*structpb.Value_StringValue):
+		return value.GetStringValue(), true
+	case (*structpb.Value_BoolValue):
+		return strconv.FormatBool(value.GetBoolValue()), true
+	case (*structpb.Value_NumberValue):
+		return strconv.FormatFloat(value.GetNumberValue(), 'f', -1, 64), true
+	}
+	return "", false
+}
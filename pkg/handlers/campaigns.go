@@ -20,18 +20,28 @@ import (
 // Campaigns returns a campaigns handler
 // @Summary Get all campaigns for the visitor
 // @Tags Campaigns
-// @Description Get all campaigns value and metadata for a visitor ID and context
+// @Description Get all campaigns value and metadata for a visitor ID and context.
+// @Description GET requests read the visitor from the query, and are cacheable when they do not trigger hits.
+// @Description In simple mode, when the environment can not be loaded or is in panic mode, the configured fallback flags are returned
+// @Description as merged modifications with fallback set to true
 // @ID get-campaigns
 // @Accept  json
 // @Produce  json
-// @Param request body campaignsBodySwagger true "Campaigns request body"
+// @Param request body campaignsBodySwagger false "Campaigns request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Param visitor_id query string false "Visitor ID of GET requests"
+// @Param anonymous_id query string false "Anonymous ID of GET requests"
+// @Param context query string false "Visitor context JSON object of GET requests. Typed values can also be sent as context.key:type=value"
+// @Param trigger_hit query boolean false "Whether to trigger hits for GET requests"
+// @Param payload query string false "Base64url encoded JSON request body, for GET requests"
 // @Success 200 {object} campaignsResponse
 // @Failure 400 {object} errorMessage
 // @Failure 500 {object} errorMessage
 // @Router /campaigns [post]
+// @Router /campaigns [get]
 func Campaigns(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		apilogic.HandleCampaigns(w, req, context, requestCampaignsHandler, utils.NewTracker())
+		apilogic.HandleCampaignsWithFallback(w, req, context, requestCampaignsHandler, requestCampaignsFallbackHandler, utils.NewTracker())
 	}
 }
 
@@ -49,6 +59,17 @@ func requestCampaignsHandler(w http.ResponseWriter, handleRequest *handle.Reques
 		return
 	}
 
+	data, err := buildCampaignsResponse(handleRequest)
+	if err != nil {
+		utils.WriteServerError(w, fmt.Errorf("error when returning final response %v", err))
+		return
+	}
+
+	utils.WriteJSONStringOk(w, string(data))
+}
+
+// buildCampaignsResponse returns the JSON campaigns response of the decision, formatted for the request mode
+func buildCampaignsResponse(handleRequest *handle.Request) ([]byte, error) {
 	handleRequest.Logger.Infof("formatting campaign response for mode %s", handleRequest.Mode)
 	var response = decision_response.DecisionResponseFull{}
 	needAggregatedResponse := handleRequest.Mode == "simple" || handleRequest.Mode == "full"
@@ -69,10 +90,10 @@ func requestCampaignsHandler(w http.ResponseWriter, handleRequest *handle.Reques
 	ma.EmitUnpopulated = true
 	data, err := ma.Marshal(finalMessage)
 	if err != nil {
-		utils.WriteServerError(w, fmt.Errorf("error when returning final response %v", err))
+		return nil, err
 	}
 
-	utils.WriteJSONStringOk(w, getSanitizedResponse(string(data)))
+	return []byte(getSanitizedResponse(string(data))), nil
 }
 
 func aggregateFullResponse(response *decision_response.DecisionResponseFull) {
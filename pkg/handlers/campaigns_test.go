@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/context_providers"
 	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
 	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
 	"github.com/flagship-io/decision-api/pkg/models"
@@ -116,7 +117,7 @@ func TestCampaigns(t *testing.T) {
 	assert.Equal(t, 2, len(decisionResponseSimple.CampaignsVariation))
 
 	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		rows := []utils.UDCVisitorRow{
+		rows := []context_providers.UDCVisitorRow{
 			{
 				Segment: "age",
 				Partner: "mixpanel",
@@ -130,7 +131,7 @@ func TestCampaigns(t *testing.T) {
 	// Close the server when test finishes
 	defer server.Close()
 
-	utils.SetUDCUrl(server.URL)
+	decisionContext.ContextProvider = context_providers.NewUDCProvider(context_providers.UDCOptions{URL: server.URL})
 	// normal mode, send context events true, extras
 	url, _ = url.Parse("/campaigns?mode=normal")
 	w = httptest.NewRecorder()
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+)
+
+// CampaignsExplain returns a decision explanation handler
+// @Summary Explain the campaigns decision of the visitor
+// @Tags Campaigns
+// @Description Explain the decision of each campaign of the environment for a visitor ID and context: the targeting groups
+// @Description and conditions that matched or failed with the context values used, the bucket ranges and allocation checks, and whether the variation
+// @Description came from the assignments cache. The explanation does not trigger hits nor save assignments
+// @ID explain-campaigns
+// @Accept  json
+// @Produce  json
+// @Param request body campaignsBodySwagger false "Campaigns request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Param visitor_id query string false "Visitor ID of GET requests"
+// @Param context query string false "Visitor context JSON object of GET requests. Typed values can also be sent as context.key:type=value"
+// @Success 200 {object} apilogic.DecisionTrace
+// @Failure 400 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /campaigns/explain [post]
+// @Router /campaigns/explain [get]
+func CampaignsExplain(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		handleRequest, err := apilogic.BuildHandleRequest(req, context)
+		if err != nil {
+			utils.WriteRequestError(w, err)
+			return
+		}
+		// the campaign ID of the explain path is not a campaign filter
+		handleRequest.CampaignID = ""
+
+		trace, err := apilogic.ExplainCampaigns(handleRequest, context, true, utils.NewTracker())
+		if err != nil {
+			writeDecisionError(w, err)
+			return
+		}
+		utils.WriteJSONOk(w, trace)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCampaignsExplain(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+
+	query := url.Values{"visitor_id": {"1234"}, "context": {`{"age": 30}`}}
+	w := httptest.NewRecorder()
+	CampaignsExplain(context)(w, httptest.NewRequest(http.MethodGet, "/v2/campaigns/explain?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	trace := &apilogic.DecisionTrace{}
+	err := json.NewDecoder(w.Body).Decode(trace)
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", trace.VisitorID)
+	assert.Len(t, trace.Campaigns, 3)
+	assert.Equal(t, apilogic.TraceReasonAssigned, trace.Campaigns[0].Reason)
+
+	w = httptest.NewRecorder()
+	CampaignsExplain(context)(w, httptest.NewRequest(http.MethodGet, "/v2/campaigns/explain?context=%7B", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// the trace can also be added to the campaigns response extras
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns?extras=decisionTrace&sendContextEvent=false", strings.NewReader(`{"visitor_id": "1234", "context": {}, "trigger_hit": false}`))
+	Campaigns(context)(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"decisionTrace"`)
+	assert.Contains(t, w.Body.String(), `"targetingGroups"`)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+)
+
+// fallbackSimpleResponse is the simple campaigns response of the fallback flags
+type fallbackSimpleResponse struct {
+	CampaignsVariation  []interface{}          `json:"campaignsVariation"`
+	MergedModifications map[string]interface{} `json:"mergedModifications"`
+	Fallback            bool                   `json:"fallback"`
+}
+
+// requestFlagsFallbackHandler writes the fallback flags as flags without metadata
+func requestFlagsFallbackHandler(w http.ResponseWriter, handleRequest *handle.Request, flags map[string]interface{}) bool {
+	flagInfos := map[string]*FlagInfo{}
+	for key, value := range flags {
+		flagInfos[key] = &FlagInfo{
+			Value:    value,
+			Fallback: true,
+		}
+	}
+	writeFallbackResponse(w, flagInfos)
+	return true
+}
+
+// requestCampaignsFallbackHandler writes the fallback flags as merged modifications of the simple mode.
+// The other modes are not served with fallback flags
+func requestCampaignsFallbackHandler(w http.ResponseWriter, handleRequest *handle.Request, flags map[string]interface{}) bool {
+	if handleRequest.Mode != "simple" {
+		return false
+	}
+	writeFallbackResponse(w, &fallbackSimpleResponse{
+		CampaignsVariation:  []interface{}{},
+		MergedModifications: flags,
+		Fallback:            true,
+	})
+	return true
+}
+
+// writeFallbackResponse writes a fallback response, which must not be cached
+func writeFallbackResponse(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJSONOk(w, data)
+}
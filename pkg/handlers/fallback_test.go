@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallback(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	loader := context.EnvironmentLoader.(*environment_loaders.MockLoader)
+	loader.ErrorReturned = errors.New("cdn unreachable")
+	body := `{"visitor_id": "1234", "context": {"plan": "premium"}, "trigger_hit": false}`
+	call := func(handler func(http.ResponseWriter, *http.Request), url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, url, strings.NewReader(body)))
+		return w
+	}
+
+	// without fallback flags, the degraded responses are unchanged
+	assert.Equal(t, http.StatusInternalServerError, call(Flags(context), "/v2/flags").Code)
+
+	context.FallbackOptions = &models.FallbackOptions{Flags: []*models.FallbackFlag{
+		{Key: "btnColor", Value: "blue", Targeting: []*models.FallbackTargeting{{Key: "plan", Operator: "equals", Value: "free"}}},
+		{Key: "btnColor", Value: "red"},
+		{Key: "limit", Value: 10, Targeting: []*models.FallbackTargeting{{Key: "plan", Operator: "EQUALS", Value: "premium"}}},
+	}}
+
+	w := call(Flags(context), "/v2/flags")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	flags := map[string]FlagInfo{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&flags))
+	assert.Len(t, flags, 2)
+	assert.Equal(t, "red", flags["btnColor"].Value)
+	assert.EqualValues(t, 10, flags["limit"].Value)
+	assert.True(t, flags["limit"].Fallback)
+
+	w = call(Campaigns(context), "/v2/campaigns?mode=simple")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"campaignsVariation": [], "mergedModifications": {"btnColor": "red", "limit": 10}, "fallback": true}`, w.Body.String())
+
+	// only the simple mode of the campaigns is served with fallback flags
+	assert.Equal(t, http.StatusInternalServerError, call(Campaigns(context), "/v2/campaigns").Code)
+
+	// panic mode
+	loader.ErrorReturned = nil
+	loader.MockedEnvironment.Common.IsPanic = true
+	w = call(Flags(context), "/v2/flags")
+	assert.Equal(t, http.StatusOK, w.Code)
+	flags = map[string]FlagInfo{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&flags))
+	assert.True(t, flags["btnColor"].Fallback)
+
+	w = call(Campaigns(context), "/v2/campaigns")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"panic":true`)
+}
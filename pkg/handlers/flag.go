@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Flag value types of the type query parameter of the flag endpoint
+const (
+	FlagTypeString  = "string"
+	FlagTypeNumber  = "number"
+	FlagTypeBoolean = "boolean"
+	FlagTypeObject  = "object"
+	FlagTypeArray   = "array"
+)
+
+// Fallback reasons of the flag endpoint
+const (
+	FlagFallbackNotFound     = "flag_not_found"
+	FlagFallbackTypeMismatch = "type_mismatch"
+	FlagFallbackPanic        = "panic_mode"
+)
+
+// flagFallbackHeader holds the fallback reason of the text/plain flag responses
+const flagFallbackHeader = "X-Flag-Fallback"
+
+// FlagValue represents the value of a single flag, or the default value when the flag falls back
+type FlagValue struct {
+	Key            string        `json:"key"`
+	Value          interface{}   `json:"value"`
+	Metadata       *FlagMetadata `json:"metadata,omitempty"`
+	Fallback       bool          `json:"fallback"`
+	FallbackReason string        `json:"fallbackReason,omitempty"`
+	TypeMismatch   bool          `json:"typeMismatch"`
+}
+
+// Flag returns a single flag handler
+// @Summary Get a single flag
+// @Tags Flags
+// @Description Get the value and metadata of a flag for a visitor ID and context. The default value is returned with fallback true
+// @Description when the flag is not found, when the value does not have the expected type, or in panic mode.
+// @Description Send the Accept: text/plain header to get the raw value of scalar flags.
+// @Description The activate, batch and stream keys are reserved by the /flags routes of the same names
+// @ID get-flag
+// @Accept  json
+// @Produce  json,plain
+// @Param key path string true "Flag key"
+// @Param default query string false "Default value, parsed as the expected type or as JSON, a string otherwise"
+// @Param type query string false "Expected type of the flag value: string, number, boolean, object or array. Defaults to the type of the default value"
+// @Param request body campaignsBodySwagger false "Flag request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Param visitor_id query string false "Visitor ID of GET requests"
+// @Param context query string false "Visitor context JSON object of GET requests. Typed values can also be sent as context.key:type=value"
+// @Param trigger_hit query boolean false "Whether to trigger hits for GET requests"
+// @Success 200 {object} FlagValue
+// @Success 204
+// @Failure 400 {object} errorMessage
+// @Failure 406 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /flags/{key} [post]
+// @Router /flags/{key} [get]
+func Flag(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key := req.PathValue("key")
+		expectedType, defaultValue, err := getFlagDefault(req)
+		if err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		handleRequest, err := apilogic.BuildHandleRequest(req, context)
+		if err != nil {
+			utils.WriteRequestError(w, err)
+			return
+		}
+		// keys without value in the assigned variation, as in reference variations, are still provided by the campaign
+		handleRequest.ExposeAllKeys = true
+
+		err = apilogic.ComputeCampaigns(handleRequest, context, utils.NewTracker())
+		if err != nil {
+			writeDecisionError(w, err)
+			return
+		}
+
+		flag := &FlagValue{Key: key}
+		var value *structpb.Value
+		if handleRequest.Environment.Common.IsPanic {
+			flag.FallbackReason = FlagFallbackPanic
+		} else if info, ok := buildFlagInfos(handleRequest.DecisionResponse)[key]; !ok {
+			flag.FallbackReason = FlagFallbackNotFound
+		} else if value, _ = info.Value.(*structpb.Value); expectedType != "" && getFlagType(value) != expectedType {
+			flag.FallbackReason = FlagFallbackTypeMismatch
+			flag.TypeMismatch = true
+		} else {
+			flag.Metadata = &info.Metadata
+		}
+
+		if flag.FallbackReason != "" {
+			flag.Fallback = true
+			value = defaultValue
+		}
+		if value != nil {
+			flag.Value = value
+		}
+
+		if strings.Contains(req.Header.Get("Accept"), "text/plain") {
+			sendFlagText(w, flag, value, handleRequest.Logger)
+			return
+		}
+		utils.WriteJSONOk(w, flag)
+	}
+}
+
+// sendFlagText writes the raw value of a scalar flag
+func sendFlagText(w http.ResponseWriter, flag *FlagValue, value *structpb.Value, logger *logger.Logger) {
+	if flag.Fallback {
+		w.Header().Set(flagFallbackHeader, flag.FallbackReason)
+	}
+	if getFlagType(value) == "" {
+		utils.WriteNoContent(w)
+		return
+	}
+
+	data, ok := formatScalarValue(value)
+	if !ok {
+		utils.WriteClientError(w, http.StatusNotAcceptable, fmt.Sprintf("flag %s is not a scalar value", flag.Key))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(data)); err != nil {
+		logger.Errorf("error when writing data: %v", err)
+	}
+}
+
+// getFlagDefault returns the expected type and the default value of the flag request query
+func getFlagDefault(req *http.Request) (string, *structpb.Value, error) {
+	query := req.URL.Query()
+	expectedType := query.Get("type")
+	switch expectedType {
+	case "", FlagTypeString, FlagTypeNumber, FlagTypeBoolean, FlagTypeObject, FlagTypeArray:
+	default:
+		return "", nil, fmt.Errorf("unknown type %s, must be string, number, boolean, object or array", expectedType)
+	}
+
+	if !query.Has("default") {
+		return expectedType, nil, nil
+	}
+
+	rawDefault := query.Get("default")
+	var defaultValue interface{}
+	switch expectedType {
+	case FlagTypeString:
+		defaultValue = rawDefault
+	case FlagTypeNumber:
+		number, err := strconv.ParseFloat(rawDefault, 64)
+		if err != nil {
+			return "", nil, errors.New("default value must be a number")
+		}
+		defaultValue = number
+	case FlagTypeBoolean:
+		boolean, err := strconv.ParseBool(rawDefault)
+		if err != nil {
+			return "", nil, errors.New("default value must be a boolean")
+		}
+		defaultValue = boolean
+	default:
+		if err := json.Unmarshal([]byte(rawDefault), &defaultValue); err != nil {
+			if expectedType != "" {
+				return "", nil, fmt.Errorf("default value must be a JSON %s", expectedType)
+			}
+			defaultValue = rawDefault
+		}
+	}
+
+	value, err := structpb.NewValue(defaultValue)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid default value: %s", err.Error())
+	}
+	if expectedType == "" {
+		expectedType = getFlagType(value)
+	} else if getFlagType(value) != expectedType {
+		return "", nil, fmt.Errorf("default value must be a JSON %s", expectedType)
+	}
+	return expectedType, value, nil
+}
+
+// getFlagType returns the type of a flag value, or an empty string for null values
+func getFlagType(value *structpb.Value) string {
+	switch value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return FlagTypeString
+	case *structpb.Value_NumberValue:
+		return FlagTypeNumber
+	case *structpb.Value_BoolValue:
+		return FlagTypeBoolean
+	case *structpb.Value_StructValue:
+		return FlagTypeObject
+	case *structpb.Value_ListValue:
+		return FlagTypeArray
+	}
+	return ""
+}
+
+// writeDecisionError writes the error of a decision computation
+func writeDecisionError(w http.ResponseWriter, err error) {
+	var apiErr *apilogic.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Status >= http.StatusInternalServerError:
+		utils.WriteServerError(w, apiErr)
+	case errors.As(err, &apiErr):
+		utils.WriteClientError(w, apiErr.Status, apiErr.Message)
+	default:
+		utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+)
+
+// FlagActivationResult is the activation of a flag key, with the metadata of the activated campaign and variation
+type FlagActivationResult struct {
+	Key       string        `json:"key"`
+	Activated bool          `json:"activated"`
+	Reason    string        `json:"reason,omitempty"`
+	Metadata  *FlagMetadata `json:"metadata,omitempty"`
+}
+
+// FlagsActivationResponse is the response of the flags batch activation, with the results in the order of the keys
+type FlagsActivationResponse struct {
+	VisitorID string                  `json:"visitorId"`
+	Flags     []*FlagActivationResult `json:"flags"`
+}
+
+// FlagActivate returns a flag activation by key handler
+// @Summary Activate a flag
+// @Tags Flags
+// @Description Activate the campaign and variation providing a flag key to the visitor ID and context.
+// @Description The variation is resolved with the cached assignment of the visitor or a new decision
+// @ID activate-flag
+// @Accept  json
+// @Produce  json
+// @Param key path string true "Flag key"
+// @Param request body campaignsBodySwagger true "Flag activation request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Success 200 {object} FlagActivationResult
+// @Failure 400 {object} errorMessage
+// @Failure 405 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /flags/{key}/activate [post]
+func FlagActivate(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		response, ok := activateFlags(w, req, context, []string{req.PathValue("key")})
+		if ok {
+			utils.WriteJSONOk(w, response.Flags[0])
+		}
+	}
+}
+
+// FlagsActivate returns a flags batch activation handler
+// @Summary Activate several flags
+// @Tags Flags
+// @Description Activate the campaigns and variations providing the flag keys to the visitor ID and context.
+// @Description The variations are resolved with the cached assignments of the visitor or a new decision
+// @ID activate-flags
+// @Accept  json
+// @Produce  json
+// @Param key query []string true "Flag keys" collectionFormat(multi)
+// @Param request body campaignsBodySwagger true "Flags activation request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Success 200 {object} FlagsActivationResponse
+// @Failure 400 {object} errorMessage
+// @Failure 405 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Router /flags/activate [post]
+func FlagsActivate(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		keys := req.URL.Query()["key"]
+		if len(keys) == 0 {
+			utils.WriteClientError(w, http.StatusBadRequest, "at least one key query parameter is mandatory")
+			return
+		}
+
+		response, ok := activateFlags(w, req, context, keys)
+		if ok {
+			utils.WriteJSONOk(w, response)
+		}
+	}
+}
+
+// activateFlags activates the flag keys of the request. It writes the error response and returns false if the activation failed
+func activateFlags(w http.ResponseWriter, req *http.Request, context *connectors.DecisionContext, keys []string) (*FlagsActivationResponse, bool) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		utils.WriteClientError(w, http.StatusMethodNotAllowed, "only POST http method is allowed")
+		return nil, false
+	}
+
+	handleRequest, err := apilogic.BuildHandleRequest(req, context)
+	if err != nil {
+		utils.WriteRequestError(w, err)
+		return nil, false
+	}
+
+	flagActivations, err := apilogic.ActivateFlags(handleRequest, context, keys, utils.NewTracker())
+	var apiErr *apilogic.Error
+	if err != nil && flagActivations != nil && !errors.As(err, &apiErr) {
+		// the flags were resolved but the activations could not be tracked or saved
+		utils.WriteServerError(w, err)
+		return nil, false
+	}
+	if err != nil {
+		writeDecisionError(w, err)
+		return nil, false
+	}
+
+	response := &FlagsActivationResponse{
+		VisitorID: handleRequest.DecisionRequest.GetVisitorId().GetValue(),
+		Flags:     make([]*FlagActivationResult, len(flagActivations)),
+	}
+	results := response.Flags
+	for i, flagActivation := range flagActivations {
+		results[i] = &FlagActivationResult{Key: flagActivation.Key}
+		switch {
+		case handleRequest.Environment.Common.IsPanic:
+			results[i].Reason = FlagFallbackPanic
+		case flagActivation.Campaign == nil:
+			results[i].Reason = FlagFallbackNotFound
+		default:
+			metadata := newFlagMetadata(flagActivation.Campaign)
+			results[i].Activated = true
+			results[i].Metadata = &metadata
+		}
+	}
+	return response, true
+}
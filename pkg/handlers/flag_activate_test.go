@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagActivate(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	environment := context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.HasIntegrations = false
+	environment.Common.SingleAssignment = true
+	environment.Common.CacheEnabled = true
+	assignmentManager := assignments_managers.InitMemoryManager()
+	hitProcessor := &hits_processors.MockHitProcessor{}
+	context.EnvID = "env_id"
+	context.AssignmentsManager = assignmentManager
+	context.HitsProcessor = hitProcessor
+
+	body := `{"visitor_id": "visitor_id", "anonymous_id": "anonymous_id", "context": {}}`
+	req := httptest.NewRequest(http.MethodPost, "/v2/flags/testString/activate?sendContextEvent=false", strings.NewReader(body))
+	req.SetPathValue("key", "testString")
+	w := httptest.NewRecorder()
+	FlagActivate(context)(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	result := &FlagActivationResult{}
+	err := json.NewDecoder(w.Body).Decode(result)
+	assert.Nil(t, err)
+	assert.True(t, result.Activated)
+	assert.Equal(t, "campaign_1", result.Metadata.CampaignID)
+
+	// the resolved variation is activated as with the activate endpoint
+	assert.Len(t, hitProcessor.TrackedHits.CampaignActivations, 1)
+	activation := hitProcessor.TrackedHits.CampaignActivations[0]
+	assert.Equal(t, "env_id", activation.EnvID)
+	assert.Equal(t, result.Metadata.VariationGroupID, activation.CampaignID)
+	assert.Equal(t, result.Metadata.VariationID, activation.VariationID)
+	assert.Equal(t, "visitor_id", activation.CustomerID)
+	assert.Equal(t, "anonymous_id", activation.VisitorID)
+
+	cacheVisitor, err := assignmentManager.LoadAssignments("env_id", "visitor_id")
+	assert.Nil(t, err)
+	assert.Equal(t, result.Metadata.VariationID, cacheVisitor.Assignments[result.Metadata.VariationGroupID].VariationID)
+	assert.True(t, cacheVisitor.Assignments[result.Metadata.VariationGroupID].Activated)
+
+	// batch activation, keys of the same campaign are activated once
+	hitProcessor.TrackedHits.CampaignActivations = nil
+	req = httptest.NewRequest(http.MethodPost, "/v2/flags/activate?sendContextEvent=false&key=testString&key=testBool&key=unknown", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	FlagsActivate(context)(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := &FlagsActivationResponse{}
+	err = json.NewDecoder(w.Body).Decode(response)
+	assert.Nil(t, err)
+	assert.Equal(t, "visitor_id", response.VisitorID)
+	assert.Len(t, response.Flags, 3)
+	assert.True(t, response.Flags[0].Activated)
+	assert.True(t, response.Flags[1].Activated)
+	assert.False(t, response.Flags[2].Activated)
+	assert.Equal(t, FlagFallbackNotFound, response.Flags[2].Reason)
+	assert.Len(t, hitProcessor.TrackedHits.CampaignActivations, 1)
+
+	// errors
+	w = httptest.NewRecorder()
+	FlagsActivate(context)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/activate", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	FlagActivate(context)(w, httptest.NewRequest(http.MethodGet, "/v2/flags/testString/activate?visitor_id=visitor_id", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	FlagActivate(context)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/testString/activate", strings.NewReader(`{"visitor_id": 1}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// the reference variation without modifications is activated, whatever the exposeAllKeys option
+	hitProcessor.TrackedHits.CampaignActivations = nil
+	environment.Common.SingleAssignment = false
+	environment.Common.Campaigns = append(environment.Common.Campaigns, utils.CreateReferenceCampaignMock("campaign_ref", "vg_ref", "btn"))
+	req = httptest.NewRequest(http.MethodPost, "/v2/flags/btn/activate?sendContextEvent=false&exposeAllKeys=false", strings.NewReader(body))
+	req.SetPathValue("key", "btn")
+	w = httptest.NewRecorder()
+	FlagActivate(context)(w, req)
+	result = &FlagActivationResult{}
+	err = json.NewDecoder(w.Body).Decode(result)
+	assert.Nil(t, err)
+	assert.True(t, result.Activated)
+	assert.True(t, result.Metadata.Reference)
+	assert.Len(t, hitProcessor.TrackedHits.CampaignActivations, 1)
+	assert.Equal(t, "v_ref", hitProcessor.TrackedHits.CampaignActivations[0].VariationID)
+
+	// no activation in panic mode
+	hitProcessor.TrackedHits.CampaignActivations = nil
+	environment.Common.IsPanic = true
+	req = httptest.NewRequest(http.MethodPost, "/v2/flags/testString/activate", strings.NewReader(body))
+	req.SetPathValue("key", "testString")
+	w = httptest.NewRecorder()
+	FlagActivate(context)(w, req)
+	result = &FlagActivationResult{}
+	err = json.NewDecoder(w.Body).Decode(result)
+	assert.Nil(t, err)
+	assert.False(t, result.Activated)
+	assert.Equal(t, FlagFallbackPanic, result.Reason)
+	assert.Len(t, hitProcessor.TrackedHits.CampaignActivations, 0)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlag(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+
+	call := func(key string, query url.Values, accept string) *httptest.ResponseRecorder {
+		query.Set("visitor_id", "1234")
+		query.Set("trigger_hit", "false")
+		query.Set("sendContextEvent", "false")
+		req := httptest.NewRequest(http.MethodGet, "/v2/flags/"+key+"?"+query.Encode(), nil)
+		req.SetPathValue("key", key)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		Flag(context)(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		data := map[string]interface{}{}
+		err := json.NewDecoder(w.Body).Decode(&data)
+		assert.Nil(t, err)
+		return data
+	}
+
+	w := call("testString", url.Values{"default": {"default"}}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	data := decode(w)
+	assert.Equal(t, "string", data["value"])
+	assert.Equal(t, false, data["fallback"])
+	assert.Equal(t, "campaign_1", data["metadata"].(map[string]interface{})["campaignId"])
+
+	// the flag falls back to the default value when not found
+	w = call("unknown", url.Values{"default": {"12"}}, "")
+	data = decode(w)
+	assert.EqualValues(t, 12, data["value"])
+	assert.Equal(t, true, data["fallback"])
+	assert.Equal(t, FlagFallbackNotFound, data["fallbackReason"])
+	assert.Nil(t, data["metadata"])
+
+	// or when the type mismatches
+	w = call("testNumber", url.Values{"default": {"true"}}, "")
+	data = decode(w)
+	assert.Equal(t, true, data["value"])
+	assert.Equal(t, true, data["typeMismatch"])
+	assert.Equal(t, FlagFallbackTypeMismatch, data["fallbackReason"])
+
+	w = call("testWhatever", url.Values{"type": {FlagTypeArray}}, "")
+	data = decode(w)
+	assert.Equal(t, []interface{}{"a", 1.}, data["value"])
+	assert.Equal(t, false, data["typeMismatch"])
+
+	// raw scalar values
+	w = call("testNumber", url.Values{}, "text/plain")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "11", w.Body.String())
+
+	w = call("unknown", url.Values{"default": {"fallback"}, "type": {FlagTypeString}}, "text/plain")
+	assert.Equal(t, "fallback", w.Body.String())
+	assert.Equal(t, FlagFallbackNotFound, w.Header().Get(flagFallbackHeader))
+
+	assert.Equal(t, http.StatusNoContent, call("unknown", url.Values{}, "text/plain").Code)
+	assert.Equal(t, http.StatusNotAcceptable, call("testWhatever", url.Values{}, "text/plain").Code)
+
+	// invalid defaults
+	assert.Equal(t, http.StatusBadRequest, call("testNumber", url.Values{"type": {"date"}}, "").Code)
+	assert.Equal(t, http.StatusBadRequest, call("testNumber", url.Values{"type": {FlagTypeNumber}, "default": {"abc"}}, "").Code)
+	assert.Equal(t, http.StatusBadRequest, call("testNumber", url.Values{"type": {FlagTypeObject}, "default": {"[]"}}, "").Code)
+
+	// POST requests
+	req := httptest.NewRequest(http.MethodPost, "/v2/flags/testBool?sendContextEvent=false", strings.NewReader(`{"visitor_id": "1234", "context": {}, "trigger_hit": false}`))
+	req.SetPathValue("key", "testBool")
+	w = httptest.NewRecorder()
+	Flag(context)(w, req)
+	assert.Equal(t, true, decode(w)["value"])
+
+	// the flag of a reference variation without modifications is provided by its campaign, whatever the exposeAllKeys option
+	environment := context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment
+	environment.Common.Campaigns = append(environment.Common.Campaigns, utils.CreateReferenceCampaignMock("campaign_ref", "vg_ref", "btn"))
+	data = decode(call("btn", url.Values{"exposeAllKeys": {"false"}}, ""))
+	assert.Equal(t, false, data["fallback"])
+	assert.Nil(t, data["value"])
+	assert.Equal(t, "v_ref", data["metadata"].(map[string]interface{})["variationId"])
+	assert.Equal(t, true, data["metadata"].(map[string]interface{})["reference"])
+
+	// panic mode
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.Common.IsPanic = true
+	data = decode(call("testString", url.Values{"default": {"default"}}, ""))
+	assert.Equal(t, "default", data["value"])
+	assert.Equal(t, FlagFallbackPanic, data["fallbackReason"])
+}
@@ -27,23 +27,34 @@ type FlagMetadata struct {
 type FlagInfo struct {
 	Value    interface{}  `json:"value"`
 	Metadata FlagMetadata `json:"metadata"`
+	// Fallback is true if the value is a configured fallback value, served when no environment is available
+	Fallback bool `json:"fallback,omitempty"`
 }
 
 // Flags returns a flags handler
 // @Summary Get all flags
 // @Tags Flags
-// @Description Get all flags value and metadata for a visitor ID and context
+// @Description Get all flags value and metadata for a visitor ID and context.
+// @Description GET requests read the visitor from the query, and are cacheable when they do not trigger hits.
+// @Description When the environment can not be loaded or is in panic mode, the configured fallback flags are returned with fallback set to true
 // @ID get-flags
 // @Accept  json
 // @Produce  json
-// @Param request body campaignsBodySwagger true "Flag request body"
+// @Param request body campaignsBodySwagger false "Flag request body"
+// @Param X-Forced-Variations header string false "Signed forced variations token of QA testers"
+// @Param visitor_id query string false "Visitor ID of GET requests"
+// @Param anonymous_id query string false "Anonymous ID of GET requests"
+// @Param context query string false "Visitor context JSON object of GET requests. Typed values can also be sent as context.key:type=value"
+// @Param trigger_hit query boolean false "Whether to trigger hits for GET requests"
+// @Param payload query string false "Base64url encoded JSON request body, for GET requests"
 // @Success 200 {object} map[string]FlagInfo{}
 // @Failure 400 {object} errorMessage
 // @Failure 500 {object} errorMessage
 // @Router /flags [post]
+// @Router /flags [get]
 func Flags(context *connectors.DecisionContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		apilogic.HandleCampaigns(w, req, context, requestFlagsHandler, utils.NewTracker())
+		apilogic.HandleCampaignsWithFallback(w, req, context, requestFlagsHandler, requestFlagsFallbackHandler, utils.NewTracker())
 	}
 }
 
@@ -57,31 +68,41 @@ func requestFlagsHandler(w http.ResponseWriter, handleRequest *handle.Request, e
 }
 
 func sendFlagsResponse(w http.ResponseWriter, decisionResponse *decision_response.DecisionResponse) {
+	utils.WriteJSONOk(w, buildFlagInfos(decisionResponse))
+}
+
+// buildFlagInfos returns the flags values and metadata of the decision campaigns
+func buildFlagInfos(decisionResponse *decision_response.DecisionResponse) map[string]*FlagInfo {
 	flagInfos := make(map[string]*FlagInfo)
 
 	for _, c := range decisionResponse.Campaigns {
 		if c.GetVariation() != nil && c.GetVariation().GetModifications() != nil && c.GetVariation().GetModifications().GetValue() != nil && c.GetVariation().GetModifications().GetValue().GetFields() != nil {
 			for k, v := range c.GetVariation().GetModifications().GetValue().GetFields() {
-				mdata := FlagMetadata{
-					CampaignID:         c.GetId().Value,
-					CampaignName:       c.GetName().GetValue(),
-					Type:               c.GetType().Value,
-					VariationGroupID:   c.GetVariationGroupId().Value,
-					VariationGroupName: c.GetVariationGroupName().GetValue(),
-					VariationID:        c.GetVariation().GetId().Value,
-					VariationName:      c.GetVariation().GetName().GetValue(),
-					Reference:          c.GetVariation().GetReference(),
-				}
-				if c.GetSlug() != nil {
-					mdata.Slug = &c.GetSlug().Value
-				}
 				flagInfos[k] = &FlagInfo{
 					Value:    v,
-					Metadata: mdata,
+					Metadata: newFlagMetadata(c),
 				}
 			}
 		}
 	}
 
-	utils.WriteJSONOk(w, flagInfos)
+	return flagInfos
+}
+
+// newFlagMetadata returns the metadata of the flags of a campaign decision
+func newFlagMetadata(c *decision_response.Campaign) FlagMetadata {
+	mdata := FlagMetadata{
+		CampaignID:         c.GetId().GetValue(),
+		CampaignName:       c.GetName().GetValue(),
+		Type:               c.GetType().GetValue(),
+		VariationGroupID:   c.GetVariationGroupId().GetValue(),
+		VariationGroupName: c.GetVariationGroupName().GetValue(),
+		VariationID:        c.GetVariation().GetId().GetValue(),
+		VariationName:      c.GetVariation().GetName().GetValue(),
+		Reference:          c.GetVariation().GetReference(),
+	}
+	if c.GetSlug() != nil {
+		mdata.Slug = &c.GetSlug().Value
+	}
+	return mdata
 }
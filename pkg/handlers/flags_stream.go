@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/apilogic"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/internal/validation"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/flagship-proto/decision_request"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// flagsStreamRetry is the reconnection delay advertised to the stream clients
+const flagsStreamRetry = 3 * time.Second
+
+// FlagsStreams tracks the flags streams of a server, to cap their number and end them when the server shuts down
+type FlagsStreams struct {
+	options     *models.FlagsStreamOptions
+	subscribers atomic.Int64
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewFlagsStreams creates the flags streams tracker. A MaxSubscribers option lower than 1 does not cap the streams
+func NewFlagsStreams(options *models.FlagsStreamOptions) *FlagsStreams {
+	return &FlagsStreams{
+		options: options,
+		done:    make(chan struct{}),
+	}
+}
+
+// Subscribers returns the number of open streams
+func (s *FlagsStreams) Subscribers() int64 {
+	return s.subscribers.Load()
+}
+
+// Close ends the open streams and rejects the new ones
+func (s *FlagsStreams) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *FlagsStreams) acquire() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	count := s.subscribers.Add(1)
+	if s.options.MaxSubscribers > 0 && count > int64(s.options.MaxSubscribers) {
+		s.subscribers.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (s *FlagsStreams) release() {
+	s.subscribers.Add(-1)
+}
+
+// flagsEvent is a flags map pushed to a stream, identified by the hash of its content
+type flagsEvent struct {
+	id   string
+	data []byte
+}
+
+// FlagsStream returns a flags server-sent events stream handler
+// @Summary Stream flags
+// @Tags Flags
+// @Description Stream the flags value and metadata of a visitor ID and context. The flags are sent again each time they change
+// @Description because of a new environment. Clients reconnecting with the Last-Event-ID header only get the flags if they changed
+// @ID stream-flags
+// @Accept  json
+// @Produce  text/event-stream
+// @Param request body campaignsBodySwagger true "Flag request body"
+// @Success 200 {object} map[string]FlagInfo{}
+// @Failure 400 {object} errorMessage
+// @Failure 500 {object} errorMessage
+// @Failure 501 {object} errorMessage
+// @Failure 503 {object} errorMessage
+// @Router /flags/stream [get]
+// @Router /flags/stream [post]
+func FlagsStream(context *connectors.DecisionContext, streams *FlagsStreams) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			utils.WriteClientError(w, http.StatusMethodNotAllowed, "only GET and POST http methods are allowed")
+			return
+		}
+
+		decisionRequest, forcedVariationsToken, err := utils.GetDecisionRequestAndForcedVariations(req)
+		if err != nil {
+			utils.WriteClientError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		notifier, ok := context.EnvironmentLoader.(connectors.EnvironmentNotifier)
+		if !ok {
+			utils.WriteClientError(w, http.StatusNotImplemented, "the environment loader does not notify environment changes")
+			return
+		}
+
+		if !streams.acquire() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(flagsStreamRetry.Seconds())))
+			utils.WriteClientErrorCode(w, http.StatusServiceUnavailable, "too_many_subscribers", "the maximum number of flags streams is reached")
+			return
+		}
+		defer streams.release()
+
+		// subscribe before the first decision to not miss an environment change
+		updates, unsubscribe := notifier.SubscribeEnvironment(context.EnvID)
+		defer unsubscribe()
+
+		event, err := computeFlagsEvent(req, context, decisionRequest, forcedVariationsToken, true)
+		var validationErr *validation.ErrorResponse
+		if errors.As(err, &validationErr) {
+			writeFlagsStreamError(w, validationErr)
+			return
+		}
+		if err != nil {
+			writeDecisionError(w, err)
+			return
+		}
+		// only the first decision of the stream triggers the activation hits
+		decisionRequest.TriggerHit = wrapperspb.Bool(false)
+
+		// streams outlive the write timeout of the server
+		controller := http.NewResponseController(w)
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		_, err = fmt.Fprintf(w, "retry: %d\n\n", flagsStreamRetry.Milliseconds())
+		if err == nil && req.Header.Get("Last-Event-ID") != event.id {
+			err = writeFlagsEvent(w, event)
+		}
+		if err == nil {
+			err = controller.Flush()
+		}
+
+		heartbeat := time.NewTicker(streams.options.HeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for err == nil {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-streams.done:
+				return
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			case <-updates:
+				newEvent, computeErr := computeFlagsEvent(req, context, decisionRequest, forcedVariationsToken, false)
+				if computeErr != nil {
+					context.Logger.Warnf("error when computing streamed flags: %v", computeErr)
+					continue
+				}
+				if newEvent.id == event.id {
+					continue
+				}
+				event = newEvent
+				err = writeFlagsEvent(w, event)
+			}
+			if err == nil {
+				err = controller.Flush()
+			}
+		}
+		context.Logger.Infof("flags stream ended: %v", err)
+	}
+}
+
+// computeFlagsEvent runs the decision of the stream visitor with the current environment.
+// The visitor context is only checked against the context schema and sent to the hits processor for the first decision
+// of the stream, as the coerced context replaces the context of the decision request. It is enriched for every decision
+func computeFlagsEvent(req *http.Request, context *connectors.DecisionContext, decisionRequest *decision_request.DecisionRequest, forcedVariationsToken string, first bool) (*flagsEvent, error) {
+	handleRequest := apilogic.NewHandleRequestFromHTTP(req, decisionRequest)
+	handleRequest.SendContextEvent = handleRequest.SendContextEvent && first
+	metadata := apilogic.NewRequestMetadataFromHTTP(req)
+	if first {
+		if err := apilogic.PrepareHandleRequest(handleRequest, forcedVariationsToken, metadata, context); err != nil {
+			return nil, err
+		}
+	} else {
+		handleRequest.ForcedVariationsToken = forcedVariationsToken
+		apilogic.EnrichContext(metadata, handleRequest, context.ContextEnrichmentOptions)
+	}
+
+	err := apilogic.ComputeCampaigns(handleRequest, context, utils.NewTracker())
+	if err != nil {
+		return nil, err
+	}
+
+	flagInfos := map[string]*FlagInfo{}
+	if !handleRequest.Environment.Common.IsPanic {
+		flagInfos = buildFlagInfos(handleRequest.DecisionResponse)
+	}
+
+	data, err := json.Marshal(flagInfos)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return &flagsEvent{id: hex.EncodeToString(hash[:8]), data: data}, nil
+}
+
+// writeFlagsStreamError opens the stream with an error event holding the context schema violations, then ends it
+func writeFlagsStreamError(w http.ResponseWriter, validationErr *validation.ErrorResponse) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", validationErr.Error())
+}
+
+func writeFlagsEvent(w http.ResponseWriter, event *flagsEvent) error {
+	_, err := fmt.Fprintf(w, "id: %s\nevent: flags\ndata: %s\n\n", event.id, event.data)
+	return err
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/stretchr/testify/assert"
+)
+
+type notifyingLoader struct {
+	environment_loaders.MockLoader
+	lock    sync.Mutex
+	updates chan struct{}
+}
+
+func (l *notifyingLoader) LoadEnvironment(envID string, APIKey string) (*models.Environment, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	environment := *l.MockedEnvironment
+	commonEnv := *l.MockedEnvironment.Common
+	environment.Common = &commonEnv
+	return &environment, nil
+}
+
+func (l *notifyingLoader) SubscribeEnvironment(envID string) (<-chan struct{}, func()) {
+	return l.updates, func() {}
+}
+
+func (l *notifyingLoader) swap(campaigns []*common.Campaign) {
+	l.lock.Lock()
+	l.MockedEnvironment.Common.Campaigns = campaigns
+	l.lock.Unlock()
+	l.updates <- struct{}{}
+}
+
+// readStreamEvent returns the next event or comment of the stream
+func readStreamEvent(t *testing.T, reader *bufio.Reader) string {
+	lines := []string{}
+	for {
+		line, err := reader.ReadString('\n')
+		assert.Nil(t, err)
+		if line == "\n" || err != nil {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestFlagsStream(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	loader := &notifyingLoader{
+		MockLoader: *context.EnvironmentLoader.(*environment_loaders.MockLoader),
+		updates:    make(chan struct{}),
+	}
+	context.EnvironmentLoader = loader
+	streams := NewFlagsStreams(&models.FlagsStreamOptions{MaxSubscribers: 1, HeartbeatInterval: 50 * time.Millisecond})
+
+	server := httptest.NewServer(http.HandlerFunc(FlagsStream(context, streams)))
+	defer server.Close()
+
+	query := url.Values{"visitor_id": {"1234"}, "context": {"{}"}, "sendContextEvent": {"false"}}
+	resp, err := http.Get(server.URL + "/v2/flags/stream?" + query.Encode())
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, "retry: 3000\n", readStreamEvent(t, reader))
+	event := readStreamEvent(t, reader)
+	assert.Contains(t, event, "event: flags\n")
+	assert.Contains(t, event, `"testString":{"value":"string"`)
+	eventID := strings.TrimPrefix(strings.Split(event, "\n")[0], "id: ")
+
+	// subscribers are capped
+	capped, err := http.Get(server.URL + "/v2/flags/stream?" + query.Encode())
+	assert.Nil(t, err)
+	capped.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, capped.StatusCode)
+	assert.Equal(t, "3", capped.Header.Get("Retry-After"))
+	assert.EqualValues(t, 1, streams.Subscribers())
+
+	// unchanged flags are not pushed again, and the recomputed decisions do not trigger activation hits
+	hitsProcessor := context.HitsProcessor.(*hits_processors.MockHitProcessor)
+	assert.NotEmpty(t, hitsProcessor.TrackedHits.CampaignActivations)
+	hitsProcessor.TrackedHits.CampaignActivations = nil
+	loader.swap(loader.MockedEnvironment.Common.Campaigns)
+	assert.Equal(t, ": heartbeat\n", readStreamEvent(t, reader))
+	assert.Empty(t, hitsProcessor.TrackedHits.CampaignActivations)
+
+	loader.swap([]*common.Campaign{})
+	event = readStreamEvent(t, reader)
+	for event == ": heartbeat\n" {
+		event = readStreamEvent(t, reader)
+	}
+	assert.Contains(t, event, "data: {}\n")
+	assert.NotContains(t, event, eventID)
+	newEventID := strings.TrimPrefix(strings.Split(event, "\n")[0], "id: ")
+	resp.Body.Close()
+	assert.Eventually(t, func() bool { return streams.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+
+	// reconnection with the last event ID does not push the same flags
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v2/flags/stream?"+query.Encode(), nil)
+	req.Header.Set("Last-Event-ID", newEventID)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	reader = bufio.NewReader(resp.Body)
+	assert.Equal(t, "retry: 3000\n", readStreamEvent(t, reader))
+	assert.Equal(t, ": heartbeat\n", readStreamEvent(t, reader))
+
+	// closing the streams ends them
+	streams.Close()
+	_, err = reader.ReadString('\n')
+	assert.NotNil(t, err)
+
+	closed, err := http.Get(server.URL + "/v2/flags/stream?" + query.Encode())
+	assert.Nil(t, err)
+	closed.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, closed.StatusCode)
+}
+
+func TestFlagsStreamErrors(t *testing.T) {
+	streams := NewFlagsStreams(&models.FlagsStreamOptions{HeartbeatInterval: time.Second})
+
+	w := httptest.NewRecorder()
+	FlagsStream(utils.CreateMockDecisionContext(), streams)(w, httptest.NewRequest(http.MethodPut, "/v2/flags/stream", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	FlagsStream(utils.CreateMockDecisionContext(), streams)(w, httptest.NewRequest(http.MethodGet, "/v2/flags/stream?context=%7B", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	FlagsStream(utils.CreateMockDecisionContext(), streams)(w, httptest.NewRequest(http.MethodPost, "/v2/flags/stream", strings.NewReader(`{"visitor_id": "1234"}`)))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestFlagsStreamContextSchema(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	context.EnvironmentLoader = &notifyingLoader{
+		MockLoader: *context.EnvironmentLoader.(*environment_loaders.MockLoader),
+		updates:    make(chan struct{}),
+	}
+	validator, err := contextschema.NewValidator(&models.ContextSchemaOptions{
+		Mode:   models.ContextSchemaModeReject,
+		Fields: []*models.ContextField{{Key: "age", Type: "number"}},
+	})
+	assert.Nil(t, err)
+	context.ContextSchema = validator
+	streams := NewFlagsStreams(&models.FlagsStreamOptions{HeartbeatInterval: time.Second})
+
+	w := httptest.NewRecorder()
+	query := url.Values{"visitor_id": {"1234"}, "context": {`{"age": "30"}`}}
+	FlagsStream(context, streams)(w, httptest.NewRequest(http.MethodGet, "/v2/flags/stream?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "event: error\ndata: {\"status\":\"error\",\"errors\":{\"context.age\":\"Expected a number.\"}}\n\n", w.Body.String())
+	assert.Equal(t, int64(0), streams.Subscribers())
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -56,3 +57,28 @@ func TestFlags(t *testing.T) {
 	assert.NotNil(t, data)
 	assert.Len(t, data, 0)
 }
+
+func TestFlagsGet(t *testing.T) {
+	context := utils.CreateMockDecisionContext()
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).MockedEnvironment.HasIntegrations = false
+
+	query := url.Values{"visitor_id": {"1234"}, "context": {"{}"}, "trigger_hit": {"false"}, "sendContextEvent": {"false"}}
+	w := httptest.NewRecorder()
+	Flags(context)(w, httptest.NewRequest(http.MethodGet, "/v2/flags?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	data := map[string]FlagInfo{}
+	err := json.NewDecoder(w.Body).Decode(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, "string", data["testString"].Value)
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"visitor_id": "1234", "context": {}, "trigger_hit": false}`))
+	w = httptest.NewRecorder()
+	Flags(context)(w, httptest.NewRequest(http.MethodGet, "/v2/flags?sendContextEvent=false&payload="+payload, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"testString"`)
+
+	w = httptest.NewRecorder()
+	Flags(context)(w, httptest.NewRequest(http.MethodGet, "/v2/flags?context=%7B", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusKO       = "ko"
+	healthStatusDegraded = "degraded"
+)
+
+// HealthStatus represents the health status of a component
+type HealthStatus struct {
+	Status     string     `json:"status"`
+	Message    string     `json:"message,omitempty"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	Age        string     `json:"age,omitempty"`
+}
+
+// EnvironmentLoaderHealth represents the health status of the environment loader for each environment
+type EnvironmentLoaderHealth struct {
+	Status       string                   `json:"status"`
+	Environments map[string]*HealthStatus `json:"environments"`
+}
+
+// ConnectorsHealth represents the health status of the connectors
+type ConnectorsHealth struct {
+	EnvironmentLoader  *EnvironmentLoaderHealth `json:"environment_loader"`
+	AssignmentsManager *HealthStatus            `json:"assignments_manager"`
+}
+
+// HealthResponse represents the readiness response
+type HealthResponse struct {
+	Status     string            `json:"status"`
+	Connectors *ConnectorsHealth `json:"connectors,omitempty"`
+}
+
+// HealthLive returns a liveness handler
+// @Summary Liveness check
+// @Tags Health
+// @Description Returns 200 as long as the server is running
+// @ID health-live
+// @Produce  json
+// @Success 200 {object} HealthResponse
+// @Router /health/live [get]
+func HealthLive() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeHealthResponse(w, &HealthResponse{Status: healthStatusOK})
+	}
+}
+
+// HealthReady returns a readiness handler
+// @Summary Readiness check
+// @Tags Health
+// @Description Returns 200 if the environments are loaded and up to date and the assignments manager backend is reachable.
+// @Description The status of each environment is reported. When some environments are not ready, the environment loader status
+// @Description is degraded and the server stays ready, unless all environments are required to be ready
+// @ID health-ready
+// @Produce  json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /health/ready [get]
+func HealthReady(decisionContexts []*connectors.DecisionContext, options *models.HealthOptions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if options.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+			defer cancel()
+		}
+
+		response := &HealthResponse{
+			Status: healthStatusOK,
+			Connectors: &ConnectorsHealth{
+				EnvironmentLoader: &EnvironmentLoaderHealth{
+					Status:       healthStatusOK,
+					Environments: map[string]*HealthStatus{},
+				},
+				AssignmentsManager: &HealthStatus{Status: healthStatusOK},
+			},
+		}
+
+		// in multi environments mode, a broken environment only takes the pod out of the load balancer if all are broken
+		notReady := 0
+		for _, dc := range decisionContexts {
+			envHealth := checkEnvironment(dc, options.StalenessThreshold)
+			response.Connectors.EnvironmentLoader.Environments[dc.EnvID] = envHealth
+			if envHealth.Status != healthStatusOK {
+				notReady++
+			}
+		}
+		switch {
+		case notReady == 0:
+		case notReady == len(decisionContexts) || options.RequireAllEnvs:
+			response.Connectors.EnvironmentLoader.Status = healthStatusKO
+			response.Status = healthStatusKO
+		default:
+			response.Connectors.EnvironmentLoader.Status = healthStatusDegraded
+		}
+
+		if len(decisionContexts) > 0 {
+			if checker, ok := decisionContexts[0].AssignmentsManager.(connectors.HealthChecker); ok {
+				if err := checker.HealthCheck(ctx); err != nil {
+					response.Connectors.AssignmentsManager = &HealthStatus{Status: healthStatusKO, Message: err.Error()}
+					response.Status = healthStatusKO
+				}
+			}
+		}
+
+		writeHealthResponse(w, response)
+	}
+}
+
+func checkEnvironment(dc *connectors.DecisionContext, stalenessThreshold time.Duration) *HealthStatus {
+	statusProvider, ok := dc.EnvironmentLoader.(connectors.EnvironmentStatusProvider)
+	if !ok {
+		// fallback to loading the environment for loaders that do not report their status
+		if _, err := dc.EnvironmentLoader.LoadEnvironment(dc.EnvID, dc.APIKey); err != nil {
+			return &HealthStatus{Status: healthStatusKO, Message: err.Error()}
+		}
+		return &HealthStatus{Status: healthStatusOK}
+	}
+
+	status := statusProvider.EnvironmentStatus(dc.EnvID)
+	if !status.Loaded {
+		return &HealthStatus{Status: healthStatusKO, Message: "environment not loaded"}
+	}
+
+	age := time.Since(status.LastUpdate)
+	health := &HealthStatus{
+		Status:     healthStatusOK,
+		LastUpdate: &status.LastUpdate,
+		Age:        age.Round(time.Second).String(),
+	}
+	if stalenessThreshold > 0 && age > stalenessThreshold {
+		health.Status = healthStatusKO
+		health.Message = "environment is stale"
+	}
+	return health
+}
+
+func writeHealthResponse(w http.ResponseWriter, response *HealthResponse) {
+	status := http.StatusOK
+	if response.Status != healthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusLoader struct {
+	environment_loaders.MockLoader
+	status connectors.EnvironmentStatus
+}
+
+func (l *statusLoader) EnvironmentStatus(envID string) connectors.EnvironmentStatus {
+	return l.status
+}
+
+type unhealthyManager struct {
+	assignments_managers.EmptyManager
+}
+
+func (m *unhealthyManager) HealthCheck(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func getHealth(t *testing.T, handler func(http.ResponseWriter, *http.Request)) (int, *HealthResponse) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v2/health/ready", nil))
+
+	resp := w.Result()
+	data := &HealthResponse{}
+	err := json.NewDecoder(resp.Body).Decode(data)
+	assert.Nil(t, err)
+	return resp.StatusCode, data
+}
+
+func TestHealthLive(t *testing.T) {
+	status, data := getHealth(t, HealthLive())
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", data.Status)
+}
+
+func TestHealthReady(t *testing.T) {
+	options := &models.HealthOptions{StalenessThreshold: time.Minute, Timeout: time.Second}
+	context := utils.CreateMockDecisionContext()
+
+	status, data := getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", data.Status)
+	assert.Equal(t, "ok", data.Connectors.EnvironmentLoader.Environments["env_id_1"].Status)
+	assert.Equal(t, "ok", data.Connectors.AssignmentsManager.Status)
+
+	// Loader error
+	context.EnvironmentLoader.(*environment_loaders.MockLoader).ErrorReturned = errors.New("cdn error")
+	status, data = getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "ko", data.Status)
+	assert.Equal(t, "ko", data.Connectors.EnvironmentLoader.Status)
+	assert.Equal(t, "cdn error", data.Connectors.EnvironmentLoader.Environments["env_id_1"].Message)
+
+	// Environment status
+	loader := &statusLoader{status: connectors.EnvironmentStatus{Loaded: true, LastUpdate: time.Now()}}
+	context.EnvironmentLoader = loader
+	status, data = getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotNil(t, data.Connectors.EnvironmentLoader.Environments["env_id_1"].LastUpdate)
+
+	loader.status.LastUpdate = time.Now().Add(-2 * time.Minute)
+	status, data = getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "environment is stale", data.Connectors.EnvironmentLoader.Environments["env_id_1"].Message)
+
+	loader.status = connectors.EnvironmentStatus{}
+	status, data = getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "environment not loaded", data.Connectors.EnvironmentLoader.Environments["env_id_1"].Message)
+
+	// Assignments manager unreachable
+	loader.status = connectors.EnvironmentStatus{Loaded: true, LastUpdate: time.Now()}
+	context.AssignmentsManager = &unhealthyManager{}
+	status, data = getHealth(t, HealthReady([]*connectors.DecisionContext{context}, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "ok", data.Connectors.EnvironmentLoader.Status)
+	assert.Equal(t, "ko", data.Connectors.AssignmentsManager.Status)
+	assert.Equal(t, "connection refused", data.Connectors.AssignmentsManager.Message)
+}
+
+func TestHealthReadyMultiEnvironments(t *testing.T) {
+	options := &models.HealthOptions{StalenessThreshold: time.Minute, Timeout: time.Second}
+	ready := utils.CreateMockDecisionContext()
+	ready.EnvironmentLoader = &statusLoader{status: connectors.EnvironmentStatus{Loaded: true, LastUpdate: time.Now()}}
+	staleLoader := &statusLoader{status: connectors.EnvironmentStatus{Loaded: true, LastUpdate: time.Now().Add(-2 * time.Minute)}}
+	stale := utils.CreateMockDecisionContext()
+	stale.EnvID = "env_id_2"
+	stale.EnvironmentLoader = staleLoader
+	contexts := []*connectors.DecisionContext{ready, stale}
+
+	// a stale environment does not take the other environments out of the load balancer
+	status, data := getHealth(t, HealthReady(contexts, options))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", data.Status)
+	assert.Equal(t, "degraded", data.Connectors.EnvironmentLoader.Status)
+	assert.Equal(t, "ok", data.Connectors.EnvironmentLoader.Environments["env_id_1"].Status)
+	assert.Equal(t, "ko", data.Connectors.EnvironmentLoader.Environments["env_id_2"].Status)
+
+	options.RequireAllEnvs = true
+	status, data = getHealth(t, HealthReady(contexts, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "ko", data.Connectors.EnvironmentLoader.Status)
+
+	// all environments stale
+	options.RequireAllEnvs = false
+	ready.EnvironmentLoader = staleLoader
+	status, data = getHealth(t, HealthReady(contexts, options))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "ko", data.Status)
+}
@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+// AdminTokenHeader is the header of the admin API token. The token can also be sent as the Authorization bearer token
+const AdminTokenHeader = "x-admin-token"
+
+const (
+	adminErrorMissingToken = "missing_admin_token"
+	adminErrorInvalidToken = "invalid_admin_token"
+	adminErrorForbiddenIP  = "forbidden_ip"
+)
+
+// AdminAuthenticator restricts the admin API to the requests with the admin token, from the allowed IPs
+type AdminAuthenticator struct {
+	options         *models.AdminOptions
+	allowedNetworks []*net.IPNet
+	trustedProxies  []*net.IPNet
+}
+
+// NewAdminAuthenticator creates an admin authenticator, and parses the allowed IPs, the trusted proxies and CIDR ranges
+func NewAdminAuthenticator(options *models.AdminOptions) (*AdminAuthenticator, error) {
+	if options.Token == "" {
+		return nil, fmt.Errorf("missing mandatory admin token")
+	}
+
+	allowedNetworks, err := utils.ParseNetworks(options.AllowedIPs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin allowed IP: %v", err)
+	}
+	trustedProxies, err := utils.ParseNetworks(options.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin trusted proxy: %v", err)
+	}
+	return &AdminAuthenticator{
+		options:         options,
+		allowedNetworks: allowedNetworks,
+		trustedProxies:  trustedProxies,
+	}, nil
+}
+
+func (a *AdminAuthenticator) isAllowedIP(r *http.Request) bool {
+	if len(a.allowedNetworks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(utils.ClientIP(r, a.options.TrustForwardedFor, a.trustedProxies))
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.allowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRequestAdminToken returns the admin token from the x-admin-token header or the Authorization bearer token
+func getRequestAdminToken(r *http.Request) string {
+	if token := r.Header.Get(AdminTokenHeader); token != "" {
+		return token
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// Authenticate returns 403 Forbidden if the client IP is not allowed, and 401 or 403 if the admin token is missing or invalid
+func (a *AdminAuthenticator) Authenticate(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.isAllowedIP(r) {
+			utils.WriteClientErrorCode(w, http.StatusForbidden, adminErrorForbiddenIP, "client IP is not allowed")
+			return
+		}
+
+		token := getRequestAdminToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			utils.WriteClientErrorCode(w, http.StatusUnauthorized, adminErrorMissingToken, "missing admin token. Set the x-admin-token header or the Authorization bearer token")
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(a.options.Token), []byte(token)) != 1 {
+			utils.WriteClientErrorCode(w, http.StatusForbidden, adminErrorInvalidToken, "invalid admin token")
+			return
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuthenticator(t *testing.T) {
+	_, err := NewAdminAuthenticator(&models.AdminOptions{Enabled: true})
+	assert.NotNil(t, err)
+
+	_, err = NewAdminAuthenticator(&models.AdminOptions{Enabled: true, Token: "token", AllowedIPs: []string{"not an ip"}})
+	assert.NotNil(t, err)
+
+	authenticator, err := NewAdminAuthenticator(&models.AdminOptions{
+		Enabled:    true,
+		Token:      "token",
+		AllowedIPs: []string{"10.0.0.0/8", "1.1.1.1"},
+	})
+	assert.Nil(t, err)
+
+	handler := authenticator.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func(remoteAddr string, headers map[string]string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v2/admin/environment", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		handler(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, call("2.2.2.2:1234", map[string]string{AdminTokenHeader: "token"}))
+	assert.Equal(t, http.StatusUnauthorized, call("1.1.1.1:1234", nil))
+	assert.Equal(t, http.StatusForbidden, call("1.1.1.1:1234", map[string]string{AdminTokenHeader: "wrong"}))
+	assert.Equal(t, http.StatusOK, call("1.1.1.1:1234", map[string]string{AdminTokenHeader: "token"}))
+	assert.Equal(t, http.StatusOK, call("10.1.2.3:1234", map[string]string{"Authorization": "Bearer token"}))
+
+	// the X-Forwarded-For header is ignored unless trusted
+	assert.Equal(t, http.StatusForbidden, call("2.2.2.2:1234", map[string]string{AdminTokenHeader: "token", "X-Forwarded-For": "1.1.1.1"}))
+	authenticator.options.TrustForwardedFor = true
+	assert.Equal(t, http.StatusOK, call("2.2.2.2:1234", map[string]string{AdminTokenHeader: "token", "X-Forwarded-For": "1.1.1.1"}))
+	// the client can not prepend an allowed IP to the X-Forwarded-For header
+	assert.Equal(t, http.StatusForbidden, call("2.2.2.2:1234", map[string]string{AdminTokenHeader: "token", "X-Forwarded-For": "1.1.1.1, 3.3.3.3"}))
+
+	_, err = NewAdminAuthenticator(&models.AdminOptions{Enabled: true, Token: "token", TrustedProxies: []string{"not an ip"}})
+	assert.NotNil(t, err)
+	authenticator, err = NewAdminAuthenticator(&models.AdminOptions{
+		Enabled:           true,
+		Token:             "token",
+		AllowedIPs:        []string{"1.1.1.1"},
+		TrustForwardedFor: true,
+		TrustedProxies:    []string{"10.0.0.0/8"},
+	})
+	assert.Nil(t, err)
+	handler = authenticator.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	assert.Equal(t, http.StatusOK, call("10.0.0.1:1234", map[string]string{AdminTokenHeader: "token", "X-Forwarded-For": "3.3.3.3, 1.1.1.1, 10.0.0.2"}))
+	assert.Equal(t, http.StatusForbidden, call("10.0.0.1:1234", map[string]string{AdminTokenHeader: "token", "X-Forwarded-For": "1.1.1.1, 3.3.3.3, 10.0.0.2"}))
+}
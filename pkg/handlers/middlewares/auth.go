@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+const (
+	authErrorMissingKey = "missing_api_key"
+	authErrorInvalidKey = "invalid_api_key"
+)
+
+// getRequestAPIKey returns the API key from the x-api-key header or the Authorization bearer token
+func getRequestAPIKey(r *http.Request) string {
+	if apiKey := r.Header.Get("x-api-key"); apiKey != "" {
+		return apiKey
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// isExemptedRoute returns true if the path equals an exempted route, or is under an exempted route ending with a slash
+func isExemptedRoute(authOptions *models.AuthOptions, path string) bool {
+	for _, route := range authOptions.ExemptedRoutes {
+		if path == route || (strings.HasSuffix(route, "/") && strings.HasPrefix(path, route)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidAPIKey(apiKeys []string, apiKey string) bool {
+	valid := false
+	for _, k := range apiKeys {
+		if k != "" && subtle.ConstantTimeCompare([]byte(k), []byte(apiKey)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// CheckAPIKey returns true if the API key matches the environment API key or one of the additional API keys set in the auth options
+func CheckAPIKey(authOptions *models.AuthOptions, envAPIKey string, apiKey string) bool {
+	apiKeys := []string{envAPIKey}
+	if authOptions != nil {
+		apiKeys = append(apiKeys, authOptions.APIKeys...)
+	}
+	return isValidAPIKey(apiKeys, apiKey)
+}
+
+// Authentication checks that the request API key matches one of the API keys allowed for the environment,
+// or the additional API keys set in the auth options
+func Authentication(authOptions *models.AuthOptions, envAPIKey string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authOptions == nil || !authOptions.Enabled || r.Method == http.MethodOptions || isExemptedRoute(authOptions, r.URL.Path) {
+			handler(w, r)
+			return
+		}
+
+		apiKey := getRequestAPIKey(r)
+		if apiKey == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			utils.WriteClientErrorCode(w, http.StatusUnauthorized, authErrorMissingKey, "missing API key. Set the x-api-key header or the Authorization bearer token")
+			return
+		}
+
+		if !CheckAPIKey(authOptions, envAPIKey, apiKey) {
+			utils.WriteClientErrorCode(w, http.StatusForbidden, authErrorInvalidKey, "invalid API key")
+			return
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthentication(t *testing.T) {
+	authOptions := &models.AuthOptions{
+		Enabled:        true,
+		APIKeys:        []string{"other_api_key"},
+		ExemptedRoutes: []string{"/v2/swagger/", "/v2/metrics"},
+	}
+	handler := Authentication(authOptions, "api_key", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil))
+	assert.Equal(t, 401, w.Code)
+	errMessage := &utils.ClientErrorCodeMessage{}
+	err := json.NewDecoder(w.Body).Decode(errMessage)
+	assert.Nil(t, err)
+	assert.Equal(t, authErrorMissingKey, errMessage.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil)
+	req.Header.Set("x-api-key", "wrong_api_key")
+	handler(w, req)
+	assert.Equal(t, 403, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil)
+	req.Header.Set("x-api-key", "api_key")
+	handler(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil)
+	req.Header.Set("Authorization", "Bearer other_api_key")
+	handler(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v2/swagger/index.html", nil))
+	assert.Equal(t, 200, w.Code)
+
+	// routes without a trailing slash only exempt their exact path
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v2/metrics", nil))
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v2/metricsX", nil))
+	assert.Equal(t, 401, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/v2/metrics/prometheus", nil))
+	assert.Equal(t, 401, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodOptions, "/v2/campaigns", nil))
+	assert.Equal(t, 200, w.Code)
+
+	authOptions.Enabled = false
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil))
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,102 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/flagship-io/decision-api/internal/handle"
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/models"
+)
+
+// httpCacheVaryHeaders are the request headers changing the decision response of a URL
+const httpCacheVaryHeaders = "X-Env-Id, X-Api-Key, Authorization, " + utils.ForcedVariationsHeader
+
+// httpCacheEnrichmentVary returns the request headers the enriched context keys are derived from. It returns false if a key
+// depends on the client IP or the server clock, which the caches cannot vary on
+func httpCacheEnrichmentVary(options *models.ContextEnrichmentOptions) (string, bool) {
+	if options == nil {
+		return "", true
+	}
+	vary := ""
+	if slices.ContainsFunc(options.Keys, func(key string) bool {
+		return key == models.ContextKeyDeviceType || key == models.ContextKeyOS || key == models.ContextKeyBrowser
+	}) {
+		vary += ", User-Agent"
+	}
+	if slices.Contains(options.Keys, models.ContextKeyLanguage) {
+		vary += ", Accept-Language"
+	}
+	cacheable := !slices.ContainsFunc(options.Keys, func(key string) bool {
+		return key == models.ContextKeyIP || key == models.ContextKeyHour || key == models.ContextKeyDayOfWeek
+	})
+	return vary, cacheable
+}
+
+// httpCacheResponseWriter adds the cache headers to the successful responses only
+type httpCacheResponseWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	vary         string
+	wroteHeader  bool
+}
+
+func (w *httpCacheResponseWriter) WriteHeader(code int) {
+	// the handlers can opt out of the cache with their own Cache-Control header
+	if !w.wroteHeader && code == http.StatusOK && w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", w.cacheControl)
+		w.Header().Add("Vary", w.vary)
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *httpCacheResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController
+func (w *httpCacheResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// HTTPCache adds Cache-Control and Vary headers to the successful responses of the GET decision requests
+// that do not trigger hits, so that CDNs and browsers can cache them. The query parameters define the whole decision
+// request, so the responses only vary with the environment, API key and forced variations headers. The responses of
+// the QA testers with forced variations are never stored by shared caches nor browsers.
+// The responses also vary with the headers of the enriched context keys. They are not cached when the context is enriched
+// with the client IP or the server clock
+func HTTPCache(options *models.HTTPCacheOptions, enrichment *models.ContextEnrichmentOptions, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	enrichmentVary, cacheable := httpCacheEnrichmentVary(enrichment)
+	vary := httpCacheVaryHeaders + enrichmentVary
+	return func(w http.ResponseWriter, r *http.Request) {
+		if options == nil || options.MaxAge <= 0 || !cacheable || r.Method != http.MethodGet {
+			handler(w, r)
+			return
+		}
+
+		decisionRequest, forcedVariationsToken, err := utils.GetDecisionRequestAndForcedVariations(r)
+		if err == nil && forcedVariationsToken != "" {
+			handler(&httpCacheResponseWriter{
+				ResponseWriter: w,
+				cacheControl:   "private, no-store",
+				vary:           vary,
+			}, r)
+			return
+		}
+		if err != nil || handle.ShouldTriggerHit(decisionRequest) {
+			handler(w, r)
+			return
+		}
+
+		handler(&httpCacheResponseWriter{
+			ResponseWriter: w,
+			cacheControl:   fmt.Sprintf("public, max-age=%d", int(options.MaxAge.Seconds())),
+			vary:           vary,
+		}, r)
+	}
+}
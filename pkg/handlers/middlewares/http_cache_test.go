@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPCache(t *testing.T) {
+	status := http.StatusOK
+	handler := HTTPCache(&models.HTTPCacheOptions{MaxAge: time.Minute}, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	call := func(method string, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := call(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1&trigger_hit=false")
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "X-Env-Id, X-Api-Key, Authorization, X-Forced-Variations", w.Header().Get("Vary"))
+
+	// requests triggering hits are not cached
+	w = call(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1")
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+	w = call(http.MethodPost, "/v2/campaigns?visitor_id=visitor_1&trigger_hit=false")
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	// requests with forced variations are never stored
+	w = call(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1&trigger_hit=false&forced_variations=token")
+	assert.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1", nil)
+	req.Header.Set("X-Forced-Variations", "token")
+	handler(w, req)
+	assert.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+
+	// errors are not cached
+	status = http.StatusBadRequest
+	w = call(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1&trigger_hit=false")
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	// the handlers can opt out of the cache
+	w = httptest.NewRecorder()
+	HTTPCache(&models.HTTPCacheOptions{MaxAge: time.Minute}, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("{}"))
+	})(w, httptest.NewRequest(http.MethodGet, "/v2/flags?visitor_id=visitor_1&trigger_hit=false", nil))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// disabled by default
+	w = httptest.NewRecorder()
+	HTTPCache(&models.HTTPCacheOptions{}, nil, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})(w, httptest.NewRequest(http.MethodGet, "/v2/campaigns?visitor_id=visitor_1&trigger_hit=false", nil))
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
+
+func TestHTTPCacheContextEnrichment(t *testing.T) {
+	options := &models.HTTPCacheOptions{MaxAge: time.Minute}
+	call := func(enrichment *models.ContextEnrichmentOptions) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		HTTPCache(options, enrichment, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("{}"))
+		})(w, httptest.NewRequest(http.MethodGet, "/v2/flags?visitor_id=visitor_1&trigger_hit=false", nil))
+		return w
+	}
+
+	// the responses vary with the headers of the enriched keys
+	w := call(&models.ContextEnrichmentOptions{Keys: []string{models.ContextKeyDeviceType, models.ContextKeyBrowser, models.ContextKeyLanguage}})
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "X-Env-Id, X-Api-Key, Authorization, X-Forced-Variations, User-Agent, Accept-Language", w.Header().Get("Vary"))
+
+	w = call(&models.ContextEnrichmentOptions{})
+	assert.Equal(t, "X-Env-Id, X-Api-Key, Authorization, X-Forced-Variations", w.Header().Get("Vary"))
+
+	// the client IP and the server clock cannot be cached
+	for _, key := range []string{models.ContextKeyIP, models.ContextKeyHour, models.ContextKeyDayOfWeek} {
+		w = call(&models.ContextEnrichmentOptions{Keys: []string{models.ContextKeyOS, key}})
+		assert.Empty(t, w.Header().Get("Cache-Control"))
+		assert.Empty(t, w.Header().Get("Vary"))
+	}
+}
@@ -1,16 +1,34 @@
 package middlewares
 
 import (
+	"encoding/json"
+	"expvar"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
-	gokitexpvar "github.com/go-kit/kit/metrics/expvar"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// MetricsNamespace is the namespace of the prometheus metrics of the API
+const MetricsNamespace = "decision_api"
+
+var responseTimeQuantiles = []int{50, 90, 95, 99}
+
+// MetricsRegistry holds the metrics of a server instance
 type MetricsRegistry struct {
-	responseTimes map[string]*gokitexpvar.Histogram
-	errors        map[string]*gokitexpvar.Counter
+	responseTimes   map[string]*generic.Histogram
+	errors          map[string]*generic.Counter
+	lock            *sync.Mutex
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
 }
 
 type loggingResponseWriter struct {
@@ -29,9 +47,50 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
-var metrics *MetricsRegistry = &MetricsRegistry{
-	responseTimes: make(map[string]*gokitexpvar.Histogram),
-	errors:        make(map[string]*gokitexpvar.Counter),
+// Flush sends the buffered data to the client, for streamed responses
+func (lrw *loggingResponseWriter) Flush() {
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// NewMetricsRegistry creates a metrics registry with the request metrics and the go runtime collectors
+func NewMetricsRegistry() *MetricsRegistry {
+	registry := prometheus.NewRegistry()
+	requestDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the API requests by handler and status code",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "code"})
+	registry.MustRegister(
+		requestDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return &MetricsRegistry{
+		responseTimes:   make(map[string]*generic.Histogram),
+		errors:          make(map[string]*generic.Counter),
+		lock:            &sync.Mutex{},
+		registry:        registry,
+		requestDuration: requestDuration,
+	}
+}
+
+// Register adds prometheus collectors to the registry
+func (m *MetricsRegistry) Register(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Metrics returns the current metrics for the running API
@@ -42,22 +101,88 @@ var metrics *MetricsRegistry = &MetricsRegistry{
 // @Produce  json
 // @Success 200 {object} handlers.MetricsResponse
 // @Router /metrics [get]
-func Metrics(name string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
-	if _, ok := metrics.responseTimes[name]; !ok {
-		metrics.responseTimes[name] = gokitexpvar.NewHistogram(fmt.Sprintf("handlers.%s.response_time", name), 50)
-	}
-	if _, ok := metrics.errors[name]; !ok {
-		metrics.errors[name] = gokitexpvar.NewCounter(fmt.Sprintf("handlers.%s.errors", name))
-	}
+func Metrics(registry *MetricsRegistry, name string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	registry.registerMetrics(name)
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		lrw := NewLoggingResponseWriter(w)
 		defer func(start time.Time) {
-			metrics.responseTimes[name].Observe(float64(time.Since(start).Milliseconds()))
-			if lrw.statusCode >= 500 {
-				metrics.errors[name].Add(1)
-			}
+			registry.ObserveRequest(name, strconv.Itoa(lrw.statusCode), time.Since(start), lrw.statusCode >= 500)
 		}(start)
 		handler(lrw, r)
 	}
 }
+
+func (m *MetricsRegistry) registerMetrics(name string) (*generic.Histogram, *generic.Counter) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.responseTimes[name]; !ok {
+		m.responseTimes[name] = generic.NewHistogram(fmt.Sprintf("handlers.%s.response_time", name), 50)
+	}
+	if _, ok := m.errors[name]; !ok {
+		m.errors[name] = generic.NewCounter(fmt.Sprintf("handlers.%s.errors", name))
+	}
+	return m.responseTimes[name], m.errors[name]
+}
+
+// ObserveRequest records the response time of a request for the handler name and status code, and counts it as an error if failed
+func (m *MetricsRegistry) ObserveRequest(name string, code string, duration time.Duration, failed bool) {
+	responseTimes, errors := m.registerMetrics(name)
+	responseTimes.Observe(float64(duration.Milliseconds()))
+	if failed {
+		errors.Add(1)
+	}
+	m.requestDuration.WithLabelValues(name, code).Observe(duration.Seconds())
+}
+
+// ExpvarHandler returns the expvar variables of the process along with the handlers metrics of the registry, in JSON
+func (m *MetricsRegistry) ExpvarHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		first := true
+		write := func(key string, value string) {
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			keyJSON, _ := json.Marshal(key)
+			fmt.Fprintf(w, "%s: %s", keyJSON, value)
+		}
+
+		fmt.Fprintf(w, "{\n")
+		expvar.Do(func(kv expvar.KeyValue) {
+			write(kv.Key, kv.Value.String())
+		})
+
+		m.lock.Lock()
+		names := make([]string, 0, len(m.responseTimes))
+		for name := range m.responseTimes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			write(fmt.Sprintf("handlers.%s.errors", name), strconv.FormatFloat(m.errors[name].Value(), 'f', -1, 64))
+			for _, q := range responseTimeQuantiles {
+				// empty histograms have no quantiles
+				value := math.Max(0, m.responseTimes[name].Quantile(float64(q)/100))
+				write(fmt.Sprintf("handlers.%s.response_time.p%d", name, q), strconv.FormatFloat(value, 'f', -1, 64))
+			}
+		}
+		m.lock.Unlock()
+		fmt.Fprintf(w, "\n}\n")
+	}
+}
+
+// PrometheusHandler returns the metrics of the registry in the prometheus text format
+// @Summary Get the current metrics for the running server in the prometheus format
+// @Tags Metrics
+// @Description Gets the request latency histograms and the connectors metrics in the prometheus text exposition format
+// @ID metrics-prometheus
+// @Produce  plain
+// @Success 200 {string} string
+// @Router /metrics/prometheus [get]
+func (m *MetricsRegistry) PrometheusHandler() func(http.ResponseWriter, *http.Request) {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}).ServeHTTP
+}
@@ -1,7 +1,7 @@
 package middlewares
 
 import (
-	"expvar"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,22 +11,52 @@ import (
 )
 
 func TestMetrics(t *testing.T) {
+	registry := NewMetricsRegistry()
 	w := httptest.NewRecorder()
-	Metrics("test", func(w http.ResponseWriter, r *http.Request) {
+	Metrics(registry, "test", func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(10 * time.Millisecond)
 	})(w, &http.Request{})
 	w.Result()
-	assert.NotNil(t, metrics.responseTimes["test"])
-	assert.GreaterOrEqual(t, expvar.Get("handlers.test.response_time.p50").String(), "10")
-	assert.GreaterOrEqual(t, expvar.Get("handlers.test.response_time.p90").String(), "10")
-	assert.GreaterOrEqual(t, expvar.Get("handlers.test.response_time.p95").String(), "10")
-	assert.GreaterOrEqual(t, expvar.Get("handlers.test.response_time.p99").String(), "10")
+	assert.NotNil(t, registry.responseTimes["test"])
+	assert.GreaterOrEqual(t, registry.responseTimes["test"].Quantile(0.50), 10.)
+	assert.GreaterOrEqual(t, registry.responseTimes["test"].Quantile(0.99), 10.)
 
 	w = httptest.NewRecorder()
-	Metrics("test", func(w http.ResponseWriter, r *http.Request) {
+	Metrics(registry, "test", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 	})(w, &http.Request{})
 	w.Result()
-	assert.NotNil(t, metrics.errors["test"])
-	assert.Equal(t, "1", expvar.Get("handlers.test.errors").String())
+	assert.NotNil(t, registry.errors["test"])
+	assert.Equal(t, 1., registry.errors["test"].Value())
+
+	// registries are scoped to their instance
+	assert.Nil(t, NewMetricsRegistry().responseTimes["test"])
+
+	w = httptest.NewRecorder()
+	registry.ExpvarHandler()(w, httptest.NewRequest(http.MethodGet, "/v2/metrics", nil))
+	data := map[string]interface{}{}
+	err := json.NewDecoder(w.Result().Body).Decode(&data)
+	assert.Nil(t, err)
+	assert.NotNil(t, data["memstats"])
+	assert.Equal(t, 1., data["handlers.test.errors"])
+	assert.GreaterOrEqual(t, data["handlers.test.response_time.p50"], 0.)
+
+	w = httptest.NewRecorder()
+	registry.PrometheusHandler()(w, httptest.NewRequest(http.MethodGet, "/v2/metrics/prometheus", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, `decision_api_request_duration_seconds_count{code="200",handler="test"} 1`)
+	assert.Contains(t, body, `decision_api_request_duration_seconds_count{code="500",handler="test"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestLoggingResponseWriterFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	lrw := NewLoggingResponseWriter(w)
+
+	_, err := lrw.Write([]byte("data"))
+	assert.Nil(t, err)
+	err = http.NewResponseController(lrw).Flush()
+	assert.Nil(t, err)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, w, lrw.Unwrap())
 }
@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+)
+
+const rateLimitErrorCode = "rate_limited"
+
+// RateLimitMaxBodySize is the maximum size of the request bodies read to get the visitor IDs
+const RateLimitMaxBodySize = 4 << 20
+
+// rateLimitBody contains the visitor IDs of the decision and activation requests bodies
+type rateLimitBody struct {
+	VisitorID string `json:"visitor_id"`
+	Vid       string `json:"vid"`
+	Batch     []struct {
+		Vid string `json:"vid"`
+	} `json:"batch"`
+}
+
+// RateLimiter limits the requests rate of the routes per client IP, API key and visitor ID
+type RateLimiter struct {
+	options        *models.RateLimitOptions
+	store          RateLimitStore
+	logger         *logger.Logger
+	trustedProxies []*net.IPNet
+}
+
+// NewRateLimiter creates a rate limiter storing the token buckets in the store, and parses the trusted proxies
+func NewRateLimiter(options *models.RateLimitOptions, store RateLimitStore, logger *logger.Logger) (*RateLimiter, error) {
+	trustedProxies, err := utils.ParseNetworks(options.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit trusted proxy: %v", err)
+	}
+	return &RateLimiter{
+		options:        options,
+		store:          store,
+		logger:         logger,
+		trustedProxies: trustedProxies,
+	}, nil
+}
+
+func (l *RateLimiter) routeLimits(route string) *models.RouteRateLimits {
+	if limits, ok := l.options.Routes[route]; ok {
+		return limits
+	}
+	return l.options.Default
+}
+
+// getVisitorIDs returns the visitor IDs of the request query for GET requests, or of the request body,
+// and restores the body for the next handler. It returns an error if the body is larger than RateLimitMaxBodySize
+func getVisitorIDs(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	if r.Method == http.MethodGet {
+		decisionRequest, err := utils.GetDecisionRequestFromQuery(r)
+		if err != nil || decisionRequest.GetVisitorId().GetValue() == "" {
+			return nil, nil
+		}
+		return []string{decisionRequest.GetVisitorId().GetValue()}, nil
+	}
+
+	if r.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, RateLimitMaxBodySize))
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	body := &rateLimitBody{}
+	if err := json.Unmarshal(data, body); err != nil {
+		return nil, nil
+	}
+
+	ids := []string{body.VisitorID, body.Vid}
+	for _, item := range body.Batch {
+		ids = append(ids, item.Vid)
+	}
+
+	visitorIDs := []string{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			visitorIDs = append(visitorIDs, id)
+		}
+	}
+	return visitorIDs, nil
+}
+
+// rateLimitKey is a token bucket key and its limit
+type rateLimitKey struct {
+	key   string
+	limit *models.RateLimit
+}
+
+// take takes a token for the key, and returns false and the retry delay if the bucket is empty.
+// Requests are allowed if the store fails
+func (l *RateLimiter) take(r *http.Request, key string, limit *models.RateLimit) (bool, time.Duration) {
+	if limit == nil || limit.Rate <= 0 {
+		return true, 0
+	}
+	allowed, retryAfter, err := l.store.Take(r.Context(), key, *limit)
+	if err != nil {
+		l.logger.Errorf("error when taking rate limit token: %v", err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// RateLimit returns 429 Too Many Requests with a Retry-After header when the client IP, API key or visitor ID
+// exceeded the rate limits of the route. The tokens are taken in this order, and none is taken after a rejection
+func (l *RateLimiter) RateLimit(envID string, route string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.options == nil || !l.options.Enabled || r.Method == http.MethodOptions {
+			handler(w, r)
+			return
+		}
+
+		limits := l.routeLimits(route)
+		if limits == nil {
+			handler(w, r)
+			return
+		}
+
+		prefix := envID + ":" + route + ":"
+		keys := []rateLimitKey{{key: prefix + "ip:" + utils.ClientIP(r, l.options.TrustForwardedFor, l.trustedProxies), limit: limits.IP}}
+		if apiKey := getRequestAPIKey(r); apiKey != "" {
+			// do not store the API keys in clear
+			hash := sha256.Sum256([]byte(apiKey))
+			keys = append(keys, rateLimitKey{key: prefix + "api_key:" + hex.EncodeToString(hash[:8]), limit: limits.APIKey})
+		}
+		if limits.VisitorID != nil {
+			visitorIDs, err := getVisitorIDs(w, r)
+			if err != nil {
+				utils.WriteClientError(w, http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			for _, visitorID := range visitorIDs {
+				keys = append(keys, rateLimitKey{key: prefix + "visitor_id:" + visitorID, limit: limits.VisitorID})
+			}
+		}
+
+		for _, k := range keys {
+			if allowed, retryAfter := l.take(r, k.key, k.limit); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds())))))
+				utils.WriteClientErrorCode(w, http.StatusTooManyRequests, rateLimitErrorCode, "too many requests, retry later")
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,145 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// memoryStoreSweepInterval is the interval between two removals of the full buckets of the memory store
+const memoryStoreSweepInterval = time.Minute
+
+// RateLimitStore stores the token buckets of the rate limiter
+type RateLimitStore interface {
+	// Take takes a token from the bucket of the key. If the bucket is empty, it returns false and the delay before a token is available
+	Take(ctx context.Context, key string, limit models.RateLimit) (bool, time.Duration, error)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time when the bucket is full again
+	full time.Time
+}
+
+// take refills the bucket since the last take, and takes a token if available
+func (b *tokenBucket) take(now time.Time, limit models.RateLimit) (bool, time.Duration) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	allowed, retryAfter := false, time.Duration((1-b.tokens)/limit.Rate*float64(time.Second))
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed, retryAfter = true, 0
+	}
+	b.full = now.Add(time.Duration((float64(limit.Burst) - b.tokens) / limit.Rate * float64(time.Second)))
+	return allowed, retryAfter
+}
+
+// MemoryRateLimitStore stores the token buckets in memory, for a single instance
+type MemoryRateLimitStore struct {
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	lock      *sync.Mutex
+}
+
+// NewMemoryRateLimitStore creates an in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+		lock:      &sync.Mutex{},
+	}
+}
+
+// Take takes a token from the bucket of the key
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit models.RateLimit) (bool, time.Duration, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > memoryStoreSweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = bucket
+	}
+	allowed, retryAfter := bucket.take(now, limit)
+	return allowed, retryAfter, nil
+}
+
+// sweep removes the full buckets, which are the same as new buckets
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.After(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// takeTokenScript atomically refills the bucket stored in a hash and takes a token.
+// It returns 1 and 0 if the token was taken, or 0 and the delay in milliseconds before a token is available
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil then
+	tokens = burst
+	last = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, retry}
+`)
+
+// RedisRateLimitStore stores the token buckets in redis, to share the rate limits between several instances
+type RedisRateLimitStore struct {
+	client    redis.Scripter
+	keyPrefix string
+}
+
+// NewRedisRateLimitStore creates a rate limit store using the redis client. The keys of the buckets are prefixed with keyPrefix
+func NewRedisRateLimitStore(client redis.Scripter, keyPrefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// Close closes the redis client if it can be closed
+func (s *RedisRateLimitStore) Close() error {
+	if closer, ok := s.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Take takes a token from the bucket of the key
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit models.RateLimit) (bool, time.Duration, error) {
+	result, err := takeTokenScript.Run(ctx, s.client, []string{s.keyPrefix + key}, limit.Rate, limit.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRateLimitStore(t *testing.T, store RateLimitStore) {
+	limit := models.RateLimit{Rate: 10, Burst: 2}
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(context.Background(), "key", limit)
+		assert.Nil(t, err)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter, err := store.Take(context.Background(), "key", limit)
+	assert.Nil(t, err)
+	assert.False(t, allowed)
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, 100*time.Millisecond)
+
+	// other keys have their own bucket
+	allowed, _, err = store.Take(context.Background(), "other_key", limit)
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+
+	time.Sleep(110 * time.Millisecond)
+	allowed, _, err = store.Take(context.Background(), "key", limit)
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	testRateLimitStore(t, store)
+
+	store.sweep(time.Now().Add(time.Second))
+	assert.Len(t, store.buckets, 0)
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	testRateLimitStore(t, NewRedisRateLimitStore(client, "rate_limit:"))
+	assert.True(t, s.Exists("rate_limit:key"))
+
+	s.Close()
+	_, _, err := NewRedisRateLimitStore(client, "rate_limit:").Take(context.Background(), "key", models.RateLimit{Rate: 1, Burst: 1})
+	assert.NotNil(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter, err := NewRateLimiter(&models.RateLimitOptions{
+		Enabled: true,
+		Default: &models.RouteRateLimits{
+			IP: &models.RateLimit{Rate: 1, Burst: 3},
+		},
+		Routes: map[string]*models.RouteRateLimits{
+			"activate": {
+				VisitorID: &models.RateLimit{Rate: 1, Burst: 1},
+				APIKey:    &models.RateLimit{Rate: 1, Burst: 2},
+			},
+		},
+	}, NewMemoryRateLimitStore(), logger.New("debug", logger.FORMAT_TEXT, "test"))
+	assert.Nil(t, err)
+
+	var body string
+	handler := limiter.RateLimit("env_id", "activate", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	call := func(handler func(http.ResponseWriter, *http.Request), remoteAddr string, apiKey string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v2/activate", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+		}
+		handler(w, req)
+		return w
+	}
+
+	// per visitor ID, and the body is kept for the handler
+	w := call(handler, "1.1.1.1:1234", "", `{"vid":"v1"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, `{"vid":"v1"}`, body)
+	w = call(handler, "1.1.1.1:1234", "", `{"vid":"v1"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), rateLimitErrorCode)
+	w = call(handler, "1.1.1.1:1234", "", `{"cid":"env_id","batch":[{"vid":"v2"},{"vid":"v1"}]}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	w = call(handler, "1.1.1.1:1234", "", `{"vid":"v6"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// per visitor ID of the query of GET requests
+	req := httptest.NewRequest(http.MethodGet, "/v2/activate?visitor_id=v6", nil)
+	req.RemoteAddr = "1.1.1.1:1234"
+	w = httptest.NewRecorder()
+	handler(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// the tokens of the other keys are not taken once a key is rejected
+	w = call(handler, "1.1.1.1:1234", "", `{"cid":"env_id","batch":[{"vid":"v1"},{"vid":"v7"}]}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, http.StatusNoContent, call(handler, "1.1.1.1:1234", "", `{"vid":"v7"}`).Code)
+
+	// the bodies read for the visitor IDs are limited
+	w = call(handler, "1.1.1.1:1234", "", `{"vid":"v8","padding":"`+strings.Repeat("a", RateLimitMaxBodySize)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// per API key
+	assert.Equal(t, http.StatusNoContent, call(handler, "1.1.1.1:1234", "key", `{"vid":"v3"}`).Code)
+	assert.Equal(t, http.StatusNoContent, call(handler, "1.1.1.1:1234", "key", `{"vid":"v4"}`).Code)
+	assert.Equal(t, http.StatusTooManyRequests, call(handler, "1.1.1.1:1234", "key", `{"vid":"v5"}`).Code)
+
+	// per IP with the default limits
+	handler = limiter.RateLimit("env_id", "campaigns", func(w http.ResponseWriter, r *http.Request) {})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, call(handler, "1.1.1.1:1234", "", `{"visitor_id":"v1"}`).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, call(handler, "1.1.1.1:1234", "", `{"visitor_id":"v1"}`).Code)
+	assert.Equal(t, http.StatusOK, call(handler, "2.2.2.2:1234", "", `{"visitor_id":"v1"}`).Code)
+
+	// disabled
+	limiter.options.Enabled = false
+	assert.Equal(t, http.StatusOK, call(handler, "1.1.1.1:1234", "", `{"visitor_id":"v1"}`).Code)
+}
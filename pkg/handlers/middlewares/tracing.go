@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracing starts a server span for each request, child of the incoming W3C traceparent header if any
+func Tracing(tracer trace.Tracer, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("HTTP %s", r.Method),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+			))
+		defer span.End()
+
+		lrw := NewLoggingResponseWriter(w)
+		handler.ServeHTTP(lrw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", lrw.statusCode))
+		if lrw.statusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(lrw.statusCode))
+		}
+	})
+}
+
+// SpanName names the request span after the route of the handler
+func SpanName(name string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(fmt.Sprintf("%s %s", r.Method, name))
+		span.SetAttributes(attribute.String("http.route", name))
+		handler(w, r)
+	}
+}
@@ -38,6 +38,8 @@ type campaignsBodySwagger struct {
 	Context        campaignsBodyContextSwagger `json:"context"`
 	VisitorConsent bool                        `json:"visitor_consent"`
 	TriggerHit     bool                        `json:"trigger_hit"`
+	// ForcedVariations is the signed forced variations token of QA testers
+	ForcedVariations *string `json:"forced_variations"`
 }
 
 // nolint
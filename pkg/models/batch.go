@@ -0,0 +1,9 @@
+package models
+
+// BatchOptions are the options of the batch decision endpoints
+type BatchOptions struct {
+	// MaxSize is the maximum number of decision requests of a batch
+	MaxSize int
+	// Concurrency is the maximum number of decisions of a batch computed at the same time
+	Concurrency int
+}
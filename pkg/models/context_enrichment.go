@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// The reserved visitor context keys derived from the request metadata and the server clock.
+// The gRPC requests read the user-agent, accept-language and x-forwarded-for metadata and the peer address
+const (
+	ContextKeyDeviceType = "fs_device_type"
+	ContextKeyOS         = "fs_os"
+	ContextKeyBrowser    = "fs_browser"
+	ContextKeyLanguage   = "fs_language"
+	ContextKeyIP         = "fs_ip"
+	ContextKeyHour       = "fs_hour"
+	ContextKeyDayOfWeek  = "fs_day_of_week"
+)
+
+// ContextEnrichmentKeys are the visitor context keys that can be derived by the server
+var ContextEnrichmentKeys = []string{
+	ContextKeyDeviceType,
+	ContextKeyOS,
+	ContextKeyBrowser,
+	ContextKeyLanguage,
+	ContextKeyIP,
+	ContextKeyHour,
+	ContextKeyDayOfWeek,
+}
+
+// ContextEnrichmentOptions are the options of the visitor context keys derived by the server.
+// The values sent by the clients take precedence
+type ContextEnrichmentOptions struct {
+	// Keys are the enabled keys of ContextEnrichmentKeys
+	Keys []string
+	// Location is the time zone of the hour and day of week keys. UTC is used if nil
+	Location *time.Location
+	// TrustForwardedFor reads the IP from the rightmost X-Forwarded-For entry, set by the proxy in front of the server
+	TrustForwardedFor bool
+}
+
+// Validate checks that the enabled keys can be derived by the server
+func (o *ContextEnrichmentOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, key := range o.Keys {
+		if !slices.Contains(ContextEnrichmentKeys, key) {
+			return fmt.Errorf("unknown context enrichment key %s", key)
+		}
+	}
+	return nil
+}
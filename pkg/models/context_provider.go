@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ContextProviderOptions are the caching, timeout and circuit breaker options of the context provider calls
+type ContextProviderOptions struct {
+	// CacheTTL is the duration the visitor contexts are cached. They are not cached if 0
+	CacheTTL time.Duration
+	// Timeout is the maximum duration of a context provider call. Calls are not limited if 0
+	Timeout time.Duration
+	// CircuitBreakerThreshold is the number of consecutive failures opening the circuit breaker. It is disabled if 0
+	CircuitBreakerThreshold int
+	// CircuitBreakerCooldown is the duration the calls are skipped once the circuit breaker is open
+	CircuitBreakerCooldown time.Duration
+}
+
+// Validate checks that the durations and threshold are not negative
+func (o *ContextProviderOptions) Validate() error {
+	if o == nil {
+		return errors.New("missing context provider options")
+	}
+	if o.CacheTTL < 0 || o.Timeout < 0 || o.CircuitBreakerCooldown < 0 {
+		return errors.New("context provider durations must not be negative")
+	}
+	if o.CircuitBreakerThreshold < 0 {
+		return errors.New("context provider circuit breaker threshold must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+const (
+	// ContextSchemaModeReject rejects the decision requests whose context violates the schema
+	ContextSchemaModeReject = "reject"
+	// ContextSchemaModeCoerce converts the context values to the schema types, removes the invalid ones and logs warnings
+	ContextSchemaModeCoerce = "coerce"
+)
+
+// ContextField describes a visitor context key of the context schema
+type ContextField struct {
+	Key string
+	// Type is the type of the value: string, number or boolean
+	Type string
+	// Enum are the allowed values, if not empty
+	Enum []interface{}
+	// Min and Max bound the number values, if set
+	Min      *float64
+	Max      *float64
+	Required bool
+}
+
+// ContextSchemaOptions are the options of the visitor context schema validation
+type ContextSchemaOptions struct {
+	Mode string
+	// Strict reports the context keys missing from the schema. The reserved fs_ keys are always allowed
+	Strict bool
+	Fields []*ContextField
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flagship-io/flagship-proto/targeting"
+)
+
+// FallbackFlag is a flag value served when no environment is available
+type FallbackFlag struct {
+	Key string
+	// Type is the expected type of the value: string, boolean, number, object or array. Any type is accepted if empty
+	Type  string
+	Value interface{}
+	// Targeting restricts the value to the visitors matching all the conditions
+	Targeting []*FallbackTargeting
+}
+
+// FallbackTargeting is a condition on a visitor context key, with an operator of the campaigns targetings such as EQUALS
+type FallbackTargeting struct {
+	Key      string
+	Operator string
+	Value    interface{}
+}
+
+// FallbackOptions are the flags served by the flags and simple campaigns endpoints when the environment
+// can not be loaded or is in panic mode. The first flag of a key matching the visitor is served
+type FallbackOptions struct {
+	Flags []*FallbackFlag
+}
+
+// Validate checks the keys, types and targeting operators of the fallback flags
+func (o *FallbackOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, flag := range o.Flags {
+		if flag.Key == "" {
+			return errors.New("missing fallback flag key")
+		}
+		if flag.Type != "" && flag.Type != FallbackValueType(flag.Value) {
+			return fmt.Errorf("fallback flag %s value is not of type %s", flag.Key, flag.Type)
+		}
+		for _, t := range flag.Targeting {
+			if t.Key == "" {
+				return fmt.Errorf("missing targeting key of fallback flag %s", flag.Key)
+			}
+			if _, ok := targeting.Targeting_TargetingOperator_value[strings.ToUpper(t.Operator)]; !ok {
+				return fmt.Errorf("unknown targeting operator %s of fallback flag %s", t.Operator, flag.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// FallbackValueType returns the type of a fallback flag value: string, boolean, number, object or array
+func FallbackValueType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int32, int64, uint, uint32, uint64, float32, float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return ""
+}
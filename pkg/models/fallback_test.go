@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackOptionsValidate(t *testing.T) {
+	var options *FallbackOptions
+	assert.Nil(t, options.Validate())
+
+	options = &FallbackOptions{Flags: []*FallbackFlag{
+		{Key: "enabled", Type: "boolean", Value: true},
+		{Key: "limit", Type: "number", Value: 10, Targeting: []*FallbackTargeting{{Key: "plan", Operator: "equals", Value: "premium"}}},
+		{Key: "config", Value: map[string]interface{}{"a": 1}},
+	}}
+	assert.Nil(t, options.Validate())
+
+	assert.NotNil(t, (&FallbackOptions{Flags: []*FallbackFlag{{Value: true}}}).Validate())
+	assert.NotNil(t, (&FallbackOptions{Flags: []*FallbackFlag{{Key: "enabled", Type: "string", Value: true}}}).Validate())
+	assert.NotNil(t, (&FallbackOptions{Flags: []*FallbackFlag{{Key: "enabled", Value: true, Targeting: []*FallbackTargeting{{Key: "plan", Operator: "like"}}}}}).Validate())
+}
+
+func TestFallbackValueType(t *testing.T) {
+	assert.Equal(t, "string", FallbackValueType("a"))
+	assert.Equal(t, "boolean", FallbackValueType(false))
+	assert.Equal(t, "number", FallbackValueType(1.5))
+	assert.Equal(t, "object", FallbackValueType(map[string]interface{}{}))
+	assert.Equal(t, "array", FallbackValueType([]interface{}{}))
+	assert.Equal(t, "", FallbackValueType(nil))
+}
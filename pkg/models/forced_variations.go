@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ForcedVariationsOptions are the options of the variations forced to QA testers with signed tokens
+type ForcedVariationsOptions struct {
+	// Secret signs the forced variations tokens. Forced variations are rejected if empty
+	Secret string
+	// MaxLifetime rejects the tokens expiring later than this duration from now. 24 hours are used if zero
+	MaxLifetime time.Duration
+}
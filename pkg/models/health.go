@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// HealthOptions are the options of the readiness check
+type HealthOptions struct {
+	// StalenessThreshold is the maximum age of a loaded environment before the server is considered not ready
+	StalenessThreshold time.Duration
+	// Timeout is the maximum duration of the connectors backend checks
+	Timeout time.Duration
+	// RequireAllEnvs makes the server not ready as soon as one environment is not loaded or stale.
+	// By default, the server is not ready only when all its environments are
+	RequireAllEnvs bool
+}
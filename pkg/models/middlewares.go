@@ -1,7 +1,57 @@
 package models
 
+import "time"
+
 type CorsOptions struct {
 	Enabled        bool
 	AllowedOrigins string
 	AllowedHeaders string
 }
+
+// AuthOptions are the options of the API keys authentication. An exempted route ending with a slash exempts
+// all the paths under it, other routes only exempt their exact path
+type AuthOptions struct {
+	Enabled        bool
+	APIKeys        []string
+	ExemptedRoutes []string
+}
+
+// RateLimit is a token bucket refilled with Rate tokens per second, holding up to Burst tokens
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RouteRateLimits are the rate limits of a route per client IP, API key and visitor ID. Nil limits are disabled
+type RouteRateLimits struct {
+	IP        *RateLimit
+	APIKey    *RateLimit
+	VisitorID *RateLimit
+}
+
+// RateLimitOptions are the rate limits of the API routes. Routes without limits fallback to the default limits
+type RateLimitOptions struct {
+	Enabled           bool
+	Default           *RouteRateLimits
+	Routes            map[string]*RouteRateLimits
+	TrustForwardedFor bool
+	// TrustedProxies are the IPs or CIDR ranges of the proxies skipped when reading the client IP from the X-Forwarded-For header
+	TrustedProxies []string
+}
+
+// AdminOptions are the options of the admin API
+type AdminOptions struct {
+	Enabled bool
+	Token   string
+	// AllowedIPs restricts the admin API to these IPs or CIDR ranges when not empty
+	AllowedIPs        []string
+	TrustForwardedFor bool
+	// TrustedProxies are the IPs or CIDR ranges of the proxies skipped when reading the client IP from the X-Forwarded-For header
+	TrustedProxies []string
+}
+
+// HTTPCacheOptions are the options of the HTTP cache headers of the GET decision requests that do not trigger hits
+type HTTPCacheOptions struct {
+	// MaxAge is the max-age of the Cache-Control header. The cache headers are disabled if it is zero
+	MaxAge time.Duration
+}
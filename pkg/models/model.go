@@ -7,6 +7,12 @@ import (
 	common "github.com/flagship-io/flagship-common"
 )
 
+// EnvironmentCredentials represents the ID and API key of a Flagship environment served by the API
+type EnvironmentCredentials struct {
+	EnvID  string
+	APIKey string
+}
+
 type Environment struct {
 	Common          *common.Environment
 	HasIntegrations bool
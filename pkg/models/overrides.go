@@ -0,0 +1,19 @@
+package models
+
+// Overrides are the local overrides applied on top of the loaded environments, as an emergency kill switch
+// when the environments can not be changed from the platform
+type Overrides struct {
+	// Panic enables the panic mode of the environments
+	Panic bool `json:"panic"`
+	// Flags forces the values of flag keys in the variations providing them
+	Flags map[string]interface{} `json:"flags,omitempty"`
+	// PausedCampaigns are the IDs of the campaigns removed from the decisions
+	PausedCampaigns []string `json:"paused_campaigns,omitempty"`
+	// ReferenceCampaigns are the IDs of the campaigns whose visitors all get the reference variation
+	ReferenceCampaigns []string `json:"reference_campaigns,omitempty"`
+}
+
+// IsEmpty returns true if no override is set
+func (o *Overrides) IsEmpty() bool {
+	return !o.Panic && len(o.Flags) == 0 && len(o.PausedCampaigns) == 0 && len(o.ReferenceCampaigns) == 0
+}
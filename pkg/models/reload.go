@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ReloadOptions are the options applied to the running server and connectors when the configuration is reloaded.
+// Zero values keep the running options
+type ReloadOptions struct {
+	LogLevel           string
+	LogFormat          string
+	CorsOptions        *CorsOptions
+	PollingInterval    time.Duration
+	HitsBatchSize      int
+	HitsBatchingWindow time.Duration
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// FlagsStreamOptions are the options of the flags server-sent events stream
+type FlagsStreamOptions struct {
+	// MaxSubscribers is the maximum number of concurrent streams of the server
+	MaxSubscribers int
+	// HeartbeatInterval is the interval of the heartbeat comments keeping the connections alive
+	HeartbeatInterval time.Duration
+}
@@ -0,0 +1,25 @@
+package models
+
+import "errors"
+
+// TLSOptions are the options to serve the API over TLS. Setting ClientCAFile enables mutual TLS
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	MinVersion   string
+}
+
+// Enabled returns true if a certificate and a key are configured
+func (o *TLSOptions) Enabled() bool {
+	return o != nil && o.CertFile != "" && o.KeyFile != ""
+}
+
+// Validate checks that the certificate and the key are either both set or both empty, so that a partial
+// configuration does not silently serve the API over plain HTTP
+func (o *TLSOptions) Validate() error {
+	if o == nil || (o.CertFile == "") == (o.KeyFile == "") {
+		return nil
+	}
+	return errors.New("the TLS certificate and key files must be set together")
+}
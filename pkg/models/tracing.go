@@ -0,0 +1,18 @@
+package models
+
+// TracingExporter is the exporter used to send the traces
+type TracingExporter string
+
+const (
+	TracingExporterOTLP   TracingExporter = "otlp"
+	TracingExporterStdout TracingExporter = "stdout"
+)
+
+// TracingOptions are the options of the OpenTelemetry tracing
+type TracingOptions struct {
+	Exporter    TracingExporter
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
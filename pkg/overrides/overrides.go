@@ -0,0 +1,169 @@
+package overrides
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Manager holds the local overrides, loaded from a JSON file which is reloaded when written.
+// The overrides changed at runtime are written back to the file, if any, so that they survive restarts
+type Manager struct {
+	filename  string
+	logger    *logger.Logger
+	lock      sync.RWMutex
+	overrides *models.Overrides
+	watcher   *fsnotify.Watcher
+}
+
+// NewManager returns an overrides manager loading the overrides file if set. A missing file holds no overrides
+func NewManager(filename string, logger *logger.Logger) (*Manager, error) {
+	m := &Manager{
+		filename:  filename,
+		logger:    logger,
+		overrides: &models.Overrides{},
+	}
+	if filename == "" {
+		return m, nil
+	}
+	if err := m.Reload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Overrides returns the current overrides, which must not be modified
+func (m *Manager) Overrides() *models.Overrides {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.overrides
+}
+
+// Reload reads the overrides file. An invalid file is rejected and the current overrides are kept
+func (m *Manager) Reload() error {
+	data, err := os.ReadFile(m.filename)
+	if err != nil {
+		return err
+	}
+	overrides := &models.Overrides{}
+	if err := json.Unmarshal(data, overrides); err != nil {
+		return fmt.Errorf("invalid overrides file %s: %w", m.filename, err)
+	}
+
+	m.lock.Lock()
+	m.overrides = overrides
+	m.lock.Unlock()
+	if !overrides.IsEmpty() {
+		m.logger.Warnf("local overrides loaded from %s: %s", m.filename, data)
+	}
+	return nil
+}
+
+// Update applies the change to a copy of the current overrides, writes them to the overrides file if set and returns them.
+// The current overrides are kept if the file can not be written
+func (m *Manager) Update(change func(overrides *models.Overrides)) (*models.Overrides, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	overrides := clone(m.overrides)
+	change(overrides)
+
+	if m.filename != "" {
+		data, err := json.MarshalIndent(overrides, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		// the file is replaced at once so that the watchers never read it partially written
+		tmpFilename := m.filename + ".tmp"
+		if err := os.WriteFile(tmpFilename, data, 0o644); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmpFilename, m.filename); err != nil {
+			return nil, err
+		}
+	}
+
+	m.overrides = overrides
+	return overrides, nil
+}
+
+// Watch reloads the overrides when the file is written. The directory is watched as editors often replace the file
+func (m *Manager) Watch() error {
+	if m.filename == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(m.filename)); err != nil {
+		watcher.Close()
+		return err
+	}
+	m.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(m.filename) || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if err := m.Reload(); err != nil {
+					m.logger.Errorf("error when reloading overrides, keeping the current ones: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				m.logger.Errorf("error when watching overrides file: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the overrides file
+func (m *Manager) Close() error {
+	if m == nil || m.watcher == nil {
+		return nil
+	}
+	return m.watcher.Close()
+}
+
+// Toggle adds the ID to the IDs if enabled, or removes it otherwise
+func Toggle(ids []string, id string, enabled bool) []string {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(other string) bool {
+		return other == id
+	})
+	if enabled {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func clone(overrides *models.Overrides) *models.Overrides {
+	cloned := &models.Overrides{
+		Panic:              overrides.Panic,
+		PausedCampaigns:    slices.Clone(overrides.PausedCampaigns),
+		ReferenceCampaigns: slices.Clone(overrides.ReferenceCampaigns),
+	}
+	if overrides.Flags != nil {
+		cloned.Flags = map[string]interface{}{}
+		for key, value := range overrides.Flags {
+			cloned.Flags[key] = value
+		}
+	}
+	return cloned
+}
@@ -0,0 +1,79 @@
+package overrides
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	log := logger.New("debug", logger.FORMAT_TEXT, "test")
+
+	// no file holds no overrides
+	m, err := NewManager("", log)
+	assert.Nil(t, err)
+	assert.True(t, m.Overrides().IsEmpty())
+	overrides, err := m.Update(func(o *models.Overrides) { o.Panic = true })
+	assert.Nil(t, err)
+	assert.True(t, overrides.Panic)
+	assert.Nil(t, m.Watch())
+	assert.Nil(t, m.Close())
+
+	filename := filepath.Join(t.TempDir(), "overrides.json")
+	m, err = NewManager(filename, log)
+	assert.Nil(t, err)
+	assert.True(t, m.Overrides().IsEmpty())
+
+	err = os.WriteFile(filename, []byte(`{"flags": {"feature": false}, "paused_campaigns": ["campaign_1"]}`), 0o644)
+	assert.Nil(t, err)
+	m, err = NewManager(filename, log)
+	assert.Nil(t, err)
+	assert.Equal(t, false, m.Overrides().Flags["feature"])
+	assert.Equal(t, []string{"campaign_1"}, m.Overrides().PausedCampaigns)
+
+	// invalid files are rejected
+	err = os.WriteFile(filename, []byte(`{"panic": "yes"}`), 0o644)
+	assert.Nil(t, err)
+	assert.NotNil(t, m.Reload())
+	assert.Equal(t, []string{"campaign_1"}, m.Overrides().PausedCampaigns)
+	_, err = NewManager(filename, log)
+	assert.NotNil(t, err)
+
+	// the updates are written to the file and do not modify the previous overrides
+	previous := m.Overrides()
+	_, err = m.Update(func(o *models.Overrides) {
+		o.PausedCampaigns = Toggle(o.PausedCampaigns, "campaign_1", false)
+		o.ReferenceCampaigns = Toggle(o.ReferenceCampaigns, "campaign_2", true)
+		o.Flags["other_feature"] = "off"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"campaign_1"}, previous.PausedCampaigns)
+	assert.Nil(t, previous.Flags["other_feature"])
+
+	m, err = NewManager(filename, log)
+	assert.Nil(t, err)
+	assert.Empty(t, m.Overrides().PausedCampaigns)
+	assert.Equal(t, []string{"campaign_2"}, m.Overrides().ReferenceCampaigns)
+	assert.Equal(t, "off", m.Overrides().Flags["other_feature"])
+
+	// the file is reloaded when written
+	assert.Nil(t, m.Watch())
+	defer m.Close()
+	err = os.WriteFile(filename, []byte(`{"panic": true}`), 0o644)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return m.Overrides().Panic
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestToggle(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, Toggle([]string{"a"}, "b", true))
+	assert.Equal(t, []string{"a", "b"}, Toggle([]string{"b", "a"}, "b", true))
+	assert.Equal(t, []string{"a"}, Toggle([]string{"a", "b"}, "b", false))
+	assert.Empty(t, Toggle(nil, "b", false))
+}
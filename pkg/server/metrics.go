@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	environmentRefreshesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "environment_loader", "refreshes_total"),
+		"Number of environment refreshes by result",
+		[]string{"env_id", "result"}, nil)
+	environmentLoadedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "environment_loader", "loaded"),
+		"Whether the environment is loaded",
+		[]string{"env_id"}, nil)
+	environmentAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "environment_loader", "age_seconds"),
+		"Time since the environment was last successfully refreshed",
+		[]string{"env_id"}, nil)
+	hitsQueueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "hits_processor", "queue_depth"),
+		"Number of hits waiting to be sent",
+		nil, nil)
+	hitsSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "hits_processor", "sent_hits_total"),
+		"Number of hits sent successfully",
+		nil, nil)
+	hitsSendFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "hits_processor", "send_failures_total"),
+		"Number of failed hits batch sends",
+		nil, nil)
+	contextProviderRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "context_provider", "requests_total"),
+		"Number of visitor context requests by result",
+		[]string{"result"}, nil)
+	contextSchemaViolationsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(middlewares.MetricsNamespace, "context_schema", "violations_total"),
+		"Number of visitor context schema violations by key and reason",
+		[]string{"key", "reason"}, nil)
+)
+
+// connectorsCollector collects the metrics reported by the connectors implementing the stats interfaces
+type connectorsCollector struct {
+	decisionContexts []*connectors.DecisionContext
+	hitsProcessor    connectors.HitsProcessor
+	contextProvider  connectors.ContextProvider
+	contextValidator *contextschema.Validator
+}
+
+func (c *connectorsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- environmentRefreshesDesc
+	ch <- environmentLoadedDesc
+	ch <- environmentAgeDesc
+	ch <- hitsQueueDepthDesc
+	ch <- hitsSentDesc
+	ch <- hitsSendFailuresDesc
+	ch <- contextProviderRequestsDesc
+	ch <- contextSchemaViolationsDesc
+}
+
+func (c *connectorsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, dc := range c.decisionContexts {
+		statusProvider, ok := dc.EnvironmentLoader.(connectors.EnvironmentStatusProvider)
+		if !ok {
+			continue
+		}
+		status := statusProvider.EnvironmentStatus(dc.EnvID)
+		ch <- prometheus.MustNewConstMetric(environmentRefreshesDesc, prometheus.CounterValue, float64(status.RefreshSuccesses), dc.EnvID, "success")
+		ch <- prometheus.MustNewConstMetric(environmentRefreshesDesc, prometheus.CounterValue, float64(status.RefreshFailures), dc.EnvID, "failure")
+
+		loaded := 0.
+		if status.Loaded {
+			loaded = 1
+			ch <- prometheus.MustNewConstMetric(environmentAgeDesc, prometheus.GaugeValue, time.Since(status.LastUpdate).Seconds(), dc.EnvID)
+		}
+		ch <- prometheus.MustNewConstMetric(environmentLoadedDesc, prometheus.GaugeValue, loaded, dc.EnvID)
+	}
+
+	if statsProvider, ok := c.hitsProcessor.(connectors.HitsProcessorStatsProvider); ok {
+		stats := statsProvider.HitsProcessorStats()
+		ch <- prometheus.MustNewConstMetric(hitsQueueDepthDesc, prometheus.GaugeValue, float64(stats.QueueDepth))
+		ch <- prometheus.MustNewConstMetric(hitsSentDesc, prometheus.CounterValue, float64(stats.SentHits))
+		ch <- prometheus.MustNewConstMetric(hitsSendFailuresDesc, prometheus.CounterValue, float64(stats.SendFailures))
+	}
+
+	if statsProvider, ok := c.contextProvider.(connectors.ContextProviderStatsProvider); ok {
+		stats := statsProvider.ContextProviderStats()
+		ch <- prometheus.MustNewConstMetric(contextProviderRequestsDesc, prometheus.CounterValue, float64(stats.CacheHits), "cache_hit")
+		ch <- prometheus.MustNewConstMetric(contextProviderRequestsDesc, prometheus.CounterValue, float64(stats.Successes), "success")
+		ch <- prometheus.MustNewConstMetric(contextProviderRequestsDesc, prometheus.CounterValue, float64(stats.Failures), "failure")
+		ch <- prometheus.MustNewConstMetric(contextProviderRequestsDesc, prometheus.CounterValue, float64(stats.Rejected), "rejected")
+	}
+
+	if c.contextValidator != nil {
+		for _, count := range c.contextValidator.Violations() {
+			ch <- prometheus.MustNewConstMetric(contextSchemaViolationsDesc, prometheus.CounterValue, float64(count.Count), count.Key, count.Reason)
+		}
+	}
+}
+
+// instrumentedAssignmentsManager records the latency and errors of the assignments manager operations
+type instrumentedAssignmentsManager struct {
+	connectors.AssignmentsManager
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newInstrumentedAssignmentsManager(manager connectors.AssignmentsManager, registry *middlewares.MetricsRegistry) (*instrumentedAssignmentsManager, error) {
+	m := &instrumentedAssignmentsManager{
+		AssignmentsManager: manager,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: middlewares.MetricsNamespace,
+			Subsystem: "assignments_manager",
+			Name:      "duration_seconds",
+			Help:      "Duration of the assignments manager operations",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: middlewares.MetricsNamespace,
+			Subsystem: "assignments_manager",
+			Name:      "errors_total",
+			Help:      "Number of assignments manager operations errors",
+		}, []string{"operation"}),
+	}
+	return m, registry.Register(m.duration, m.errors)
+}
+
+func (m *instrumentedAssignmentsManager) observe(operation string, start time.Time, err error) {
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (m *instrumentedAssignmentsManager) LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error) {
+	start := time.Now()
+	assignments, err := m.AssignmentsManager.LoadAssignments(envID, visitorID)
+	m.observe("load", start, err)
+	return assignments, err
+}
+
+func (m *instrumentedAssignmentsManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	start := time.Now()
+	err := m.AssignmentsManager.SaveAssignments(envID, visitorID, vgIDAssignments, date)
+	m.observe("save", start, err)
+	return err
+}
+
+func (m *instrumentedAssignmentsManager) SaveAssignmentsBulk(envID string, assignments []*connectors.VisitorAssignmentsSave) error {
+	start := time.Now()
+	err := connectors.SaveAssignmentsBulk(m.AssignmentsManager, envID, assignments)
+	m.observe("save_bulk", start, err)
+	return err
+}
+
+// DeleteAssignments forwards the deletion to the instrumented manager if it implements it
+func (m *instrumentedAssignmentsManager) DeleteAssignments(envID string, visitorID string) error {
+	deleter, ok := m.AssignmentsManager.(connectors.AssignmentsDeleter)
+	if !ok {
+		return connectors.ErrNotSupported
+	}
+	start := time.Now()
+	err := deleter.DeleteAssignments(envID, visitorID)
+	m.observe("delete", start, err)
+	return err
+}
+
+// HealthCheck forwards the health check to the instrumented manager if it implements it
+func (m *instrumentedAssignmentsManager) HealthCheck(ctx context.Context) error {
+	if checker, ok := m.AssignmentsManager.(connectors.HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
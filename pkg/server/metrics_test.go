@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/context_providers"
+	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
+	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type failingAssignmentsManager struct {
+	assignments_managers.EmptyManager
+}
+
+func (m *failingAssignmentsManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	return errors.New("save error")
+}
+
+func getPrometheusMetrics(registry *middlewares.MetricsRegistry) string {
+	w := httptest.NewRecorder()
+	registry.PrometheusHandler()(w, httptest.NewRequest(http.MethodGet, "/v2/metrics/prometheus", nil))
+	return w.Body.String()
+}
+
+func TestConnectorsCollector(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"accountSettings":{}}`))
+	}))
+	defer cdn.Close()
+
+	loader := environment_loaders.NewCDNLoader(environment_loaders.WithBaseURL(cdn.URL), environment_loaders.WithPollingInterval(time.Minute))
+	assert.Nil(t, loader.Init("env_id", "api_key"))
+
+	registry := middlewares.NewMetricsRegistry()
+	err := registry.Register(&connectorsCollector{
+		decisionContexts: []*connectors.DecisionContext{{
+			EnvID:      "env_id",
+			APIKey:     "api_key",
+			Connectors: connectors.Connectors{EnvironmentLoader: loader},
+		}},
+		hitsProcessor: hits_processors.NewDataCollectProcessor(hits_processors.WithBatchOptions(10, time.Minute)),
+	})
+	assert.Nil(t, err)
+
+	metrics := getPrometheusMetrics(registry)
+	assert.Contains(t, metrics, `decision_api_environment_loader_refreshes_total{env_id="env_id",result="success"} 1`)
+	assert.Contains(t, metrics, `decision_api_environment_loader_refreshes_total{env_id="env_id",result="failure"} 0`)
+	assert.Contains(t, metrics, `decision_api_environment_loader_loaded{env_id="env_id"} 1`)
+	assert.Contains(t, metrics, `decision_api_environment_loader_age_seconds{env_id="env_id"}`)
+	assert.Contains(t, metrics, "decision_api_hits_processor_queue_depth 0")
+	assert.Contains(t, metrics, "decision_api_hits_processor_send_failures_total 0")
+}
+
+func TestInstrumentedAssignmentsManager(t *testing.T) {
+	registry := middlewares.NewMetricsRegistry()
+	manager, err := newInstrumentedAssignmentsManager(&failingAssignmentsManager{}, registry)
+	assert.Nil(t, err)
+
+	_, err = manager.LoadAssignments("env_id", "visitor_id")
+	assert.Nil(t, err)
+	err = manager.SaveAssignments("env_id", "visitor_id", nil, time.Now())
+	assert.NotNil(t, err)
+	err = manager.SaveAssignmentsBulk("env_id", []*connectors.VisitorAssignmentsSave{{VisitorID: "visitor_id"}})
+	assert.NotNil(t, err)
+	assert.Nil(t, manager.HealthCheck(context.Background()))
+
+	metrics := getPrometheusMetrics(registry)
+	assert.Contains(t, metrics, `decision_api_assignments_manager_duration_seconds_count{operation="load"} 1`)
+	assert.Contains(t, metrics, `decision_api_assignments_manager_duration_seconds_count{operation="save"} 1`)
+	assert.Contains(t, metrics, `decision_api_assignments_manager_errors_total{operation="save"} 1`)
+	assert.Contains(t, metrics, `decision_api_assignments_manager_errors_total{operation="save_bulk"} 1`)
+	assert.NotContains(t, metrics, `decision_api_assignments_manager_errors_total{operation="load"}`)
+}
+
+func TestContextProviderMetrics(t *testing.T) {
+	registry := middlewares.NewMetricsRegistry()
+	provider := context_providers.NewResilientProvider(&context_providers.FileProvider{}, models.ContextProviderOptions{CacheTTL: time.Minute})
+	assert.Nil(t, registry.Register(&connectorsCollector{
+		hitsProcessor:   &hits_processors.MockHitProcessor{},
+		contextProvider: provider,
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := provider.LoadVisitorContext(context.Background(), "env_id", "visitor_id")
+		assert.Nil(t, err)
+	}
+
+	metrics := getPrometheusMetrics(registry)
+	assert.Contains(t, metrics, `decision_api_context_provider_requests_total{result="cache_hit"} 1`)
+	assert.Contains(t, metrics, `decision_api_context_provider_requests_total{result="success"} 1`)
+	assert.Contains(t, metrics, `decision_api_context_provider_requests_total{result="failure"} 0`)
+}
+
+func TestContextSchemaViolationsMetrics(t *testing.T) {
+	validator, err := contextschema.NewValidator(&models.ContextSchemaOptions{
+		Mode:   models.ContextSchemaModeCoerce,
+		Fields: []*models.ContextField{{Key: "age", Type: "number", Required: true}},
+	})
+	assert.Nil(t, err)
+	registry := middlewares.NewMetricsRegistry()
+	assert.Nil(t, registry.Register(&connectorsCollector{
+		hitsProcessor:    &hits_processors.MockHitProcessor{},
+		contextValidator: validator,
+	}))
+
+	validator.Validate(map[string]*structpb.Value{"Age": structpb.NewStringValue("30")})
+	validator.Validate(map[string]*structpb.Value{})
+
+	metrics := getPrometheusMetrics(registry)
+	assert.Contains(t, metrics, `decision_api_context_schema_violations_total{key="Age",reason="unknown"} 1`)
+	assert.Contains(t, metrics, `decision_api_context_schema_violations_total{key="age",reason="type"} 1`)
+	assert.Contains(t, metrics, `decision_api_context_schema_violations_total{key="age",reason="required"} 1`)
+}
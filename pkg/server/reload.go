@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/connectors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	common "github.com/flagship-io/flagship-common"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadableAssignmentsManager forwards the operations to an assignments manager that can be replaced
+// while the server is running
+type reloadableAssignmentsManager struct {
+	manager connectors.AssignmentsManager
+	lock    *sync.RWMutex
+}
+
+func newReloadableAssignmentsManager(manager connectors.AssignmentsManager) *reloadableAssignmentsManager {
+	return &reloadableAssignmentsManager{
+		manager: manager,
+		lock:    &sync.RWMutex{},
+	}
+}
+
+func (m *reloadableAssignmentsManager) current() connectors.AssignmentsManager {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.manager
+}
+
+// replace sets the new manager and returns the previous one
+func (m *reloadableAssignmentsManager) replace(manager connectors.AssignmentsManager) connectors.AssignmentsManager {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	previous := m.manager
+	m.manager = manager
+	return previous
+}
+
+func (m *reloadableAssignmentsManager) ShouldSaveAssignments(context connectors.SaveAssignmentsContext) bool {
+	return m.current().ShouldSaveAssignments(context)
+}
+
+func (m *reloadableAssignmentsManager) LoadAssignments(envID string, visitorID string) (*common.VisitorAssignments, error) {
+	return m.current().LoadAssignments(envID, visitorID)
+}
+
+func (m *reloadableAssignmentsManager) SaveAssignments(envID string, visitorID string, vgIDAssignments map[string]*common.VisitorCache, date time.Time) error {
+	return m.current().SaveAssignments(envID, visitorID, vgIDAssignments, date)
+}
+
+// SaveAssignmentsBulk forwards the bulk save to the current manager
+func (m *reloadableAssignmentsManager) SaveAssignmentsBulk(envID string, assignments []*connectors.VisitorAssignmentsSave) error {
+	return connectors.SaveAssignmentsBulk(m.current(), envID, assignments)
+}
+
+// DeleteAssignments forwards the deletion to the current manager if it implements it
+func (m *reloadableAssignmentsManager) DeleteAssignments(envID string, visitorID string) error {
+	if deleter, ok := m.current().(connectors.AssignmentsDeleter); ok {
+		return deleter.DeleteAssignments(envID, visitorID)
+	}
+	return connectors.ErrNotSupported
+}
+
+// HealthCheck forwards the health check to the current manager if it implements it
+func (m *reloadableAssignmentsManager) HealthCheck(ctx context.Context) error {
+	if checker, ok := m.current().(connectors.HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// cors applies the running CORS options, which can be changed when the configuration is reloaded
+func (o *ServerOptions) cors(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		middlewares.Cors(o.runningCorsOptions.Load(), handler)(w, r)
+	}
+}
+
+// validateReloadOptions checks the reload options before any of them is applied
+func validateReloadOptions(options *models.ReloadOptions) error {
+	if options == nil {
+		return errors.New("missing reload options")
+	}
+	if options.LogLevel != "" {
+		if _, err := logrus.ParseLevel(options.LogLevel); err != nil {
+			return err
+		}
+	}
+	switch format := logger.LogFormat(options.LogFormat); format {
+	case "", logger.FORMAT_JSON, logger.FORMAT_TEXT:
+	default:
+		return fmt.Errorf("unknown log format %s", format)
+	}
+	if options.PollingInterval < 0 || options.HitsBatchSize < 0 || options.HitsBatchingWindow < 0 {
+		return errors.New("polling interval, hits batch size and hits batching window must not be negative")
+	}
+	return nil
+}
+
+// Reload applies the options to the running server and connectors, without restarting the listeners.
+// If assignmentsManager is set, it replaces the running assignments manager, which is then shut down.
+// The options are validated before any change, so a rejected reload leaves the running server untouched
+// and shuts the new assignments manager down
+func (s *Server) Reload(ctx context.Context, options *models.ReloadOptions, assignmentsManager connectors.AssignmentsManager) error {
+	if err := validateReloadOptions(options); err != nil {
+		if assignmentsManager != nil {
+			if shutdownErr := connectors.ShutdownConnector(ctx, assignmentsManager); shutdownErr != nil {
+				s.options.logger.Errorf("error when shutting rejected assignments manager down: %v", shutdownErr)
+			}
+		}
+		return err
+	}
+
+	if options.LogLevel != "" {
+		// the level is validated, so configuring the loggers can not fail
+		_ = s.options.logger.Configure(options.LogLevel, logger.LogFormat(options.LogFormat))
+	}
+
+	if options.CorsOptions != nil {
+		s.options.runningCorsOptions.Store(options.CorsOptions)
+	}
+
+	if assignmentsManager == nil {
+		assignmentsManager = s.assignmentsManager.current()
+	}
+	var reloadErr error
+	for _, connector := range []interface{}{s.options.environmentLoader, s.options.hitsProcessor, assignmentsManager} {
+		if err := connectors.ReloadConnector(connector, options); err != nil {
+			s.options.logger.Errorf("error when reloading connector: %v", err)
+			reloadErr = errors.Join(reloadErr, err)
+		}
+	}
+
+	if previous := s.assignmentsManager.replace(assignmentsManager); previous != assignmentsManager {
+		s.options.logger.Info("assignments manager replaced")
+		if err := connectors.ShutdownConnector(ctx, previous); err != nil {
+			s.options.logger.Errorf("error when shutting previous assignments manager down: %v", err)
+		}
+	}
+
+	s.options.logger.Info("server options reloaded")
+	return reloadErr
+}
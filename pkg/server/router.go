@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flagship-io/decision-api/internal/utils"
+)
+
+// EnvIDHeader is the header used to select the environment of a request
+const EnvIDHeader = "x-env-id"
+
+const apiPrefix = "/v2/"
+
+// bucketingFileName is the name of the bucketing file on the CDN. /{envId}/bucketing.json requests are served
+// by the bucketing route so that the SDKs can use the API as their bucketing base URL
+const bucketingFileName = "/bucketing.json"
+
+// environmentRouter dispatches requests to the handlers of the requested environment
+type environmentRouter struct {
+	defaultEnvID string
+	handlers     map[string]http.Handler
+}
+
+func (r *environmentRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	envID := req.Header.Get(EnvIDHeader)
+
+	// check for a /{envId}/bucketing.json or /v2/{envId}/bucketing.json path
+	pathEnvID := strings.Trim(strings.TrimPrefix(strings.TrimSuffix(req.URL.Path, bucketingFileName), "/v2/"), "/")
+	if _, ok := r.handlers[pathEnvID]; ok && strings.HasSuffix(req.URL.Path, bucketingFileName) {
+		envID = pathEnvID
+		req = req.Clone(req.Context())
+		req.URL.Path = apiPrefix + "bucketing"
+		req.URL.RawPath = ""
+	} else if strings.HasPrefix(req.URL.Path, apiPrefix) {
+		// check for a /v2/{envId}/... path prefix and strip it from the request path
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, apiPrefix), "/", 2)
+		if _, ok := r.handlers[parts[0]]; ok && len(parts) == 2 {
+			envID = parts[0]
+			req = req.Clone(req.Context())
+			req.URL.Path = apiPrefix + parts[1]
+			req.URL.RawPath = ""
+		}
+	}
+
+	if envID == "" {
+		envID = r.defaultEnvID
+	}
+
+	handler, ok := r.handlers[envID]
+	if !ok {
+		utils.WriteClientError(w, http.StatusBadRequest, fmt.Sprintf("environment %s not found", envID))
+		return
+	}
+
+	handler.ServeHTTP(w, req)
+}
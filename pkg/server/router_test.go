@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironmentRouter(t *testing.T) {
+	served := map[string]string{}
+	newHandler := func(envID string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served[envID] = r.URL.Path
+		})
+	}
+	router := &environmentRouter{
+		defaultEnvID: "env_1",
+		handlers: map[string]http.Handler{
+			"env_1": newHandler("env_1"),
+			"env_2": newHandler("env_2"),
+		},
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v2/campaigns", nil))
+	assert.Equal(t, "/v2/campaigns", served["env_1"])
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v2/flags", nil)
+	req.Header.Set(EnvIDHeader, "env_2")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "/v2/flags", served["env_2"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v2/env_2/campaigns/cid", nil))
+	assert.Equal(t, "/v2/campaigns/cid", served["env_2"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/env_2/bucketing.json", nil))
+	assert.Equal(t, "/v2/bucketing", served["env_2"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/env_1/bucketing.json", nil))
+	assert.Equal(t, "/v2/bucketing", served["env_1"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown/bucketing.json", nil))
+	assert.Equal(t, "/unknown/bucketing.json", served["env_1"])
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v2/flags", nil)
+	req.Header.Set(EnvIDHeader, "unknown")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
@@ -2,32 +2,67 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
-	"expvar"
+	"fmt"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/flagship-io/decision-api/docs"
 	"github.com/flagship-io/decision-api/pkg/connectors"
 	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
+	"github.com/flagship-io/decision-api/pkg/connectors/context_providers"
 	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
 	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/grpc_server"
 	"github.com/flagship-io/decision-api/pkg/handlers"
 	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
 	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/overrides"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
+	"github.com/flagship-io/decision-api/pkg/utils/contextschema"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/flagship-io/decision-api/pkg/utils/tracing"
 	common "github.com/flagship-io/flagship-common"
+	"github.com/prometheus/client_golang/prometheus"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
 )
 
 type ServerOptions struct {
-	hitsProcessor      connectors.HitsProcessor
-	environmentLoader  connectors.EnvironmentLoader
-	assignmentsManager connectors.AssignmentsManager
-	logger             *logger.Logger
-	corsOptions        *models.CorsOptions
-	recover            bool
+	hitsProcessor          connectors.HitsProcessor
+	environmentLoader      connectors.EnvironmentLoader
+	assignmentsManager     connectors.AssignmentsManager
+	contextProvider        connectors.ContextProvider
+	contextProviderOptions *models.ContextProviderOptions
+	logger                 *logger.Logger
+	corsOptions            *models.CorsOptions
+	authOptions            *models.AuthOptions
+	tlsOptions             *models.TLSOptions
+	healthOptions          *models.HealthOptions
+	rateLimitOptions       *models.RateLimitOptions
+	rateLimitStore         middlewares.RateLimitStore
+	adminOptions           *models.AdminOptions
+	flagsStreamOptions     *models.FlagsStreamOptions
+	batchOptions           *models.BatchOptions
+	httpCacheOptions       *models.HTTPCacheOptions
+	forcedVariations       *models.ForcedVariationsOptions
+	overrides              *overrides.Manager
+	fallbackOptions        *models.FallbackOptions
+	contextSchema          *models.ContextSchemaOptions
+	contextEnrichment      *models.ContextEnrichmentOptions
+	grpcAddress            string
+	tracerProvider         trace.TracerProvider
+	recover                bool
+	metrics                *middlewares.MetricsRegistry
+	rateLimiter            *middlewares.RateLimiter
+	adminAuthenticator     *middlewares.AdminAuthenticator
+	flagsStreams           *handlers.FlagsStreams
+	runningCorsOptions     atomic.Pointer[models.CorsOptions]
 }
 
 type ServerOptionsBuilder func(*ServerOptions)
@@ -50,6 +85,20 @@ func WithAssignmentsManager(manager connectors.AssignmentsManager) ServerOptions
 	}
 }
 
+// WithContextProvider sets the connector fetching the visitor context of the integration providers
+func WithContextProvider(provider connectors.ContextProvider) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.contextProvider = provider
+	}
+}
+
+// WithContextProviderOptions sets the caching, timeout and circuit breaker options of the context provider calls
+func WithContextProviderOptions(options *models.ContextProviderOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.contextProviderOptions = options
+	}
+}
+
 func WithLogger(logger *logger.Logger) ServerOptionsBuilder {
 	return func(h *ServerOptions) {
 		h.logger = logger
@@ -62,6 +111,114 @@ func WithCorsOptions(options *models.CorsOptions) ServerOptionsBuilder {
 	}
 }
 
+func WithAuthOptions(options *models.AuthOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.authOptions = options
+	}
+}
+
+func WithTLSOptions(options *models.TLSOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.tlsOptions = options
+	}
+}
+
+func WithHealthOptions(options *models.HealthOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.healthOptions = options
+	}
+}
+
+func WithRateLimitOptions(options *models.RateLimitOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.rateLimitOptions = options
+	}
+}
+
+// WithRateLimitStore sets the store of the rate limits token buckets. Use a shared store to rate limit several instances
+func WithRateLimitStore(store middlewares.RateLimitStore) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.rateLimitStore = store
+	}
+}
+
+// WithAdminOptions enables the admin API, protected by its own token
+func WithAdminOptions(options *models.AdminOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.adminOptions = options
+	}
+}
+
+// WithFlagsStreamOptions sets the subscribers cap and the heartbeat interval of the flags streams
+func WithFlagsStreamOptions(options *models.FlagsStreamOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.flagsStreamOptions = options
+	}
+}
+
+// WithBatchOptions sets the maximum size and the concurrency of the batch decisions
+func WithBatchOptions(options *models.BatchOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.batchOptions = options
+	}
+}
+
+// WithHTTPCacheOptions sets the Cache-Control max age of the GET decisions that do not trigger hits
+func WithHTTPCacheOptions(options *models.HTTPCacheOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.httpCacheOptions = options
+	}
+}
+
+// WithForcedVariationsOptions sets the secret verifying the signed forced variations of the QA decision requests
+func WithForcedVariationsOptions(options *models.ForcedVariationsOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.forcedVariations = options
+	}
+}
+
+// WithOverrides sets the manager of the local overrides applied on top of the loaded environments
+func WithOverrides(manager *overrides.Manager) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.overrides = manager
+	}
+}
+
+// WithFallbackOptions sets the flags served when an environment can not be loaded or is in panic mode
+func WithFallbackOptions(options *models.FallbackOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.fallbackOptions = options
+	}
+}
+
+// WithContextSchemaOptions sets the schema validating the visitor contexts of the decision requests
+func WithContextSchemaOptions(options *models.ContextSchemaOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.contextSchema = options
+	}
+}
+
+// WithContextEnrichmentOptions sets the visitor context keys derived from the decision requests
+func WithContextEnrichmentOptions(options *models.ContextEnrichmentOptions) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.contextEnrichment = options
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider of the API spans
+func WithTracerProvider(provider trace.TracerProvider) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.tracerProvider = provider
+	}
+}
+
+// WithGRPCAddress enables the gRPC decision service on the address
+func WithGRPCAddress(addr string) ServerOptionsBuilder {
+	return func(h *ServerOptions) {
+		h.grpcAddress = addr
+	}
+}
+
 func WithRecover(enabled bool) ServerOptionsBuilder {
 	return func(h *ServerOptions) {
 		h.recover = enabled
@@ -69,20 +226,50 @@ func WithRecover(enabled bool) ServerOptionsBuilder {
 }
 
 type Server struct {
-	options    *ServerOptions
-	httpServer *http.Server
+	options            *ServerOptions
+	decisionContexts   []*connectors.DecisionContext
+	assignmentsManager *reloadableAssignmentsManager
+	httpServer         *http.Server
+	grpcServer         *grpc.Server
+	tlsReloader        *tlsReloader
 }
 
+// Listen starts the gRPC server in background if enabled, and serves the HTTP API
 func (srv *Server) Listen() error {
+	if srv.grpcServer != nil {
+		listener, err := net.Listen("tcp", srv.options.grpcAddress)
+		if err != nil {
+			return fmt.Errorf("error when listening for gRPC server: %v", err)
+		}
+		srv.options.logger.Infof("gRPC decision service listening on %s", srv.options.grpcAddress)
+		go func() {
+			if err := srv.grpcServer.Serve(listener); err != nil {
+				srv.options.logger.Errorf("error when serving gRPC: %v", err)
+			}
+		}()
+	}
+
+	if srv.httpServer.TLSConfig != nil {
+		return srv.httpServer.ListenAndServeTLS("", "")
+	}
 	return srv.httpServer.ListenAndServe()
 }
 
-func wrapMiddlewares(serverOptions *ServerOptions, endpointName string, handler http.HandlerFunc) http.HandlerFunc {
+func wrapMiddlewares(serverOptions *ServerOptions, envID string, endpointName string, handler http.HandlerFunc) http.HandlerFunc {
+	return middlewares.Recover(
+		serverOptions.recover,
+		middlewares.Metrics(serverOptions.metrics, endpointName,
+			middlewares.SpanName(endpointName,
+				middlewares.Version(
+					serverOptions.cors(
+						serverOptions.rateLimiter.RateLimit(envID, endpointName, handler))))))
+}
+
+func wrapAdminMiddlewares(serverOptions *ServerOptions, endpointName string, handler http.HandlerFunc) http.HandlerFunc {
 	return middlewares.Recover(
 		serverOptions.recover,
-		middlewares.Metrics(endpointName,
-			middlewares.Version(
-				middlewares.Cors(serverOptions.corsOptions, handler))))
+		middlewares.Metrics(serverOptions.metrics, endpointName,
+			middlewares.SpanName(endpointName, handler)))
 }
 
 // @title Flagship Decision API
@@ -97,7 +284,13 @@ func wrapMiddlewares(serverOptions *ServerOptions, endpointName string, handler
 // @license.name Apache 2.0
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 func CreateServer(envID string, apiKey string, addr string, opts ...ServerOptionsBuilder) (*Server, error) {
+	return CreateMultiEnvironmentServer([]models.EnvironmentCredentials{{EnvID: envID, APIKey: apiKey}}, addr, opts...)
+}
 
+// CreateMultiEnvironmentServer creates a server serving decisions for several environments.
+// Requests are routed to an environment with the x-env-id header or the /v2/{envId}/... path prefix,
+// and fallback to the first environment
+func CreateMultiEnvironmentServer(environments []models.EnvironmentCredentials, addr string, opts ...ServerOptionsBuilder) (*Server, error) {
 	// Dynamic swagger version
 	docs.SwaggerInfo.Version = models.Version
 
@@ -108,24 +301,69 @@ func CreateServer(envID string, apiKey string, addr string, opts ...ServerOption
 		),
 		hitsProcessor:      hits_processors.NewDataCollectProcessor(),
 		assignmentsManager: &assignments_managers.EmptyManager{},
+		contextProviderOptions: &models.ContextProviderOptions{
+			CacheTTL:                config.ContextProviderCacheTTL,
+			Timeout:                 config.ContextProviderTimeout,
+			CircuitBreakerThreshold: config.ContextProviderCircuitBreakerThreshold,
+			CircuitBreakerCooldown:  config.ContextProviderCircuitBreakerCooldown,
+		},
 		corsOptions: &models.CorsOptions{
 			Enabled:        config.ServerCorsEnabled,
 			AllowedOrigins: config.ServerCorsAllowedOrigins,
 			AllowedHeaders: config.ServerCorsAllowedHeaders,
 		},
-		recover: true,
+		authOptions: &models.AuthOptions{
+			Enabled:        config.ServerAuthEnabled,
+			ExemptedRoutes: config.ServerAuthExemptedRoutes,
+		},
+		healthOptions: &models.HealthOptions{
+			StalenessThreshold: config.HealthStalenessThreshold,
+			Timeout:            config.HealthTimeout,
+		},
+		rateLimitOptions: &models.RateLimitOptions{
+			Enabled: config.RateLimitEnabled,
+		},
+		rateLimitStore: middlewares.NewMemoryRateLimitStore(),
+		adminOptions: &models.AdminOptions{
+			Enabled: config.AdminEnabled,
+		},
+		flagsStreamOptions: &models.FlagsStreamOptions{
+			MaxSubscribers:    config.FlagsStreamMaxSubscribers,
+			HeartbeatInterval: config.FlagsStreamHeartbeatInterval,
+		},
+		batchOptions: &models.BatchOptions{
+			MaxSize:     config.BatchMaxSize,
+			Concurrency: config.BatchConcurrency,
+		},
+		httpCacheOptions: &models.HTTPCacheOptions{
+			MaxAge: config.HTTPCacheMaxAge,
+		},
+		tracerProvider: noop.NewTracerProvider(),
+		recover:        true,
 	}
 
 	for _, opt := range opts {
 		opt(serverOptions)
 	}
 
-	if envID == "" {
-		return nil, errors.New("missing mandatory environment ID")
+	if len(environments) == 0 {
+		return nil, errors.New("missing mandatory environment")
 	}
 
-	if apiKey == "" {
-		return nil, errors.New("missing mandatory API Key")
+	for i, env := range environments {
+		if env.EnvID == "" {
+			return nil, errors.New("missing mandatory environment ID")
+		}
+
+		if env.APIKey == "" {
+			return nil, errors.New("missing mandatory API Key")
+		}
+
+		for _, other := range environments[:i] {
+			if other.EnvID == env.EnvID {
+				return nil, fmt.Errorf("environment %s is configured more than once", env.EnvID)
+			}
+		}
 	}
 
 	if serverOptions.logger == nil {
@@ -144,20 +382,61 @@ func CreateServer(envID string, apiKey string, addr string, opts ...ServerOption
 		return nil, errors.New("missing mandatory visitorAssignmentLoader connector")
 	}
 
-	err := serverOptions.environmentLoader.Init(envID, apiKey)
-	if err != nil {
-		serverOptions.logger.Errorf("error when initializing environment loader: %v", err)
+	if serverOptions.rateLimitOptions == nil || serverOptions.rateLimitStore == nil {
+		return nil, errors.New("missing mandatory rate limit options and store")
 	}
 
-	context := &connectors.DecisionContext{
-		APIKey: apiKey,
-		EnvID:  envID,
-		Logger: serverOptions.logger,
-		Connectors: connectors.Connectors{
-			HitsProcessor:      serverOptions.hitsProcessor,
-			EnvironmentLoader:  serverOptions.environmentLoader,
-			AssignmentsManager: serverOptions.assignmentsManager,
-		},
+	if err := serverOptions.contextProviderOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid context provider options: %w", err)
+	}
+	if serverOptions.contextProvider != nil {
+		serverOptions.contextProvider = context_providers.NewResilientProvider(serverOptions.contextProvider, *serverOptions.contextProviderOptions)
+	}
+
+	if err := serverOptions.fallbackOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid fallback options: %w", err)
+	}
+
+	if err := serverOptions.contextEnrichment.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid context enrichment options: %w", err)
+	}
+
+	if err := serverOptions.tlsOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid TLS options: %w", err)
+	}
+
+	var contextValidator *contextschema.Validator
+	if serverOptions.contextSchema != nil && len(serverOptions.contextSchema.Fields) > 0 {
+		validator, err := contextschema.NewValidator(serverOptions.contextSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid context schema: %w", err)
+		}
+		contextValidator = validator
+	}
+
+	if serverOptions.overrides == nil {
+		// without overrides file, the overrides set from the admin API are kept in memory
+		serverOptions.overrides, _ = overrides.NewManager("", serverOptions.logger)
+	}
+
+	if serverOptions.tracerProvider == nil {
+		return nil, errors.New("missing mandatory tracer provider")
+	}
+
+	if serverOptions.healthOptions == nil {
+		return nil, errors.New("missing mandatory health options")
+	}
+
+	if serverOptions.flagsStreamOptions == nil || serverOptions.flagsStreamOptions.HeartbeatInterval <= 0 {
+		return nil, errors.New("missing mandatory flags stream heartbeat interval")
+	}
+
+	if serverOptions.batchOptions == nil {
+		return nil, errors.New("missing mandatory batch options")
+	}
+
+	if serverOptions.httpCacheOptions == nil {
+		return nil, errors.New("missing mandatory http cache options")
 	}
 
 	// set the logger for common package
@@ -166,27 +445,191 @@ func CreateServer(envID string, apiKey string, addr string, opts ...ServerOption
 		Entry: commonLogger.Entry,
 	})
 
-	mux := http.NewServeMux()
+	// metrics are scoped to the server instance
+	serverOptions.metrics = middlewares.NewMetricsRegistry()
+	rateLimiter, err := middlewares.NewRateLimiter(serverOptions.rateLimitOptions, serverOptions.rateLimitStore, serverOptions.logger)
+	if err != nil {
+		return nil, err
+	}
+	serverOptions.rateLimiter = rateLimiter
+	serverOptions.runningCorsOptions.Store(serverOptions.corsOptions)
+	serverOptions.flagsStreams = handlers.NewFlagsStreams(serverOptions.flagsStreamOptions)
+	if serverOptions.adminOptions != nil && serverOptions.adminOptions.Enabled {
+		authenticator, err := middlewares.NewAdminAuthenticator(serverOptions.adminOptions)
+		if err != nil {
+			return nil, err
+		}
+		serverOptions.adminAuthenticator = authenticator
+	}
+	reloadableManager := newReloadableAssignmentsManager(serverOptions.assignmentsManager)
+	assignmentsManager, err := newInstrumentedAssignmentsManager(reloadableManager, serverOptions.metrics)
+	if err != nil {
+		return nil, fmt.Errorf("error when registering assignments manager metrics: %v", err)
+	}
 
-	mux.HandleFunc("/v2/campaigns", wrapMiddlewares(serverOptions, "campaigns", handlers.Campaigns(context)))
-	mux.HandleFunc("/v2/campaigns/", wrapMiddlewares(serverOptions, "campaign", handlers.Campaign(context)))
-	mux.HandleFunc("/v2/activate", wrapMiddlewares(serverOptions, "activate", handlers.Activate(context)))
-	mux.HandleFunc("/v2/flags", wrapMiddlewares(serverOptions, "flags", handlers.Flags(context)))
-	mux.HandleFunc("/v2/metrics", wrapMiddlewares(serverOptions, "metrics", expvar.Handler().ServeHTTP))
-	mux.HandleFunc("/v2/swagger/", httpSwagger.WrapHandler)
+	tracer := serverOptions.tracerProvider.Tracer(tracing.TracerName, trace.WithInstrumentationVersion(models.Version))
+
+	router := &environmentRouter{
+		defaultEnvID: environments[0].EnvID,
+		handlers:     map[string]http.Handler{},
+	}
+	decisionContexts := []*connectors.DecisionContext{}
+	for _, env := range environments {
+		err := serverOptions.environmentLoader.Init(env.EnvID, env.APIKey)
+		if err != nil {
+			serverOptions.logger.Errorf("error when initializing environment loader for environment %s: %v", env.EnvID, err)
+		}
+
+		context := &connectors.DecisionContext{
+			APIKey:                   env.APIKey,
+			EnvID:                    env.EnvID,
+			Logger:                   serverOptions.logger,
+			Tracer:                   tracer,
+			ForcedVariationsOptions:  serverOptions.forcedVariations,
+			ContextSchema:            contextValidator,
+			ContextEnrichmentOptions: serverOptions.contextEnrichment,
+			FallbackOptions:          serverOptions.fallbackOptions,
+			Overrides:                serverOptions.overrides,
+			Connectors: connectors.Connectors{
+				HitsProcessor:      serverOptions.hitsProcessor,
+				EnvironmentLoader:  serverOptions.environmentLoader,
+				AssignmentsManager: assignmentsManager,
+				ContextProvider:    serverOptions.contextProvider,
+			},
+		}
+		decisionContexts = append(decisionContexts, context)
+	}
+
+	err = serverOptions.metrics.Register(&connectorsCollector{
+		decisionContexts: decisionContexts,
+		hitsProcessor:    serverOptions.hitsProcessor,
+		contextProvider:  serverOptions.contextProvider,
+		contextValidator: contextValidator,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when registering connectors metrics: %v", err)
+	}
+
+	err = serverOptions.metrics.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: middlewares.MetricsNamespace,
+		Name:      "flags_stream_subscribers",
+		Help:      "Number of open flags streams",
+	}, func() float64 {
+		return float64(serverOptions.flagsStreams.Subscribers())
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("error when registering flags stream metrics: %v", err)
+	}
+
+	// readiness reports the status of all the environments whatever the environment of the request
+	healthReady := handlers.HealthReady(decisionContexts, serverOptions.healthOptions)
+	for _, context := range decisionContexts {
+		router.handlers[context.EnvID] = createEnvironmentMux(serverOptions, context, healthReady)
+	}
+
+	var tlsConfig, grpcTLSConfig *tls.Config
+	var reloader *tlsReloader
+	if serverOptions.tlsOptions.Enabled() {
+		reloader, err = newTLSReloader(serverOptions.tlsOptions, serverOptions.logger)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = reloader.tlsConfig("h2", "http/1.1")
+		grpcTLSConfig = reloader.tlsConfig("h2")
+	}
 
 	server := &Server{
-		options: serverOptions,
+		options:            serverOptions,
+		decisionContexts:   decisionContexts,
+		assignmentsManager: reloadableManager,
+		tlsReloader:        reloader,
 		httpServer: &http.Server{
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 10 * time.Second,
 			Addr:         addr,
-			Handler:      middlewares.RequestLogger(serverOptions.logger, mux),
+			TLSConfig:    tlsConfig,
+			Handler:      middlewares.RequestLogger(serverOptions.logger, middlewares.Tracing(tracer, router)),
 		}}
+	// the flags streams would otherwise keep the server from shutting down until the drain timeout
+	server.httpServer.RegisterOnShutdown(serverOptions.flagsStreams.Close)
+
+	if serverOptions.grpcAddress != "" {
+		server.grpcServer = grpc_server.NewServer(grpc_server.Options{
+			DecisionContexts: decisionContexts,
+			AuthOptions:      serverOptions.authOptions,
+			Logger:           serverOptions.logger,
+			Recover:          serverOptions.recover,
+			TLSConfig:        grpcTLSConfig,
+			Metrics:          serverOptions.metrics,
+		})
+	}
 
 	return server, nil
 }
 
+// createEnvironmentMux registers the API routes for a single environment
+func createEnvironmentMux(serverOptions *ServerOptions, context *connectors.DecisionContext, healthReady http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/campaigns", wrapMiddlewares(serverOptions, context.EnvID, "campaigns", middlewares.HTTPCache(serverOptions.httpCacheOptions, serverOptions.contextEnrichment, handlers.Campaigns(context))))
+	mux.HandleFunc("/v2/campaigns/", wrapMiddlewares(serverOptions, context.EnvID, "campaign", middlewares.HTTPCache(serverOptions.httpCacheOptions, serverOptions.contextEnrichment, handlers.Campaign(context))))
+	mux.HandleFunc("/v2/campaigns/explain", wrapMiddlewares(serverOptions, context.EnvID, "campaigns_explain", handlers.CampaignsExplain(context)))
+	mux.HandleFunc("/v2/campaigns/batch", wrapMiddlewares(serverOptions, context.EnvID, "campaigns_batch", handlers.CampaignsBatch(context, serverOptions.batchOptions)))
+	mux.HandleFunc("/v2/activate", wrapMiddlewares(serverOptions, context.EnvID, "activate", handlers.Activate(context)))
+	mux.HandleFunc("/v2/flags", wrapMiddlewares(serverOptions, context.EnvID, "flags", middlewares.HTTPCache(serverOptions.httpCacheOptions, serverOptions.contextEnrichment, handlers.Flags(context))))
+	mux.HandleFunc("/v2/flags/activate", wrapMiddlewares(serverOptions, context.EnvID, "flags_activate", handlers.FlagsActivate(context)))
+	mux.HandleFunc("/v2/flags/batch", wrapMiddlewares(serverOptions, context.EnvID, "flags_batch", handlers.FlagsBatch(context, serverOptions.batchOptions)))
+	mux.HandleFunc("/v2/flags/stream", wrapMiddlewares(serverOptions, context.EnvID, "flags_stream", handlers.FlagsStream(context, serverOptions.flagsStreams)))
+	// the literal /v2/flags routes take precedence over the single flag route, so the activate, batch and stream keys are reserved
+	mux.HandleFunc("/v2/flags/{key}", wrapMiddlewares(serverOptions, context.EnvID, "flag", middlewares.HTTPCache(serverOptions.httpCacheOptions, serverOptions.contextEnrichment, handlers.Flag(context))))
+	mux.HandleFunc("/v2/flags/{key}/activate", wrapMiddlewares(serverOptions, context.EnvID, "flag_activate", handlers.FlagActivate(context)))
+	// the SDKs in bucketing mode fetch the bucketing file without API key, the route is exempted from authentication by default
+	mux.HandleFunc("/v2/bucketing", wrapMiddlewares(serverOptions, context.EnvID, "bucketing", handlers.Bucketing(context)))
+	mux.HandleFunc("/v2/metrics", wrapMiddlewares(serverOptions, context.EnvID, "metrics", serverOptions.metrics.ExpvarHandler()))
+	mux.HandleFunc("/v2/metrics/prometheus", serverOptions.metrics.PrometheusHandler())
+	mux.HandleFunc("/v2/health/live", handlers.HealthLive())
+	mux.HandleFunc("/v2/health/ready", healthReady)
+	mux.HandleFunc("/v2/swagger/", httpSwagger.WrapHandler)
+
+	handler := http.HandlerFunc(middlewares.Authentication(serverOptions.authOptions, context.APIKey, mux.ServeHTTP))
+	if serverOptions.adminAuthenticator == nil {
+		return handler
+	}
+
+	// the admin API is protected by the admin token instead of the API keys
+	root := http.NewServeMux()
+	root.Handle("/v2/admin/", createAdminMux(serverOptions, context))
+	root.Handle("/", handler)
+	return root
+}
+
+// createAdminMux registers the admin API routes for a single environment
+func createAdminMux(serverOptions *ServerOptions, context *connectors.DecisionContext) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v2/admin/environment", wrapAdminMiddlewares(serverOptions, "admin_environment", handlers.AdminEnvironment(context)))
+	mux.HandleFunc("POST /v2/admin/environment/refresh", wrapAdminMiddlewares(serverOptions, "admin_environment_refresh", handlers.AdminRefreshEnvironment(context)))
+	mux.HandleFunc("GET /v2/admin/visitors/{visitorId}/assignments", wrapAdminMiddlewares(serverOptions, "admin_visitor_assignments", handlers.AdminVisitorAssignments(context)))
+	mux.HandleFunc("DELETE /v2/admin/visitors/{visitorId}/assignments", wrapAdminMiddlewares(serverOptions, "admin_delete_visitor_assignments", handlers.AdminDeleteVisitorAssignments(context)))
+	mux.HandleFunc("POST /v2/admin/hits/flush", wrapAdminMiddlewares(serverOptions, "admin_flush_hits", handlers.AdminFlushHits(context)))
+	mux.HandleFunc("POST /v2/admin/campaigns/{campaignId}/simulation", wrapAdminMiddlewares(serverOptions, "admin_campaign_simulation", handlers.AdminSimulateCampaign(context)))
+	mux.HandleFunc("GET /v2/admin/overrides", wrapAdminMiddlewares(serverOptions, "admin_overrides", handlers.AdminOverrides(context)))
+	mux.HandleFunc("PUT /v2/admin/overrides", wrapAdminMiddlewares(serverOptions, "admin_replace_overrides", handlers.AdminReplaceOverrides(context)))
+	mux.HandleFunc("DELETE /v2/admin/overrides", wrapAdminMiddlewares(serverOptions, "admin_clear_overrides", handlers.AdminClearOverrides(context)))
+	mux.HandleFunc("PUT /v2/admin/overrides/panic", wrapAdminMiddlewares(serverOptions, "admin_panic_override", handlers.AdminSetPanicOverride(context, true)))
+	mux.HandleFunc("DELETE /v2/admin/overrides/panic", wrapAdminMiddlewares(serverOptions, "admin_panic_override", handlers.AdminSetPanicOverride(context, false)))
+	mux.HandleFunc("PUT /v2/admin/overrides/flags/{key}", wrapAdminMiddlewares(serverOptions, "admin_flag_override", handlers.AdminSetFlagOverride(context)))
+	mux.HandleFunc("DELETE /v2/admin/overrides/flags/{key}", wrapAdminMiddlewares(serverOptions, "admin_flag_override", handlers.AdminDeleteFlagOverride(context)))
+	mux.HandleFunc("PUT /v2/admin/overrides/campaigns/{campaignId}/paused", wrapAdminMiddlewares(serverOptions, "admin_paused_campaign_override", handlers.AdminSetPausedCampaignOverride(context, true)))
+	mux.HandleFunc("DELETE /v2/admin/overrides/campaigns/{campaignId}/paused", wrapAdminMiddlewares(serverOptions, "admin_paused_campaign_override", handlers.AdminSetPausedCampaignOverride(context, false)))
+	mux.HandleFunc("PUT /v2/admin/overrides/campaigns/{campaignId}/reference", wrapAdminMiddlewares(serverOptions, "admin_reference_campaign_override", handlers.AdminSetReferenceCampaignOverride(context, true)))
+	mux.HandleFunc("DELETE /v2/admin/overrides/campaigns/{campaignId}/reference", wrapAdminMiddlewares(serverOptions, "admin_reference_campaign_override", handlers.AdminSetReferenceCampaignOverride(context, false)))
+
+	return http.HandlerFunc(serverOptions.adminAuthenticator.Authenticate(mux.ServeHTTP))
+}
+
+// Shutdown drains the server: it stops accepting requests, waits for the in-flight requests, flushes the remaining hits
+// and closes the connectors. The context sets the drain timeout
 func (s *Server) Shutdown(ctx context.Context) {
 	s.options.logger.Info("shutting server down")
 	err := s.httpServer.Shutdown(ctx)
@@ -194,9 +637,52 @@ func (s *Server) Shutdown(ctx context.Context) {
 		s.options.logger.Errorf("error when shutting server down: %v", err)
 	}
 
+	if s.grpcServer != nil {
+		s.options.logger.Info("shutting gRPC server down")
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcServer.Stop()
+		}
+	}
+
 	s.options.logger.Info("cleaning remaining hits")
 	err = s.options.hitsProcessor.Shutdown(ctx)
 	if err != nil {
 		s.options.logger.Errorf("error when shutting server down: %v", err)
 	}
+
+	s.options.logger.Info("closing connectors")
+	if err := connectors.ShutdownConnector(ctx, s.options.environmentLoader); err != nil {
+		s.options.logger.Errorf("error when shutting environment loader down: %v", err)
+	}
+	if err := connectors.ShutdownConnector(ctx, s.assignmentsManager.current()); err != nil {
+		s.options.logger.Errorf("error when shutting assignments manager down: %v", err)
+	}
+	if err := connectors.ShutdownConnector(ctx, s.options.contextProvider); err != nil {
+		s.options.logger.Errorf("error when shutting context provider down: %v", err)
+	}
+	if err := connectors.ShutdownConnector(ctx, s.options.rateLimitStore); err != nil {
+		s.options.logger.Errorf("error when shutting rate limit store down: %v", err)
+	}
+	if err := s.options.overrides.Close(); err != nil {
+		s.options.logger.Errorf("error when closing overrides watcher: %v", err)
+	}
+	if err := s.tlsReloader.Close(); err != nil {
+		s.options.logger.Errorf("error when closing TLS files watcher: %v", err)
+	}
+
+	// flush the remaining spans
+	if provider, ok := s.options.tracerProvider.(interface{ Shutdown(context.Context) error }); ok {
+		s.options.logger.Info("flushing remaining spans")
+		err = provider.Shutdown(ctx)
+		if err != nil {
+			s.options.logger.Errorf("error when shutting tracer provider down: %v", err)
+		}
+	}
 }
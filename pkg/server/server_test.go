@@ -1,14 +1,23 @@
 package server
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/flagship-io/decision-api/docs"
+	"github.com/flagship-io/decision-api/internal/utils"
 	"github.com/flagship-io/decision-api/pkg/connectors/assignments_managers"
 	"github.com/flagship-io/decision-api/pkg/connectors/environment_loaders"
 	"github.com/flagship-io/decision-api/pkg/connectors/hits_processors"
+	"github.com/flagship-io/decision-api/pkg/handlers/middlewares"
+	"github.com/flagship-io/decision-api/pkg/models"
 	"github.com/flagship-io/decision-api/pkg/utils/config"
 	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -42,6 +51,33 @@ func TestCreateServer(t *testing.T) {
 	_, err = CreateServer(envID, apiKey, ":8080", WithLogger(nil))
 	assert.NotNil(t, err)
 
+	_, err = CreateServer(envID, apiKey, ":8080", WithFlagsStreamOptions(&models.FlagsStreamOptions{}))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithBatchOptions(nil))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithHTTPCacheOptions(nil))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithTLSOptions(&models.TLSOptions{CertFile: "cert.pem"}))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithContextSchemaOptions(&models.ContextSchemaOptions{Mode: "warn", Fields: []*models.ContextField{{Key: "age", Type: "number"}}}))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithContextProviderOptions(nil))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithContextProviderOptions(&models.ContextProviderOptions{Timeout: -time.Second}))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithContextEnrichmentOptions(&models.ContextEnrichmentOptions{Keys: []string{"fs_country"}}))
+	assert.NotNil(t, err)
+
+	_, err = CreateServer(envID, apiKey, ":8080", WithFallbackOptions(&models.FallbackOptions{Flags: []*models.FallbackFlag{{Value: true}}}))
+	assert.NotNil(t, err)
+
 	assignmentManager := assignments_managers.InitMemoryManager()
 	hitsProcessor := &hits_processors.MockHitProcessor{}
 	environmentLoader := &environment_loaders.MockLoader{}
@@ -60,3 +96,285 @@ func TestCreateServer(t *testing.T) {
 	assert.Equal(t, environmentLoader, server.options.environmentLoader)
 	assert.Equal(t, log, server.options.logger)
 }
+
+func TestCreateMultiEnvironmentServer(t *testing.T) {
+	_, err := CreateMultiEnvironmentServer([]models.EnvironmentCredentials{}, ":8080")
+	assert.NotNil(t, err)
+
+	environmentLoader := &environment_loaders.MockLoader{}
+	_, err = CreateMultiEnvironmentServer([]models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+		{EnvID: "env_id_1", APIKey: "api_key_2"},
+	}, ":8080", WithEnvironmentLoader(environmentLoader))
+	assert.NotNil(t, err)
+
+	server, err := CreateMultiEnvironmentServer([]models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+		{EnvID: "env_id_2", APIKey: "api_key_2"},
+	}, ":8080", WithEnvironmentLoader(environmentLoader))
+	assert.Nil(t, err)
+	assert.Len(t, server.decisionContexts, 2)
+	assert.Equal(t, "env_id_2", server.decisionContexts[1].EnvID)
+	assert.Equal(t, "api_key_2", server.decisionContexts[1].APIKey)
+	assert.Nil(t, server.grpcServer)
+
+	server, err = CreateMultiEnvironmentServer([]models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+	}, ":8080", WithEnvironmentLoader(environmentLoader), WithGRPCAddress(":9090"))
+	assert.Nil(t, err)
+	assert.NotNil(t, server.grpcServer)
+
+	server, err = CreateMultiEnvironmentServer([]models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+		{EnvID: "env_id_2", APIKey: "api_key_2"},
+	}, ":8080", WithEnvironmentLoader(environmentLoader), WithAuthOptions(&models.AuthOptions{Enabled: true}))
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v2/env_id_2/metrics", nil)
+	req.Header.Set("x-api-key", "api_key_1")
+	server.httpServer.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/v2/env_id_2/metrics", nil)
+	req.Header.Set("x-api-key", "api_key_2")
+	server.httpServer.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHealthRoutes(t *testing.T) {
+	server, err := CreateMultiEnvironmentServer([]models.EnvironmentCredentials{
+		{EnvID: "env_id_1", APIKey: "api_key_1"},
+		{EnvID: "env_id_2", APIKey: "api_key_2"},
+	}, ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{}),
+		WithAuthOptions(&models.AuthOptions{Enabled: true, ExemptedRoutes: config.ServerAuthExemptedRoutes}))
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/health/live", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/health/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "env_id_2")
+}
+
+func TestFlagRoutes(t *testing.T) {
+	server, err := CreateServer("env_id", "api_key", ":8080", WithEnvironmentLoader(utils.CreateMockDecisionContext().EnvironmentLoader))
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/flags/testString?visitor_id=visitor_id", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"testString"`)
+
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v2/flags/testString/activate", strings.NewReader(`{"visitor_id": "visitor_id", "context": {}}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"activated":true`)
+
+	// the literal /v2/flags routes take precedence over the single flag route
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/flags/batch?visitor_id=visitor_id", nil))
+	assert.NotContains(t, w.Body.String(), `"key":"batch"`)
+}
+
+func TestHTTPCacheContextEnrichmentRoutes(t *testing.T) {
+	call := func(keys ...string) *httptest.ResponseRecorder {
+		server, err := CreateServer("env_id", "api_key", ":8080",
+			WithEnvironmentLoader(utils.CreateMockDecisionContext().EnvironmentLoader),
+			WithHTTPCacheOptions(&models.HTTPCacheOptions{MaxAge: time.Minute}),
+			WithContextEnrichmentOptions(&models.ContextEnrichmentOptions{Keys: keys}))
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v2/flags?visitor_id=visitor_id&trigger_hit=false&sendContextEvent=false", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+		server.httpServer.Handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	// the cached decisions of the enriched devices and languages vary with their headers
+	w := call(models.ContextKeyDeviceType, models.ContextKeyLanguage)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Header().Get("Vary"), "User-Agent, Accept-Language")
+
+	// the decisions of the enriched IP and hour are not cached
+	w = call(models.ContextKeyIP, models.ContextKeyHour)
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
+
+func TestBucketingRoutes(t *testing.T) {
+	server, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{}),
+		WithAuthOptions(&models.AuthOptions{Enabled: true, ExemptedRoutes: config.ServerAuthExemptedRoutes}))
+	assert.Nil(t, err)
+
+	// the SDKs fetch the bucketing file without API key
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/env_id/bucketing.json", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/flags?visitor_id=visitor_id", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestMetricsRoutes(t *testing.T) {
+	server, err := CreateServer("env_id", "api_key", ":8080", WithEnvironmentLoader(&environment_loaders.MockLoader{}))
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"handlers.campaigns.errors": 0`)
+
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/metrics/prometheus", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `decision_api_request_duration_seconds_count{code="200",handler="metrics"} 1`)
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	server, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{}),
+		WithRateLimitOptions(&models.RateLimitOptions{
+			Enabled: true,
+			Routes: map[string]*models.RouteRateLimits{
+				"metrics": {IP: &models.RateLimit{Rate: 1, Burst: 1}},
+			},
+		}))
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/metrics", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+type shutdownLoader struct {
+	environment_loaders.MockLoader
+	shutdown bool
+}
+
+func (l *shutdownLoader) Shutdown(ctx context.Context) error {
+	l.shutdown = true
+	return nil
+}
+
+type closeAssignmentsManager struct {
+	*assignments_managers.MemoryManager
+	closed bool
+}
+
+func (m *closeAssignmentsManager) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	loader := &shutdownLoader{}
+	manager := &closeAssignmentsManager{MemoryManager: assignments_managers.InitMemoryManager()}
+	server, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(loader),
+		WithAssignmentsManager(manager))
+	assert.Nil(t, err)
+
+	// the loader does not notify environment changes
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/flags/stream?visitor_id=1234", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	assert.True(t, loader.shutdown)
+	assert.True(t, manager.closed)
+}
+
+func TestReload(t *testing.T) {
+	manager := &closeAssignmentsManager{MemoryManager: assignments_managers.InitMemoryManager()}
+	log := logger.New("warning", logger.FORMAT_TEXT, "test")
+	server, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{}),
+		WithAssignmentsManager(manager),
+		WithLogger(log))
+	assert.Nil(t, err)
+
+	err = server.Reload(context.Background(), &models.ReloadOptions{
+		LogLevel:    "debug",
+		CorsOptions: &models.CorsOptions{Enabled: true, AllowedOrigins: "https://example.com"},
+	}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, logrus.DebugLevel, log.Logger.Level)
+
+	w := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/v2/campaigns", nil))
+	assert.Equal(t, "https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// rejected options leave the server untouched and shut the new assignments manager down
+	rejectedManager := &closeAssignmentsManager{MemoryManager: assignments_managers.InitMemoryManager()}
+	err = server.Reload(context.Background(), &models.ReloadOptions{
+		LogLevel:    "unknown",
+		CorsOptions: &models.CorsOptions{Enabled: true, AllowedOrigins: "https://other.com"},
+	}, rejectedManager)
+	assert.NotNil(t, err)
+	assert.True(t, rejectedManager.closed)
+	assert.False(t, manager.closed)
+	assert.Equal(t, manager, server.assignmentsManager.current())
+	assert.Equal(t, "https://example.com", server.options.runningCorsOptions.Load().AllowedOrigins)
+	assert.NotNil(t, server.Reload(context.Background(), &models.ReloadOptions{LogFormat: "xml"}, nil))
+	assert.NotNil(t, server.Reload(context.Background(), nil, nil))
+
+	newManager := assignments_managers.InitMemoryManager()
+	err = server.Reload(context.Background(), &models.ReloadOptions{}, newManager)
+	assert.Nil(t, err)
+	assert.True(t, manager.closed)
+	assert.Equal(t, newManager, server.assignmentsManager.current())
+}
+
+func TestAdminRoutes(t *testing.T) {
+	_, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{}),
+		WithAdminOptions(&models.AdminOptions{Enabled: true}))
+	assert.NotNil(t, err)
+
+	server, err := CreateServer("env_id", "api_key", ":8080",
+		WithEnvironmentLoader(&environment_loaders.MockLoader{MockedEnvironment: &models.Environment{}}),
+		WithAssignmentsManager(assignments_managers.InitMemoryManager()),
+		WithAuthOptions(&models.AuthOptions{Enabled: true}),
+		WithAdminOptions(&models.AdminOptions{Enabled: true, Token: "admin_token"}))
+	assert.Nil(t, err)
+
+	call := func(method string, path string, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set(middlewares.AdminTokenHeader, token)
+		}
+		server.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/v2/admin/environment", "").Code)
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/v2/admin/environment", "api_key").Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/v2/admin/environment", "admin_token").Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/v2/env_id/admin/environment", "admin_token").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, call(http.MethodPost, "/v2/admin/environment", "admin_token").Code)
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/v2/admin/visitors/visitor_id/assignments", "admin_token").Code)
+	assert.Equal(t, http.StatusNoContent, call(http.MethodDelete, "/v2/admin/visitors/visitor_id/assignments", "admin_token").Code)
+	assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/v2/admin/campaigns/campaign_id/simulation", "admin_token").Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodPut, "/v2/admin/overrides/panic", "admin_token").Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodDelete, "/v2/admin/overrides", "admin_token").Code)
+
+	// the admin token does not give access to the decision API
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/v2/campaigns", "admin_token").Code)
+}
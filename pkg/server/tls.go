@@ -0,0 +1,215 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsReloader builds the TLS configuration from the certificate files and rebuilds it
+// when one of the files is modified on disk. The directories of the files are watched, as
+// certificates are often rotated by replacing a symlink
+type tlsReloader struct {
+	options    *models.TLSOptions
+	minVersion uint16
+	logger     *logger.Logger
+	lock       *sync.Mutex
+	modTimes   map[string]time.Time
+	config     *tls.Config
+	watcher    *fsnotify.Watcher
+}
+
+func newTLSReloader(options *models.TLSOptions, logger *logger.Logger) (*tlsReloader, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if options.MinVersion != "" {
+		v, ok := tlsVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min version %s", options.MinVersion)
+		}
+		minVersion = v
+	}
+
+	r := &tlsReloader{
+		options:    options,
+		minVersion: minVersion,
+		logger:     logger,
+		lock:       &sync.Mutex{},
+		modTimes:   map[string]time.Time{},
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	if err := r.watch(); err != nil {
+		return nil, fmt.Errorf("error when watching TLS files: %v", err)
+	}
+	return r, nil
+}
+
+// watch reloads the configuration when the directory of one of the TLS files changes
+func (r *tlsReloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, f := range r.files() {
+		if err := watcher.Add(filepath.Dir(f)); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	r.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if err := r.reload(); err != nil {
+					r.logger.Errorf("error when reloading TLS configuration, keeping the previous one: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Errorf("error when watching TLS files: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the TLS files
+func (r *tlsReloader) Close() error {
+	if r == nil || r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.options.CertFile, r.options.KeyFile}
+	if r.options.ClientCAFile != "" {
+		files = append(files, r.options.ClientCAFile)
+	}
+	return files
+}
+
+// hasChanged returns true if one of the TLS files modification time changed since last load
+func (r *tlsReloader) hasChanged() (bool, map[string]time.Time, error) {
+	changed := false
+	modTimes := map[string]time.Time{}
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, nil, err
+		}
+		modTimes[f] = info.ModTime()
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			changed = true
+		}
+	}
+	return changed, modTimes, nil
+}
+
+func (r *tlsReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.options.CertFile, r.options.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error when loading TLS certificate: %v", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   r.minVersion,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.options.ClientCAFile != "" {
+		data, err := os.ReadFile(r.options.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error when reading TLS client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no valid certificate found in TLS client CA file")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// reload rebuilds the TLS configuration if the files changed. If the reload fails, the previous configuration is kept
+func (r *tlsReloader) reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	changed, modTimes, err := r.hasChanged()
+	if err != nil || !changed {
+		return err
+	}
+	config, err := r.load()
+	if err != nil {
+		return err
+	}
+	if r.config != nil {
+		r.logger.Info("TLS certificates changed, configuration reloaded")
+	}
+	r.config = config
+	r.modTimes = modTimes
+	return nil
+}
+
+// getConfig returns the current TLS configuration
+func (r *tlsReloader) getConfig() (*tls.Config, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.config == nil {
+		return nil, errors.New("no TLS configuration loaded")
+	}
+	return r.config, nil
+}
+
+// tlsConfig returns the server TLS config, which resolves the configuration on each client handshake
+// with the given application protocols
+func (r *tlsReloader) tlsConfig(nextProtos ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := r.getConfig()
+			if err != nil {
+				return nil, err
+			}
+			config = config.Clone()
+			config.NextProtos = nextProtos
+			return config, nil
+		},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			config, err := r.getConfig()
+			if err != nil {
+				return nil, err
+			}
+			return &config.Certificates[0], nil
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/flagship-io/decision-api/pkg/utils/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCertificate(t *testing.T, certFile string, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.Nil(t, err)
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	assert.Nil(t, err)
+}
+
+func TestTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	log := logger.New("debug", logger.FORMAT_TEXT, "test")
+
+	_, err := newTLSReloader(&models.TLSOptions{CertFile: certFile, KeyFile: keyFile}, log)
+	assert.NotNil(t, err)
+
+	writeTestCertificate(t, certFile, keyFile, 1)
+
+	_, err = newTLSReloader(&models.TLSOptions{CertFile: certFile, KeyFile: keyFile, MinVersion: "0.9"}, log)
+	assert.NotNil(t, err)
+
+	reloader, err := newTLSReloader(&models.TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, MinVersion: "1.3"}, log)
+	assert.Nil(t, err)
+	defer reloader.Close()
+
+	config, err := reloader.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), cert.SerialNumber.Int64())
+
+	serial := func() int64 {
+		tlsCert, err := reloader.tlsConfig().GetCertificate(&tls.ClientHelloInfo{})
+		assert.Nil(t, err)
+		cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		assert.Nil(t, err)
+		return cert.SerialNumber.Int64()
+	}
+
+	// rotating the certificate is picked up by the files watcher
+	writeTestCertificate(t, certFile, keyFile, 2)
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		assert.Nil(t, os.Chtimes(f, future, future))
+	}
+	assert.Eventually(t, func() bool { return serial() == 2 }, time.Second, 10*time.Millisecond)
+
+	// invalid certificate keeps the previous configuration
+	assert.Nil(t, os.WriteFile(certFile, []byte("invalid"), 0600))
+	future = future.Add(time.Minute)
+	assert.Nil(t, os.Chtimes(certFile, future, future))
+	assert.NotNil(t, reloader.reload())
+	assert.Equal(t, int64(2), serial())
+}
@@ -20,9 +20,40 @@ func NewFromFilename(name string) (*Config, error) {
 	v.SetDefault("cors.enabled", ServerCorsEnabled)
 	v.SetDefault("cors.allowed_origins", ServerCorsAllowedOrigins)
 	v.SetDefault("cors.allowed_headers", ServerCorsAllowedHeaders)
+	v.SetDefault("auth.enabled", ServerAuthEnabled)
+	v.SetDefault("auth.exempted_routes", ServerAuthExemptedRoutes)
+	v.SetDefault("drain_timeout", ServerDrainTimeout)
+	v.SetDefault("health.staleness_threshold", HealthStalenessThreshold)
+	v.SetDefault("health.timeout", HealthTimeout)
+	v.SetDefault("health.require_all_environments", HealthRequireAllEnvs)
+	v.SetDefault("rate_limit.enabled", RateLimitEnabled)
+	v.SetDefault("rate_limit.store", RateLimitStore)
+	v.SetDefault("admin.enabled", AdminEnabled)
+	v.SetDefault("flags_stream.max_subscribers", FlagsStreamMaxSubscribers)
+	v.SetDefault("flags_stream.heartbeat_interval", FlagsStreamHeartbeatInterval)
+	v.SetDefault("batch.max_size", BatchMaxSize)
+	v.SetDefault("batch.concurrency", BatchConcurrency)
+	v.SetDefault("http_cache.max_age", HTTPCacheMaxAge)
+	v.SetDefault("forced_variations.max_lifetime", ForcedVariationsMaxLifetime)
+	v.SetDefault("context_schema.mode", ContextSchemaMode)
+	v.SetDefault("context_enrichment.timezone", ContextEnrichmentTimezone)
+	v.SetDefault("context_enrichment.trust_forwarded_for", ContextEnrichmentTrustForwardedFor)
+	v.SetDefault("context_provider.cache_ttl", ContextProviderCacheTTL)
+	v.SetDefault("context_provider.timeout", ContextProviderTimeout)
+	v.SetDefault("context_provider.circuit_breaker.threshold", ContextProviderCircuitBreakerThreshold)
+	v.SetDefault("context_provider.circuit_breaker.cooldown", ContextProviderCircuitBreakerCooldown)
+	v.SetDefault("tracing.enabled", TracingEnabled)
+	v.SetDefault("tracing.exporter", TracingExporter)
+	v.SetDefault("tracing.service_name", TracingServiceName)
+	v.SetDefault("tracing.sample_ratio", TracingSampleRatio)
+	v.SetDefault("grpc.enabled", GRPCEnabled)
+	v.SetDefault("grpc.address", GRPCAddress)
 	v.SetDefault("log.level", LoggerLevel)
 	v.SetDefault("log.format", LoggerFormat)
 	v.SetDefault("polling_interval", CDNLoaderPollingInterval)
+	v.SetDefault("hits.batch_size", HitsBatchSize)
+	v.SetDefault("hits.batching_window", HitsBatchingWindow)
+	v.SetDefault("watch_config", ConfigWatchEnabled)
 	v.SetDefault("cache.options.redisHost", RedisAddr)
 
 	// replace dot in key name by underscore
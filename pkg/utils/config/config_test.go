@@ -16,6 +16,37 @@ func TestNewFromFilename(t *testing.T) {
 	assert.Equal(t, cfg.GetBool("cors.enabled"), ServerCorsEnabled)
 	assert.Equal(t, cfg.GetString("cors.allowed_origins"), ServerCorsAllowedOrigins)
 	assert.Equal(t, cfg.GetString("cors.allowed_headers"), ServerCorsAllowedHeaders)
+	assert.Equal(t, cfg.GetBool("auth.enabled"), ServerAuthEnabled)
+	assert.Equal(t, cfg.GetStringSlice("auth.exempted_routes"), ServerAuthExemptedRoutes)
+	assert.Equal(t, cfg.GetDuration("health.staleness_threshold"), HealthStalenessThreshold)
+	assert.Equal(t, cfg.GetDuration("health.timeout"), HealthTimeout)
+	assert.Equal(t, cfg.GetBool("health.require_all_environments"), HealthRequireAllEnvs)
+	assert.Equal(t, cfg.GetDuration("drain_timeout"), ServerDrainTimeout)
+	assert.Equal(t, cfg.GetBool("admin.enabled"), AdminEnabled)
+	assert.Equal(t, cfg.GetInt("flags_stream.max_subscribers"), FlagsStreamMaxSubscribers)
+	assert.Equal(t, cfg.GetDuration("flags_stream.heartbeat_interval"), FlagsStreamHeartbeatInterval)
+	assert.Equal(t, cfg.GetInt("batch.max_size"), BatchMaxSize)
+	assert.Equal(t, cfg.GetInt("batch.concurrency"), BatchConcurrency)
+	assert.Equal(t, cfg.GetDuration("http_cache.max_age"), HTTPCacheMaxAge)
+	assert.Equal(t, cfg.GetDuration("forced_variations.max_lifetime"), ForcedVariationsMaxLifetime)
+	assert.Equal(t, cfg.GetString("context_schema.mode"), ContextSchemaMode)
+	assert.Equal(t, cfg.GetString("context_enrichment.timezone"), ContextEnrichmentTimezone)
+	assert.Equal(t, cfg.GetBool("context_enrichment.trust_forwarded_for"), ContextEnrichmentTrustForwardedFor)
+	assert.Equal(t, cfg.GetDuration("context_provider.cache_ttl"), ContextProviderCacheTTL)
+	assert.Equal(t, cfg.GetDuration("context_provider.timeout"), ContextProviderTimeout)
+	assert.Equal(t, cfg.GetInt("context_provider.circuit_breaker.threshold"), ContextProviderCircuitBreakerThreshold)
+	assert.Equal(t, cfg.GetDuration("context_provider.circuit_breaker.cooldown"), ContextProviderCircuitBreakerCooldown)
+	assert.Equal(t, cfg.GetInt("hits.batch_size"), HitsBatchSize)
+	assert.Equal(t, cfg.GetDuration("hits.batching_window"), HitsBatchingWindow)
+	assert.Equal(t, cfg.GetBool("watch_config"), ConfigWatchEnabled)
+	assert.Equal(t, cfg.GetBool("rate_limit.enabled"), RateLimitEnabled)
+	assert.Equal(t, cfg.GetString("rate_limit.store"), RateLimitStore)
+	assert.Equal(t, cfg.GetBool("tracing.enabled"), TracingEnabled)
+	assert.Equal(t, cfg.GetString("tracing.exporter"), TracingExporter)
+	assert.Equal(t, cfg.GetString("tracing.service_name"), TracingServiceName)
+	assert.Equal(t, cfg.GetFloat64("tracing.sample_ratio"), TracingSampleRatio)
+	assert.Equal(t, cfg.GetBool("grpc.enabled"), GRPCEnabled)
+	assert.Equal(t, cfg.GetString("grpc.address"), GRPCAddress)
 	assert.Equal(t, cfg.GetString("log.level"), LoggerLevel)
 	assert.Equal(t, cfg.GetString("log.format"), LoggerFormat)
 	assert.Equal(t, cfg.GetDuration("polling_interval"), CDNLoaderPollingInterval)
@@ -6,11 +6,54 @@ const (
 	ServerAddress            = ":8080"
 	ServerCorsEnabled        = true
 	ServerCorsAllowedOrigins = "*"
-	ServerCorsAllowedHeaders = "Content-Type,Authorization,X-Api-Key,X-Sdk-Client,X-Sdk-Version,X-Pop"
+	ServerCorsAllowedHeaders = "Content-Type,Authorization,X-Api-Key,X-Env-Id,X-Sdk-Client,X-Sdk-Version,X-Pop,X-Forced-Variations"
+	ServerAuthEnabled        = false
+	ServerDrainTimeout       = 3 * time.Second
+	TLSMinVersion            = "1.2"
+	HealthStalenessThreshold = 5 * time.Minute
+	HealthTimeout            = 2 * time.Second
+	HealthRequireAllEnvs     = false
+	RateLimitEnabled         = false
+	AdminEnabled             = false
+	RateLimitStore           = "memory"
+	TracingEnabled           = false
+	TracingExporter          = "otlp"
+	TracingServiceName       = "decision-api"
+	TracingSampleRatio       = 1.0
+	GRPCEnabled              = false
+	GRPCAddress              = ":9090"
 	LoggerLevel              = "warning"
 	LoggerFormat             = "text"
 
 	CDNLoaderPollingInterval = time.Minute * 1
 
+	FlagsStreamMaxSubscribers    = 1000
+	FlagsStreamHeartbeatInterval = 15 * time.Second
+
+	BatchMaxSize     = 1000
+	BatchConcurrency = 10
+
+	HTTPCacheMaxAge = 0 * time.Second
+
+	ForcedVariationsMaxLifetime = 24 * time.Hour
+
+	ContextSchemaMode = "coerce"
+
+	ContextEnrichmentTimezone          = "UTC"
+	ContextEnrichmentTrustForwardedFor = true
+
+	ContextProviderCacheTTL                = 1 * time.Minute
+	ContextProviderTimeout                 = 1 * time.Second
+	ContextProviderCircuitBreakerThreshold = 5
+	ContextProviderCircuitBreakerCooldown  = 30 * time.Second
+
+	HitsBatchSize      = 50
+	HitsBatchingWindow = 30 * time.Second
+
+	ConfigWatchEnabled = false
+
 	RedisAddr = "localhost:6379"
 )
+
+// ServerAuthExemptedRoutes are the routes served without API key. The SDKs in bucketing mode fetch the bucketing file without key
+var ServerAuthExemptedRoutes = []string{"/v2/swagger/", "/v2/metrics", "/v2/metrics/prometheus", "/v2/health/", "/v2/bucketing"}
@@ -0,0 +1,275 @@
+package contextschema
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The reasons of the context schema violations
+const (
+	ReasonRequired = "required"
+	ReasonType     = "type"
+	ReasonEnum     = "enum"
+	ReasonRange    = "range"
+	ReasonUnknown  = "unknown"
+)
+
+// unknownKey is the key of the violations counts of the unknown keys that are not variants of schema keys
+const unknownKey = "_unknown"
+
+// reservedPrefix is the prefix of the context keys set by the SDKs and the API, never reported as unknown
+const reservedPrefix = "fs_"
+
+// Violation is a visitor context key violating the context schema
+type Violation struct {
+	Key     string
+	Reason  string
+	Message string
+}
+
+// ViolationCount is the number of violations of a context key for a reason
+type ViolationCount struct {
+	Key    string
+	Reason string
+	Count  uint64
+}
+
+type violationCountKey struct {
+	key    string
+	reason string
+}
+
+// Validator checks the visitor contexts against a context schema, and counts the violations by key and reason
+type Validator struct {
+	options *models.ContextSchemaOptions
+	fields  map[string]*models.ContextField
+	// lowerKeys maps the lower case keys of the schema to the schema keys, to detect case typos
+	lowerKeys  map[string]string
+	lock       sync.Mutex
+	violations map[violationCountKey]uint64
+}
+
+// NewValidator returns a validator of the context schema, or an error if the schema is invalid
+func NewValidator(options *models.ContextSchemaOptions) (*Validator, error) {
+	if options.Mode != models.ContextSchemaModeReject && options.Mode != models.ContextSchemaModeCoerce {
+		return nil, fmt.Errorf("unknown context schema mode %s", options.Mode)
+	}
+	v := &Validator{
+		options:    options,
+		fields:     map[string]*models.ContextField{},
+		lowerKeys:  map[string]string{},
+		violations: map[violationCountKey]uint64{},
+	}
+	for _, field := range options.Fields {
+		if field.Key == "" {
+			return nil, fmt.Errorf("missing context schema field key")
+		}
+		if !slices.Contains([]string{"string", "number", "boolean"}, field.Type) {
+			return nil, fmt.Errorf("unknown type %s of context schema field %s", field.Type, field.Key)
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return nil, fmt.Errorf("min is greater than max for context schema field %s", field.Key)
+		}
+		v.fields[field.Key] = field
+		v.lowerKeys[strings.ToLower(field.Key)] = field.Key
+	}
+	return v, nil
+}
+
+// Reject returns true if the decision requests violating the schema are rejected
+func (v *Validator) Reject() bool {
+	return v.options.Mode == models.ContextSchemaModeReject
+}
+
+// Validate checks the visitor context against the schema and returns the violations.
+// In coerce mode, the returned context holds the values converted to the schema types, without the invalid values,
+// and the unknown keys differing from a schema key by case are renamed. Otherwise the context is returned unchanged
+func (v *Validator) Validate(context map[string]*structpb.Value) (map[string]*structpb.Value, []*Violation) {
+	coerce := !v.Reject()
+	result := context
+	if coerce {
+		result = make(map[string]*structpb.Value, len(context))
+		for key, value := range context {
+			result[key] = value
+		}
+	}
+
+	violations := []*Violation{}
+	for _, key := range sortedKeys(context) {
+		value := context[key]
+		field, ok := v.fields[key]
+		if !ok {
+			violation := v.checkUnknownKey(key)
+			if violation == nil {
+				continue
+			}
+			violations = append(violations, violation)
+			schemaKey, isVariant := v.lowerKeys[strings.ToLower(key)]
+			if _, exists := context[schemaKey]; !coerce || !isVariant || exists {
+				continue
+			}
+			delete(result, key)
+			key, field = schemaKey, v.fields[schemaKey]
+		}
+
+		checked, violation := checkValue(field, value, coerce)
+		if violation != nil {
+			violations = append(violations, violation)
+		}
+		if coerce {
+			if checked == nil {
+				delete(result, key)
+			} else {
+				result[key] = checked
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(v.fields) {
+		if _, ok := result[key]; !ok && v.fields[key].Required {
+			violations = append(violations, &Violation{Key: key, Reason: ReasonRequired, Message: "Field is mandatory."})
+		}
+	}
+
+	v.count(violations)
+	return result, violations
+}
+
+// Violations returns the number of violations by key and reason since the validator creation
+func (v *Validator) Violations() []*ViolationCount {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	counts := []*ViolationCount{}
+	for key, count := range v.violations {
+		counts = append(counts, &ViolationCount{Key: key.key, Reason: key.reason, Count: count})
+	}
+	return counts
+}
+
+func (v *Validator) count(violations []*Violation) {
+	if len(violations) == 0 {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	for _, violation := range violations {
+		key := violation.Key
+		// the unknown keys are not bounded, so they are counted together
+		if _, ok := v.lowerKeys[strings.ToLower(key)]; !ok {
+			key = unknownKey
+		}
+		v.violations[violationCountKey{key: key, reason: violation.Reason}]++
+	}
+}
+
+// checkUnknownKey returns the violation of a key missing from the schema, if it differs from a schema key by case
+// or if the schema is strict
+func (v *Validator) checkUnknownKey(key string) *Violation {
+	if strings.HasPrefix(key, reservedPrefix) {
+		return nil
+	}
+	if schemaKey, ok := v.lowerKeys[strings.ToLower(key)]; ok {
+		return &Violation{Key: key, Reason: ReasonUnknown, Message: fmt.Sprintf("Unknown key, did you mean %s?", schemaKey)}
+	}
+	if v.options.Strict {
+		return &Violation{Key: key, Reason: ReasonUnknown, Message: "Unknown key."}
+	}
+	return nil
+}
+
+// checkValue checks the value of a schema field. In coerce mode, it returns the value converted to the field type,
+// or nil if the value is invalid
+func checkValue(field *models.ContextField, value *structpb.Value, coerce bool) (*structpb.Value, *Violation) {
+	var violation *Violation
+	if !hasType(value, field.Type) {
+		violation = &Violation{Key: field.Key, Reason: ReasonType, Message: fmt.Sprintf("Expected a %s.", field.Type)}
+		if !coerce {
+			return value, violation
+		}
+		value = convert(value, field.Type)
+		if value == nil {
+			return nil, violation
+		}
+	}
+
+	if len(field.Enum) > 0 && !slices.ContainsFunc(field.Enum, func(allowed interface{}) bool {
+		allowedValue, err := structpb.NewValue(allowed)
+		return err == nil && proto.Equal(allowedValue, value)
+	}) {
+		return nil, &Violation{Key: field.Key, Reason: ReasonEnum, Message: fmt.Sprintf("Value must be one of %v.", field.Enum)}
+	}
+
+	if field.Type == "number" {
+		number := value.GetNumberValue()
+		if (field.Min != nil && number < *field.Min) || (field.Max != nil && number > *field.Max) {
+			return nil, &Violation{Key: field.Key, Reason: ReasonRange, Message: rangeMessage(field)}
+		}
+	}
+	return value, violation
+}
+
+func hasType(value *structpb.Value, fieldType string) bool {
+	switch value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return fieldType == "string"
+	case *structpb.Value_NumberValue:
+		return fieldType == "number"
+	case *structpb.Value_BoolValue:
+		return fieldType == "boolean"
+	}
+	return false
+}
+
+// convert returns the value converted to the type, or nil if it can not be converted
+func convert(value *structpb.Value, fieldType string) *structpb.Value {
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		switch fieldType {
+		case "number":
+			if number, err := strconv.ParseFloat(strings.TrimSpace(kind.StringValue), 64); err == nil {
+				return structpb.NewNumberValue(number)
+			}
+		case "boolean":
+			if boolean, err := strconv.ParseBool(strings.TrimSpace(kind.StringValue)); err == nil {
+				return structpb.NewBoolValue(boolean)
+			}
+		}
+	case *structpb.Value_NumberValue:
+		if fieldType == "string" {
+			return structpb.NewStringValue(strconv.FormatFloat(kind.NumberValue, 'f', -1, 64))
+		}
+	case *structpb.Value_BoolValue:
+		if fieldType == "string" {
+			return structpb.NewStringValue(strconv.FormatBool(kind.BoolValue))
+		}
+	}
+	return nil
+}
+
+func rangeMessage(field *models.ContextField) string {
+	switch {
+	case field.Min != nil && field.Max != nil:
+		return fmt.Sprintf("Value must be between %v and %v.", *field.Min, *field.Max)
+	case field.Min != nil:
+		return fmt.Sprintf("Value must be greater than or equal to %v.", *field.Min)
+	default:
+		return fmt.Sprintf("Value must be less than or equal to %v.", *field.Max)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
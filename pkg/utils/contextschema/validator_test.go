@@ -0,0 +1,110 @@
+package contextschema
+
+import (
+	"testing"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newSchema(mode string) *models.ContextSchemaOptions {
+	min, max := 0., 120.
+	return &models.ContextSchemaOptions{
+		Mode: mode,
+		Fields: []*models.ContextField{
+			{Key: "age", Type: "number", Min: &min, Max: &max},
+			{Key: "plan", Type: "string", Enum: []interface{}{"free", "premium"}, Required: true},
+			{Key: "vip", Type: "boolean"},
+		},
+	}
+}
+
+func newContext(values map[string]interface{}) map[string]*structpb.Value {
+	context, _ := structpb.NewStruct(values)
+	return context.GetFields()
+}
+
+func asMap(context map[string]*structpb.Value) map[string]interface{} {
+	return (&structpb.Struct{Fields: context}).AsMap()
+}
+
+func TestNewValidator(t *testing.T) {
+	_, err := NewValidator(newSchema(models.ContextSchemaModeReject))
+	assert.Nil(t, err)
+
+	_, err = NewValidator(newSchema("warn"))
+	assert.NotNil(t, err)
+
+	schema := newSchema(models.ContextSchemaModeCoerce)
+	schema.Fields[0].Type = "integer"
+	_, err = NewValidator(schema)
+	assert.NotNil(t, err)
+
+	schema = newSchema(models.ContextSchemaModeCoerce)
+	schema.Fields[0].Min, schema.Fields[0].Max = schema.Fields[0].Max, schema.Fields[0].Min
+	_, err = NewValidator(schema)
+	assert.NotNil(t, err)
+}
+
+func TestValidateReject(t *testing.T) {
+	v, err := NewValidator(newSchema(models.ContextSchemaModeReject))
+	assert.Nil(t, err)
+	assert.True(t, v.Reject())
+
+	context := newContext(map[string]interface{}{"age": 30, "plan": "free", "vip": true, "other": 1, "fs_client": "go"})
+	result, violations := v.Validate(context)
+	assert.Empty(t, violations)
+	assert.Equal(t, context, result)
+
+	context = newContext(map[string]interface{}{"Age": 30, "vip": "true"})
+	result, violations = v.Validate(context)
+	assert.Equal(t, context, result)
+	assert.Equal(t, []*Violation{
+		{Key: "Age", Reason: ReasonUnknown, Message: "Unknown key, did you mean age?"},
+		{Key: "vip", Reason: ReasonType, Message: "Expected a boolean."},
+		{Key: "plan", Reason: ReasonRequired, Message: "Field is mandatory."},
+	}, violations)
+
+	_, violations = v.Validate(newContext(map[string]interface{}{"age": 130, "plan": "gold"}))
+	assert.Equal(t, []*Violation{
+		{Key: "age", Reason: ReasonRange, Message: "Value must be between 0 and 120."},
+		{Key: "plan", Reason: ReasonEnum, Message: "Value must be one of [free premium]."},
+	}, violations)
+
+	assert.ElementsMatch(t, []*ViolationCount{
+		{Key: "Age", Reason: ReasonUnknown, Count: 1},
+		{Key: "vip", Reason: ReasonType, Count: 1},
+		{Key: "plan", Reason: ReasonRequired, Count: 1},
+		{Key: "age", Reason: ReasonRange, Count: 1},
+		{Key: "plan", Reason: ReasonEnum, Count: 1},
+	}, v.Violations())
+}
+
+func TestValidateCoerce(t *testing.T) {
+	schema := newSchema(models.ContextSchemaModeCoerce)
+	schema.Strict = true
+	v, err := NewValidator(schema)
+	assert.Nil(t, err)
+	assert.False(t, v.Reject())
+
+	context := newContext(map[string]interface{}{"Age": "30", "plan": "gold", "vip": "yes", "other": 1, "fs_client": "go"})
+	result, violations := v.Validate(context)
+	assert.Equal(t, map[string]interface{}{"age": 30., "other": 1., "fs_client": "go"}, asMap(result))
+	assert.Len(t, violations, 6)
+	// the context is not modified
+	assert.Contains(t, context, "Age")
+
+	result, _ = v.Validate(newContext(map[string]interface{}{"age": "abc", "plan": "premium", "vip": "true"}))
+	assert.Equal(t, map[string]interface{}{"plan": "premium", "vip": true}, asMap(result))
+
+	result, _ = v.Validate(newContext(map[string]interface{}{"plan": 1.5}))
+	assert.Empty(t, result)
+
+	counts := map[string]uint64{}
+	for _, count := range v.Violations() {
+		counts[count.Key+"/"+count.Reason] = count.Count
+	}
+	assert.Equal(t, uint64(1), counts["_unknown/unknown"])
+	assert.Equal(t, uint64(2), counts["plan/enum"])
+}
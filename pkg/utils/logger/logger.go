@@ -16,16 +16,19 @@ type Logger struct {
 	*logrus.Entry
 }
 
+func newFormatter(fmt LogFormat) logrus.Formatter {
+	if fmt == FORMAT_JSON {
+		return &logrus.JSONFormatter{}
+	}
+	return &logrus.TextFormatter{
+		FullTimestamp: true,
+	}
+}
+
 func New(lvl string, fmt LogFormat, component string) *Logger {
 	l := logrus.New()
 
-	if fmt == FORMAT_JSON {
-		l.SetFormatter(&logrus.JSONFormatter{})
-	} else {
-		l.SetFormatter(&logrus.TextFormatter{
-			FullTimestamp: true,
-		})
-	}
+	l.SetFormatter(newFormatter(fmt))
 	l.SetOutput(os.Stderr)
 
 	l.SetLevel(logrus.WarnLevel)
@@ -40,3 +43,14 @@ func New(lvl string, fmt LogFormat, component string) *Logger {
 
 	return &Logger{entry}
 }
+
+// Configure changes the level and format of the logger while it is in use
+func (l *Logger) Configure(lvl string, fmt LogFormat) error {
+	parsedLvl, err := logrus.ParseLevel(lvl)
+	if err != nil {
+		return err
+	}
+	l.Logger.SetFormatter(newFormatter(fmt))
+	l.Logger.SetLevel(parsedLvl)
+	return nil
+}
@@ -0,0 +1,102 @@
+package qa
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors of the forced variations tokens verification
+var (
+	ErrForcedVariationsDisabled = errors.New("forced variations are not enabled")
+	ErrInvalidToken             = errors.New("forced variations token is invalid")
+	ErrInvalidSignature         = errors.New("forced variations token signature is invalid")
+	ErrExpiredToken             = errors.New("forced variations token is expired")
+	ErrMissingExpiration        = errors.New("forced variations token has no expiration")
+	ErrLifetimeExceeded         = errors.New("forced variations token expires after the maximum lifetime")
+	ErrMissingVisitor           = errors.New("forced variations token is not restricted to a visitor")
+	ErrVisitorMismatch          = errors.New("forced variations token is not valid for this visitor")
+)
+
+// DefaultMaxLifetime is the maximum lifetime of the tokens if none is set
+const DefaultMaxLifetime = 24 * time.Hour
+
+// ForcedVariations are the variations forced to a QA tester, by campaign ID
+type ForcedVariations struct {
+	// Variations maps the campaign IDs to the forced variation IDs
+	Variations map[string]string `json:"variations"`
+	// VisitorID restricts the token to a visitor
+	VisitorID string `json:"visitor_id,omitempty"`
+	// AllVisitors applies the token to every visitor. Either VisitorID or AllVisitors must be set
+	AllVisitors bool `json:"all_visitors,omitempty"`
+	// ExpiresAt is the unix time in seconds after which the token is rejected
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// SignForcedVariations returns the token of the forced variations, as the base64url encoded JSON payload
+// and its base64url encoded HMAC-SHA256 signature with the secret, separated by a dot
+func SignForcedVariations(secret string, forcedVariations *ForcedVariations) (string, error) {
+	if secret == "" {
+		return "", ErrForcedVariationsDisabled
+	}
+	data, err := json.Marshal(forcedVariations)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(secret, payload)), nil
+}
+
+// VerifyForcedVariations checks the signature of the token with the secret, its expiration and its visitor ID,
+// and returns the forced variations of the token. The token must expire, at most maxLifetime after now,
+// and be restricted to a visitor unless it explicitly applies to all visitors
+func VerifyForcedVariations(secret string, token string, visitorID string, now time.Time, maxLifetime time.Duration) (*ForcedVariations, error) {
+	if secret == "" {
+		return nil, ErrForcedVariationsDisabled
+	}
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, sign(secret, payload)) {
+		return nil, ErrInvalidSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	forcedVariations := &ForcedVariations{}
+	if err := json.Unmarshal(data, forcedVariations); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if maxLifetime <= 0 {
+		maxLifetime = DefaultMaxLifetime
+	}
+	switch {
+	case forcedVariations.ExpiresAt <= 0:
+		return nil, ErrMissingExpiration
+	case now.Unix() >= forcedVariations.ExpiresAt:
+		return nil, ErrExpiredToken
+	case forcedVariations.ExpiresAt > now.Add(maxLifetime).Unix():
+		return nil, ErrLifetimeExceeded
+	}
+	switch {
+	case forcedVariations.VisitorID == "" && !forcedVariations.AllVisitors:
+		return nil, ErrMissingVisitor
+	case forcedVariations.VisitorID != "" && forcedVariations.VisitorID != visitorID:
+		return nil, ErrVisitorMismatch
+	}
+	return forcedVariations, nil
+}
+
+func sign(secret string, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,73 @@
+package qa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForcedVariations(t *testing.T) {
+	now := time.Now()
+	forcedVariations := &ForcedVariations{
+		Variations: map[string]string{"campaign_id": "variation_id"},
+		VisitorID:  "visitor_id",
+		ExpiresAt:  now.Add(time.Hour).Unix(),
+	}
+
+	_, err := SignForcedVariations("", forcedVariations)
+	assert.Equal(t, ErrForcedVariationsDisabled, err)
+
+	token, err := SignForcedVariations("secret", forcedVariations)
+	assert.Nil(t, err)
+
+	verified, err := VerifyForcedVariations("secret", token, "visitor_id", now, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, forcedVariations, verified)
+
+	_, err = VerifyForcedVariations("", token, "visitor_id", now, 0)
+	assert.Equal(t, ErrForcedVariationsDisabled, err)
+
+	_, err = VerifyForcedVariations("other_secret", token, "visitor_id", now, 0)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+	_, err = VerifyForcedVariations("secret", payload+"e."+signature, "visitor_id", now, 0)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	_, err = VerifyForcedVariations("secret", payload, "visitor_id", now, 0)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = VerifyForcedVariations("secret", token, "other_visitor_id", now, 0)
+	assert.Equal(t, ErrVisitorMismatch, err)
+
+	_, err = VerifyForcedVariations("secret", token, "visitor_id", now.Add(2*time.Hour), 0)
+	assert.Equal(t, ErrExpiredToken, err)
+
+	// the expiration is required, within the maximum lifetime
+	_, err = VerifyForcedVariations("secret", token, "visitor_id", now, 30*time.Minute)
+	assert.Equal(t, ErrLifetimeExceeded, err)
+
+	token, err = SignForcedVariations("secret", &ForcedVariations{Variations: forcedVariations.Variations, VisitorID: "visitor_id"})
+	assert.Nil(t, err)
+	_, err = VerifyForcedVariations("secret", token, "visitor_id", now, 0)
+	assert.Equal(t, ErrMissingExpiration, err)
+
+	token, err = SignForcedVariations("secret", &ForcedVariations{Variations: forcedVariations.Variations, VisitorID: "visitor_id", ExpiresAt: now.Add(48 * time.Hour).Unix()})
+	assert.Nil(t, err)
+	_, err = VerifyForcedVariations("secret", token, "visitor_id", now, 0)
+	assert.Equal(t, ErrLifetimeExceeded, err)
+
+	// the visitor is required, unless the token explicitly applies to all visitors
+	token, err = SignForcedVariations("secret", &ForcedVariations{Variations: forcedVariations.Variations, ExpiresAt: forcedVariations.ExpiresAt})
+	assert.Nil(t, err)
+	_, err = VerifyForcedVariations("secret", token, "visitor_id", now, 0)
+	assert.Equal(t, ErrMissingVisitor, err)
+
+	token, err = SignForcedVariations("secret", &ForcedVariations{Variations: forcedVariations.Variations, AllVisitors: true, ExpiresAt: forcedVariations.ExpiresAt})
+	assert.Nil(t, err)
+	verified, err = VerifyForcedVariations("secret", token, "other_visitor_id", now, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "variation_id", verified.Variations["campaign_id"])
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracerName is the name of the tracer of the API spans
+const TracerName = "github.com/flagship-io/decision-api"
+
+// NewTracerProvider creates a tracer provider exporting the spans with the exporter of the options
+func NewTracerProvider(ctx context.Context, options *models.TracingOptions) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch options.Exporter {
+	case models.TracingExporterOTLP:
+		otlpOptions := []otlptracehttp.Option{}
+		if options.Endpoint != "" {
+			otlpOptions = append(otlpOptions, otlptracehttp.WithEndpoint(options.Endpoint))
+		}
+		if options.Insecure {
+			otlpOptions = append(otlpOptions, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, otlpOptions...)
+	case models.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %s", options.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error when creating %s tracing exporter: %v", options.Exporter, err)
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(options.ServiceName),
+		semconv.ServiceVersion(models.Version),
+	)
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	), nil
+}
+
+// StartSpan starts a span with the tracer, or a no-op span if the tracer is nil
+func StartSpan(ctx context.Context, tracer trace.Tracer, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer(TracerName)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// EndSpan records the error if any and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flagship-io/decision-api/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	_, err := NewTracerProvider(context.Background(), &models.TracingOptions{Exporter: "unknown"})
+	assert.NotNil(t, err)
+
+	provider, err := NewTracerProvider(context.Background(), &models.TracingOptions{
+		Exporter:    models.TracingExporterStdout,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, provider.Shutdown(context.Background()))
+
+	provider, err = NewTracerProvider(context.Background(), &models.TracingOptions{
+		Exporter:    models.TracingExporterOTLP,
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		ServiceName: "test",
+		SampleRatio: 1,
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, provider)
+}
+
+func TestSpans(t *testing.T) {
+	// nil tracer starts a no-op span
+	_, span := StartSpan(context.Background(), nil, "noop")
+	assert.False(t, span.SpanContext().IsValid())
+	EndSpan(span, nil)
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	_, span = StartSpan(context.Background(), tracer, "success")
+	EndSpan(span, nil)
+	_, span = StartSpan(context.Background(), tracer, "failure")
+	EndSpan(span, errors.New("error"))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "error", spans[1].Status().Description)
+}